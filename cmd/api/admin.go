@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type updateRoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=user moderator admin"`
+}
+
+// UpdateUserRole changes the role of a user
+//
+//	@Summary		Changes the role of a user
+//	@Description	Changes the role of a user. Only admins may call this endpoint; the first admin of an installation is made from the command line with migrate promote-admin EMAIL.
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			userId	path		int					true	"User ID"
+//	@Param			role	body		updateRoleRequest	true	"Role"
+//	@Success		200		{object}	database.User
+//	@Router			/admin/users/{userId}/role [put]
+//	@Security		BearerAuth
+func (app *application) updateUserRole(c *gin.Context) {
+	userId, err := strconv.Atoi(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user id"})
+		return
+	}
+
+	var request updateRoleRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := app.models.Users.GetUserById(userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve user"})
+		return
+	}
+
+	if user == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if err := app.models.Users.UpdateRole(c, userId, request.Role); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role"})
+		return
+	}
+
+	user.Role = request.Role
+
+	c.JSON(http.StatusOK, user)
+}
@@ -1,9 +1,15 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"rest-api-event-app/internal/database"
+	"rest-api-event-app/internal/jobs"
+	"rest-api-event-app/internal/mailer"
 	"strconv"
 	"time"
 
@@ -12,6 +18,11 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+const (
+	jobPurgeRevokedTokens = "tokens.purge"
+	jobSendPasswordReset  = "auth.password_reset"
+)
+
 type registerRequest struct {
 	Email    string `json:"email" binding:"required,email"`
 	Password string `json:"password" binding:"required,min=8"`
@@ -26,11 +37,33 @@ type loginRequest struct {
 type MyJWTClaims struct {
 	ID   string `json:"id"`
 	Name string `json:"name"`
+	Role string `json:"role"`
 	jwt.RegisteredClaims
 }
 
 type loginResponse struct {
-	Token string `json:"token"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+type forgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// passwordResetJob asks for a password reset link to be mailed to email, if
+// an account is registered with it.
+type passwordResetJob struct {
+	Email string `json:"email"`
+}
+
+type resetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=8"`
+}
+
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
 }
 
 func (app *application) login(c *gin.Context) {
@@ -42,39 +75,161 @@ func (app *application) login(c *gin.Context) {
 	}
 
 	existingUser, err := app.models.Users.GetUserByEmail(auth.Email)
-	fmt.Println("email :", existingUser.Email, "password :", existingUser.Password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Something went wrong"})
+		return
+	}
+
 	if existingUser == nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
 		return
 	}
 
+	err = bcrypt.CompareHashAndPassword([]byte(existingUser.Password), []byte(auth.Password))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Something went wrong"})
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
 		return
 	}
 
-	err = bcrypt.CompareHashAndPassword([]byte(existingUser.Password), []byte(auth.Password))
+	now := time.Now()
+	accessTokenId, err := generateTokenId()
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generating token"})
 		return
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, MyJWTClaims{
-		ID:   strconv.Itoa(int(existingUser.ID)),
-		Name: existingUser.Name,
-		RegisteredClaims: jwt.RegisteredClaims{
-			Issuer:    strconv.Itoa(int(existingUser.ID)),
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * 24)),
-		},
+	refreshToken, refreshTokenHash, err := generateToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generating token"})
+		return
+	}
+
+	_, err = app.models.RefreshTokens.Insert(c, &database.RefreshToken{
+		UserId:          existingUser.ID,
+		TokenHash:       refreshTokenHash,
+		AccessTokenId:   accessTokenId,
+		AccessExpiresAt: now.Add(app.accessTokenTTL),
+		ExpiresAt:       now.Add(app.refreshTokenTTL),
+		CreatedAt:       now,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generating token"})
+		return
+	}
+
+	tokenToString, err := app.signAccessToken(existingUser, accessTokenId, now)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generating token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, loginResponse{
+		Token:        tokenToString,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(app.accessTokenTTL.Seconds()),
 	})
+}
+
+func (app *application) refreshToken(c *gin.Context) {
+	var request refreshRequest
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	now := time.Now()
+	accessTokenId, err := generateTokenId()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generating token"})
+		return
+	}
 
-	tokenToString, err := token.SignedString([]byte(app.jwtSecret))
+	refreshToken, refreshTokenHash, err := generateToken()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generating token"})
 		return
 	}
 
-	c.JSON(http.StatusOK, loginResponse{Token: tokenToString})
+	session, err := app.models.RefreshTokens.Rotate(c, hashToken(request.RefreshToken), &database.RefreshToken{
+		TokenHash:       refreshTokenHash,
+		AccessTokenId:   accessTokenId,
+		AccessExpiresAt: now.Add(app.accessTokenTTL),
+		ExpiresAt:       now.Add(app.refreshTokenTTL),
+		CreatedAt:       now,
+	})
+	if err != nil {
+		if errors.Is(err, database.ErrRefreshTokenInvalid) || errors.Is(err, database.ErrRefreshTokenReused) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Something went wrong"})
+		return
+	}
+
+	user, err := app.models.Users.GetUserById(session.UserId)
+	if err != nil || user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		return
+	}
+
+	tokenToString, err := app.signAccessToken(user, accessTokenId, now)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generating token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, loginResponse{
+		Token:        tokenToString,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(app.accessTokenTTL.Seconds()),
+	})
+}
+
+func (app *application) logout(c *gin.Context) {
+	user := app.GetUserFromContext(c)
+	claims := app.GetClaimsFromContext(c)
+
+	err := app.models.RefreshTokens.RevokeSession(c, user.ID, claims.RegisteredClaims.ID, claims.ExpiresAt.Time, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+func (app *application) logoutAll(c *gin.Context) {
+	user := app.GetUserFromContext(c)
+
+	if err := app.models.RefreshTokens.RevokeAllForUser(c, user.ID, time.Now()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// purgeRevokedTokens removes the revoked access tokens that have expired
+// since, so the blacklist checked on every request stays small.
+func (app *application) purgeRevokedTokens(ctx context.Context, job *database.Job) error {
+	return app.models.RevokedTokens.DeleteExpired(ctx, time.Now().UTC())
+}
+
+func (app *application) signAccessToken(user *database.User, tokenId string, now time.Time) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, MyJWTClaims{
+		ID:   strconv.Itoa(user.ID),
+		Name: user.Name,
+		Role: user.Role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        tokenId,
+			Issuer:    strconv.Itoa(user.ID),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(app.accessTokenTTL)),
+		},
+	})
+
+	return token.SignedString([]byte(app.jwtSecret))
 }
 
 func (app *application) registerUser(c *gin.Context) {
@@ -104,5 +259,156 @@ func (app *application) registerUser(c *gin.Context) {
 		return
 	}
 
+	if err := app.sendVerificationEmail(c, user); err != nil {
+		log.Printf("Failed to send verification email to user %d: %s", user.ID, err)
+	}
+
 	c.JSON(http.StatusOK, user)
 }
+
+type verifyEmailRequest struct {
+	Token string `json:"token" form:"token" binding:"required"`
+}
+
+// verifyEmail accepts the token either as a query parameter, so the link in
+// the email works when opened directly, or as a JSON body.
+func (app *application) verifyEmail(c *gin.Context) {
+	var request verifyEmailRequest
+
+	bind := c.ShouldBindJSON
+	if c.Request.Method == http.MethodGet {
+		bind = c.ShouldBindQuery
+	}
+
+	if err := bind(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	_, err := app.models.Verifications.Verify(c, hashToken(request.Token), time.Now())
+	if err != nil {
+		if errors.Is(err, database.ErrVerificationTokenInvalid) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired verification token"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email has been verified"})
+}
+
+func (app *application) resendVerification(c *gin.Context) {
+	user := app.GetUserFromContext(c)
+
+	if user.Verified {
+		c.JSON(http.StatusConflict, gin.H{"error": "Email is already verified"})
+		return
+	}
+
+	if err := app.sendVerificationEmail(c, user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send verification email"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "Verification email has been sent"})
+}
+
+func (app *application) sendVerificationEmail(c *gin.Context, user *database.User) error {
+	token, tokenHash, err := generateToken()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if err := app.models.Verifications.Create(c, user.ID, tokenHash, now, now.Add(app.verificationTTL)); err != nil {
+		return err
+	}
+
+	return app.mailer.Send(c, mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease confirm your email address by opening the link below. It expires in %s.\n\n%s/api/v1/auth/verify?token=%s\n",
+			user.Name, app.verificationTTL, app.appURL, token),
+	})
+}
+
+func (app *application) forgotPassword(c *gin.Context) {
+	var request forgotPasswordRequest
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// The account is looked up and mailed by a job, so the response is the
+	// same, and takes as long, whether or not the email is registered, and
+	// the endpoint cannot be used to find out which emails are.
+	if _, err := app.jobs.Enqueue(c, jobSendPasswordReset, passwordResetJob{Email: request.Email}, jobs.Options{}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Something went wrong"})
+		return
+	}
+
+	app.jobs.Notify()
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "If the email is registered, a password reset link has been sent"})
+}
+
+// sendPasswordReset mails a password reset link to the account of the
+// email, if there is one. A retry issues a new link; the link of a failed
+// attempt is never seen and simply expires.
+func (app *application) sendPasswordReset(ctx context.Context, job *database.Job) error {
+	var payload passwordResetJob
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return jobs.Permanent(err)
+	}
+
+	user, err := app.models.Users.GetUserByEmail(payload.Email)
+	if err != nil || user == nil {
+		return err
+	}
+
+	token, tokenHash, err := generateToken()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if err := app.models.PasswordResets.Create(ctx, user.ID, tokenHash, now, now.Add(app.passwordResetTTL)); err != nil {
+		return err
+	}
+
+	return app.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nUse the link below to choose a new password. It expires in %s and can only be used once.\n\n%s/reset-password?token=%s\n\nIf you did not ask for this, you can ignore this email.\n",
+			user.Name, app.passwordResetTTL, app.appURL, token),
+	})
+}
+
+func (app *application) resetPassword(c *gin.Context) {
+	var request resetPasswordRequest
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(request.Password), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed hashed password"})
+		return
+	}
+
+	_, err = app.models.PasswordResets.ResetPassword(c, hashToken(request.Token), string(hashedPassword), time.Now())
+	if err != nil {
+		if errors.Is(err, database.ErrResetTokenInvalid) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired reset token"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password has been reset"})
+}
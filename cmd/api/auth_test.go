@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"rest-api-event-app/internal/database"
+	"rest-api-event-app/internal/jobs"
+	"rest-api-event-app/internal/mailer"
+	"rest-api-event-app/internal/testdb"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestForgotPassword asks for a reset of a registered and an unknown email:
+// both only queue a job, and only the job of the registered one sends mail.
+func TestForgotPassword(t *testing.T) {
+	models := database.NewModels(testdb.Open(t))
+	outbox := mailer.NewMemoryOutbox()
+	app := &application{
+		models:           models,
+		mailer:           outbox,
+		jobs:             jobs.NewPool(&models.Jobs, jobs.Config{}),
+		passwordResetTTL: time.Hour,
+		appURL:           "https://events.example.com",
+	}
+
+	user := createTestUser(t, &models)
+	unknown := testdb.UniqueName("nobody") + "@example.com"
+
+	queued := func(email string) *database.Job {
+		t.Helper()
+
+		w := serveAs(nil, app.forgotPassword, nil, fmt.Sprintf(`{"email":%q}`, email))
+		if w.Code != http.StatusAccepted {
+			t.Fatalf("forgot password for %s responded %d, want 202", email, w.Code)
+		}
+
+		queuedJobs, err := models.Jobs.List(context.Background(), jobSendPasswordReset, database.JobStatusQueued, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, job := range queuedJobs {
+			var payload passwordResetJob
+			if json.Unmarshal(job.Payload, &payload) == nil && payload.Email == email {
+				return job
+			}
+		}
+
+		t.Fatalf("no password reset job queued for %s", email)
+		return nil
+	}
+
+	registeredJob, unknownJob := queued(user.Email), queued(unknown)
+
+	if messages := outbox.Messages(); len(messages) != 0 {
+		t.Fatalf("%d emails sent while handling the requests, want none", len(messages))
+	}
+
+	for _, job := range []*database.Job{registeredJob, unknownJob} {
+		if err := app.sendPasswordReset(context.Background(), job); err != nil {
+			t.Fatalf("running job %d: %s", job.ID, err)
+		}
+	}
+
+	messages := outbox.Messages()
+	if len(messages) != 1 || messages[0].To != user.Email || !strings.Contains(messages[0].Body, "/reset-password?token=") {
+		t.Errorf("sent %+v, want a single reset link to %s", messages, user.Email)
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"rest-api-event-app/internal/database"
+	"rest-api-event-app/internal/ical"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const icalProdID = "-//rest-api-event-app//Events//EN"
+
+type calendarFeedResponse struct {
+	URL       string `json:"url"`
+	WebcalURL string `json:"webcal_url"`
+}
+
+// GetEventICS returns a single event as an iCalendar file
+//
+//	@Summary		Returns an event as an iCalendar file
+//	@Description	Returns an event as an RFC 5545 iCalendar file that calendar apps can import, including its recurrence rule and time zone
+//	@Tags			calendar
+//	@Produce		text/calendar
+//	@Param			eventId	path		int	true	"Event ID"
+//	@Success		200		{string}	string	"iCalendar file"
+//	@Router			/events/{eventId}/ics [get]
+func (app *application) getEventICS(c *gin.Context) {
+	eventId, err := strconv.Atoi(c.Param("eventId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event id"})
+		return
+	}
+
+	event, err := app.models.Events.GetEventById(eventId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve event"})
+		return
+	}
+
+	if event == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="event-%d.ics"`, event.ID))
+	app.writeCalendar(c, event.Name, []ical.Event{app.icalEvent(event, time.Now().UTC())})
+}
+
+// CreateCalendarFeed creates the authenticated user's calendar feed
+//
+//	@Summary		Creates a personal calendar feed
+//	@Description	Creates a secret calendar feed URL listing the events the authenticated user attends or owns. Calling it again replaces the URL, so the previous one stops working.
+//	@Tags			calendar
+//	@Produce		json
+//	@Success		201	{object}	calendarFeedResponse
+//	@Router			/me/calendar-feed [post]
+//	@Security		BearerAuth
+func (app *application) createCalendarFeed(c *gin.Context) {
+	user := app.GetUserFromContext(c)
+
+	token, hash, err := generateToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create calendar feed"})
+		return
+	}
+
+	if err := app.models.CalendarFeeds.Rotate(c, user.ID, hash, time.Now().UTC()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create calendar feed"})
+		return
+	}
+
+	feedURL := strings.TrimRight(app.appURL, "/") + "/api/v1/calendar/" + token + ".ics"
+	webcalURL := feedURL
+	if i := strings.Index(feedURL, "://"); i >= 0 {
+		webcalURL = "webcal" + feedURL[i:]
+	}
+
+	c.JSON(http.StatusCreated, calendarFeedResponse{URL: feedURL, WebcalURL: webcalURL})
+}
+
+// DeleteCalendarFeed revokes the authenticated user's calendar feed
+//
+//	@Summary		Revokes the personal calendar feed
+//	@Description	Revokes the authenticated user's calendar feed URL
+//	@Tags			calendar
+//	@Produce		json
+//	@Success		204	{string}	string	"No Content"
+//	@Router			/me/calendar-feed [delete]
+//	@Security		BearerAuth
+func (app *application) deleteCalendarFeed(c *gin.Context) {
+	user := app.GetUserFromContext(c)
+
+	deleted, err := app.models.CalendarFeeds.Delete(c, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke calendar feed"})
+		return
+	}
+
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Calendar feed not found"})
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// GetCalendarFeed returns a user's calendar feed
+//
+//	@Summary		Returns a personal calendar feed
+//	@Description	Returns the events a user owns or holds a confirmed seat at as an iCalendar feed. Registrations that are declined, waitlisted or awaiting payment are left out, and registrations for single occurrences of a recurring event list only those occurrences. The token in the URL authenticates the request, so calendar apps can subscribe without logging in.
+//	@Tags			calendar
+//	@Produce		text/calendar
+//	@Param			token	path		string	true	"Feed token, optionally followed by .ics"
+//	@Success		200		{string}	string	"iCalendar feed"
+//	@Router			/calendar/{token} [get]
+func (app *application) getCalendarFeed(c *gin.Context) {
+	token := strings.TrimSuffix(c.Param("token"), ".ics")
+
+	userId, err := app.models.CalendarFeeds.GetUserIdByToken(c, hashToken(token))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve calendar feed"})
+		return
+	}
+
+	if userId == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Calendar feed not found"})
+		return
+	}
+
+	attending, err := app.models.Attendees.GetConfirmedByUser(c, userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get events"})
+		return
+	}
+
+	owned, err := app.models.Events.GetEventsByOwner(c, userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get events"})
+		return
+	}
+
+	now := time.Now().UTC()
+
+	// Owned events and series registrations list the whole series. Owners
+	// may also be registered for their own events, so every event is listed
+	// once.
+	series := map[int]bool{}
+	events := []ical.Event{}
+	for _, event := range owned {
+		series[event.ID] = true
+		events = append(events, app.icalEvent(event, now))
+	}
+
+	for _, attendance := range attending {
+		if attendance.Occurrence == nil && !series[attendance.Event.ID] {
+			series[attendance.Event.ID] = true
+			events = append(events, app.icalEvent(attendance.Event, now))
+		}
+	}
+
+	// Registrations for single occurrences list only those, unless the
+	// occurrence was cancelled or moved since.
+	for _, attendance := range attending {
+		event, occurrence := attendance.Event, attendance.Occurrence
+		if occurrence == nil || series[event.ID] || !event.IsOccurrence(*occurrence) {
+			continue
+		}
+
+		events = append(events, app.icalOccurrence(event, *occurrence, now))
+	}
+
+	app.writeCalendar(c, "My events", events)
+}
+
+// writeCalendar responds with the events as an iCalendar object.
+func (app *application) writeCalendar(c *gin.Context, name string, events []ical.Event) {
+	cal := ical.Calendar{ProdID: icalProdID, Name: name, Events: events}
+
+	var buf bytes.Buffer
+	if err := ical.Encode(&buf, cal); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encode calendar"})
+		return
+	}
+
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", buf.Bytes())
+}
+
+func (app *application) icalEvent(event *database.Event, now time.Time) ical.Event {
+	return ical.Event{
+		UID:         fmt.Sprintf("event-%d@%s", event.ID, app.icalHost()),
+		Stamp:       now,
+		Summary:     event.Name,
+		Description: event.Description,
+		Location:    event.Location,
+		URL:         fmt.Sprintf("%s/api/v1/events/%d", strings.TrimRight(app.appURL, "/"), event.ID),
+		Start:       event.StartsAt,
+		End:         event.EndsAt,
+		TimeZone:    event.TimeZone,
+		RRule:       event.RecurrenceRule,
+		ExDates:     event.ExceptionDates,
+	}
+}
+
+// icalOccurrence presents a single occurrence of a recurring event as an
+// event of its own, with a UID of its own so it does not replace the series
+// in calendars that also list it.
+func (app *application) icalOccurrence(event *database.Event, start, now time.Time) ical.Event {
+	occurrence := app.icalEvent(event, now)
+	occurrence.UID = fmt.Sprintf("event-%d-%d@%s", event.ID, start.Unix(), app.icalHost())
+	occurrence.Start = start
+	occurrence.End = start.Add(event.EndsAt.Sub(event.StartsAt))
+	occurrence.RRule = ""
+	occurrence.ExDates = nil
+
+	return occurrence
+}
+
+// icalHost returns the host name UIDs are scoped to.
+func (app *application) icalHost() string {
+	if u, err := url.Parse(app.appURL); err == nil && u.Hostname() != "" {
+		return u.Hostname()
+	}
+
+	return "localhost"
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"rest-api-event-app/internal/database"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxScanClockSkew is how far in the future a device's clock may be before
+// its scans are rejected.
+const maxScanClockSkew = 5 * time.Minute
+
+// checkInManifest is what a scanning device verifies tickets against while
+// offline. Tickets are listed by the SHA-256 of their token, so the manifest
+// cannot be used to forge tickets.
+type checkInManifest struct {
+	Version     int              `json:"version"`
+	EventId     int              `json:"event_id"`
+	EventName   string           `json:"event_name"`
+	GeneratedAt time.Time        `json:"generated_at"`
+	Tickets     []manifestTicket `json:"tickets"`
+}
+
+type manifestTicket struct {
+	AttendeeId int               `json:"attendee_id"`
+	Name       string            `json:"name"`
+	Occurrence *time.Time        `json:"occurrence,omitempty"`
+	TicketHash string            `json:"ticket_hash"`
+	CheckIns   []manifestCheckIn `json:"check_ins"`
+}
+
+// manifestCheckIn records that a ticket was used at an occurrence.
+type manifestCheckIn struct {
+	Occurrence  time.Time `json:"occurrence"`
+	CheckedInAt time.Time `json:"checked_in_at"`
+}
+
+// signedManifest carries the manifest as the exact bytes that were signed,
+// base64 encoded, so devices can verify the Ed25519 signature before
+// decoding it. Devices should pin the public key rather than trust the one
+// sent alongside.
+type signedManifest struct {
+	Manifest  string `json:"manifest"`
+	Signature string `json:"signature"`
+	PublicKey string `json:"public_key"`
+	Algorithm string `json:"algorithm"`
+}
+
+type offlineScan struct {
+	Token      string     `json:"token" binding:"required"`
+	Occurrence *time.Time `json:"occurrence"`
+	ScannedAt  time.Time  `json:"scanned_at" binding:"required"`
+}
+
+type checkInSyncRequest struct {
+	Scans []offlineScan `json:"scans" binding:"required,min=1,max=5000,dive"`
+}
+
+type scanResult struct {
+	Index int `json:"index"`
+	database.ScanResult
+}
+
+type checkInSyncResponse struct {
+	CheckedIn       int          `json:"checked_in"`
+	Duplicates      int          `json:"duplicates"`
+	Invalid         int          `json:"invalid"`
+	Unconfirmed     int          `json:"unconfirmed"`
+	WrongOccurrence int          `json:"wrong_occurrence"`
+	Results         []scanResult `json:"results"`
+}
+
+// manifestKey derives the key check-in manifests are signed with from the
+// ticket secret, so it survives restarts without extra configuration.
+func (app *application) manifestKey() ed25519.PrivateKey {
+	seed := sha256.Sum256(append([]byte("manifest:v1:"), app.ticketSecret...))
+	return ed25519.NewKeyFromSeed(seed[:])
+}
+
+// GetCheckInManifest downloads the signed ticket manifest of an event
+//
+//	@Summary		Downloads the check-in manifest of an event
+//	@Description	Returns every confirmed ticket of an event, listed by the SHA-256 hex digest of its token together with the occurrences it was already checked in to, for scanning devices to verify tickets while offline. The manifest is base64-encoded JSON signed with Ed25519.
+//	@Tags			tickets
+//	@Produce		json
+//	@Param			eventId	path		int	true	"Event ID"
+//	@Success		200		{object}	signedManifest
+//	@Router			/events/{eventId}/check-in/manifest [get]
+//	@Security		BearerAuth
+func (app *application) getCheckInManifest(c *gin.Context) {
+	eventId, err := strconv.Atoi(c.Param("eventId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event id"})
+		return
+	}
+
+	event, err := app.models.Events.GetEventById(eventId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve event"})
+		return
+	}
+
+	if event == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+
+	if !app.authorize(c, actionCheckIn, event) {
+		return
+	}
+
+	tickets, err := app.models.Attendees.GetManifestTickets(c, event.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve tickets"})
+		return
+	}
+
+	manifest := checkInManifest{
+		Version:     2,
+		EventId:     event.ID,
+		EventName:   event.Name,
+		GeneratedAt: time.Now().UTC(),
+		Tickets:     make([]manifestTicket, len(tickets)),
+	}
+	for i, t := range tickets {
+		token := app.signTicket(ticket{EventId: event.ID, AttendeeId: t.AttendeeId, Code: t.TicketCode})
+		manifest.Tickets[i] = manifestTicket{
+			AttendeeId: t.AttendeeId,
+			Name:       t.Name,
+			Occurrence: t.Occurrence,
+			TicketHash: hashToken(token),
+			CheckIns:   make([]manifestCheckIn, len(t.CheckIns)),
+		}
+		for j, checkIn := range t.CheckIns {
+			manifest.Tickets[i].CheckIns[j] = manifestCheckIn{Occurrence: checkIn.Occurrence, CheckedInAt: checkIn.CheckedInAt}
+		}
+	}
+
+	payload, err := json.Marshal(manifest)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encode manifest"})
+		return
+	}
+
+	key := app.manifestKey()
+
+	c.Header("Cache-Control", "private, no-store")
+	c.JSON(http.StatusOK, signedManifest{
+		Manifest:  base64.StdEncoding.EncodeToString(payload),
+		Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(key, payload)),
+		PublicKey: base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey)),
+		Algorithm: "Ed25519",
+	})
+}
+
+// SyncCheckIns uploads check-ins recorded offline
+//
+//	@Summary		Uploads check-ins recorded offline
+//	@Description	Applies a batch of ticket scans recorded while a device was offline. Each scan checks in to the occurrence it names, or else the one open for check-in when it was scanned, under the same rules as online check-ins; scans of a ticket not valid there are reported as wrong_occurrence. The earliest scan of each ticket at an occurrence wins, across devices and check-ins already recorded, and later scans of it there are reported as duplicates. Results are returned in the order of the scans.
+//	@Tags			tickets
+//	@Accept			json
+//	@Produce		json
+//	@Param			eventId	path		int					true	"Event ID"
+//	@Param			scans	body		checkInSyncRequest	true	"Offline scans"
+//	@Success		200		{object}	checkInSyncResponse
+//	@Router			/events/{eventId}/check-in/sync [post]
+//	@Security		BearerAuth
+func (app *application) syncCheckIns(c *gin.Context) {
+	eventId, err := strconv.Atoi(c.Param("eventId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event id"})
+		return
+	}
+
+	var request checkInSyncRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	event, err := app.models.Events.GetEventById(eventId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve event"})
+		return
+	}
+
+	if event == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+
+	if !app.authorize(c, actionCheckIn, event) {
+		return
+	}
+
+	response := checkInSyncResponse{Results: make([]scanResult, len(request.Scans))}
+
+	// Scans with a bad signature, for another event, from a device whose
+	// clock is ahead or made while no occurrence was open never reach the
+	// database.
+	var scans []database.OfflineScan
+	var indexes []int
+	latest := time.Now().Add(maxScanClockSkew)
+	for i, scan := range request.Scans {
+		response.Results[i] = scanResult{Index: i, ScanResult: database.ScanResult{Status: database.ScanInvalid}}
+
+		t, err := app.parseTicket(strings.TrimSpace(scan.Token))
+		if err != nil || t.EventId != event.ID || scan.ScannedAt.After(latest) {
+			continue
+		}
+
+		response.Results[i].AttendeeId = t.AttendeeId
+
+		occurrence, err := app.checkInOccurrence(event, scan.Occurrence, scan.ScannedAt)
+		if err != nil {
+			response.Results[i].Status = database.ScanWrongOccurrence
+			continue
+		}
+
+		scans = append(scans, database.OfflineScan{AttendeeId: t.AttendeeId, TicketCode: t.Code, Occurrence: occurrence, ScannedAt: scan.ScannedAt})
+		indexes = append(indexes, i)
+	}
+
+	if len(scans) > 0 {
+		results, err := app.models.Attendees.SyncCheckIns(c, event.ID, scans)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sync check-ins"})
+			return
+		}
+
+		for j, result := range results {
+			response.Results[indexes[j]].ScanResult = result
+		}
+	}
+
+	for _, result := range response.Results {
+		switch result.Status {
+		case database.ScanCheckedIn:
+			response.CheckedIn++
+		case database.ScanDuplicate:
+			response.Duplicates++
+		case database.ScanUnconfirmed:
+			response.Unconfirmed++
+		case database.ScanWrongOccurrence:
+			response.WrongOccurrence++
+		default:
+			response.Invalid++
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
@@ -19,3 +19,17 @@ func (app *application) GetUserFromContext(c *gin.Context) *database.User {
 
 	return user
 }
+
+func (app *application) GetClaimsFromContext(c *gin.Context) *MyJWTClaims {
+	contextClaims, exist := c.Get("claims")
+	if !exist {
+		return &MyJWTClaims{}
+	}
+
+	claims, ok := contextClaims.(*MyJWTClaims)
+	if !ok {
+		return &MyJWTClaims{}
+	}
+
+	return claims
+}
@@ -1,10 +1,12 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"rest-api-event-app/internal/database"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -12,7 +14,7 @@ import (
 // CreateEvent creates a new event
 //
 //	@Summary		Creates a new event
-//	@Description	Creates a new event
+//	@Description	Creates a new event. starts_at and ends_at are RFC 3339 timestamps and timezone is an IANA time zone such as Europe/Berlin; responses present both times in that zone. A recurring event sets recurrence_rule to an RFC 5545 RRULE, evaluated in its time zone from starts_at, and may cancel single occurrences through exception_dates.
 //	@Tags			events
 //	@Accept			json
 //	@Produce		json
@@ -28,8 +30,14 @@ func (app *application) createEvent(c *gin.Context) {
 		return
 	}
 
+	if err := event.ValidateRecurrence(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	user := app.GetUserFromContext(c)
 	event.OwnerId = user.ID
+	event.Occurrences = nil
 
 	result, err := app.models.Events.InsertEvent(c, &event)
 
@@ -41,24 +49,96 @@ func (app *application) createEvent(c *gin.Context) {
 	c.JSON(http.StatusCreated, result)
 }
 
-// GetAllEvent return all events
+type listEventsQuery struct {
+	Search   string `form:"q"`
+	From     string `form:"from" binding:"omitempty,datetime=2006-01-02"`
+	To       string `form:"to" binding:"omitempty,datetime=2006-01-02"`
+	Location string `form:"location"`
+	OwnerId  int    `form:"owner_id" binding:"omitempty,min=1"`
+	Sort     string `form:"sort" binding:"omitempty,oneof=date -date name -name id -id"`
+	Limit    int    `form:"limit" binding:"omitempty,min=1,max=100"`
+	Cursor   string `form:"cursor"`
+}
+
+// GetAllEvent return events matching the given filters
 //
-//	@Summary		Return all events
-//	@Description	Return all events
+//	@Summary		Return events
+//	@Description	Return a page of events filtered by text, date range, location and owner. Pass next_cursor back as cursor to fetch the following page. When both from and to are given, recurring events list their occurrences in that range and series without one are left out of both the page and total.
 //	@Tags			events
 //	@Accept			json
 //	@Produce		json
-//	@Success		200	{array}	database.Event
+//	@Param			q			query		string	false	"Search in name and description"
+//	@Param			from		query		string	false	"Only events still running on or after this UTC date (YYYY-MM-DD)"
+//	@Param			to			query		string	false	"Only events starting on or before this UTC date (YYYY-MM-DD)"
+//	@Param			location	query		string	false	"Location contains"
+//	@Param			owner_id	query		int		false	"Owner ID"
+//	@Param			sort		query		string	false	"Sort order"	Enums(date, -date, name, -name, id, -id)
+//	@Param			limit		query		int		false	"Page size (max 100)"
+//	@Param			cursor		query		string	false	"Cursor from a previous page"
+//	@Success		200			{object}	database.EventPage
 //	@Router			/events [get]
 func (app *application) getAllEvent(c *gin.Context) {
-	events, err := app.models.Events.GetAllEvent()
+	var query listEventsQuery
+
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	page, err := app.models.Events.ListEvents(c, database.EventFilter{
+		Search:   query.Search,
+		From:     query.From,
+		To:       query.To,
+		Location: query.Location,
+		OwnerId:  query.OwnerId,
+		Sort:     query.Sort,
+		Limit:    query.Limit,
+		Cursor:   query.Cursor,
+	})
 
 	if err != nil {
+		if errors.Is(err, database.ErrInvalidCursor) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve events"})
 		return
 	}
 
-	c.JSON(http.StatusOK, events)
+	c.JSON(http.StatusOK, page)
+}
+
+type searchEventsQuery struct {
+	Query string `form:"q" binding:"required,min=2"`
+	Limit int    `form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+// SearchEvents return events ranked by relevance
+//
+//	@Summary		Search events
+//	@Description	Full-text search over event name, description and location, ranked by relevance with highlighted snippets
+//	@Tags			events
+//	@Accept			json
+//	@Produce		json
+//	@Param			q		query	string	true	"Search query"
+//	@Param			limit	query	int		false	"Maximum number of results (max 100)"
+//	@Success		200		{array}	database.EventSearchResult
+//	@Router			/events/search [get]
+func (app *application) searchEvents(c *gin.Context) {
+	var query searchEventsQuery
+
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	results, err := app.models.Events.Search(c, query.Query, query.Limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search events"})
+		return
+	}
+
+	c.JSON(http.StatusOK, results)
 }
 
 // GetEvent return a single event
@@ -81,15 +161,16 @@ func (app *application) getEvent(c *gin.Context) {
 
 	event, err := app.models.Events.GetEventById(id)
 
-	if event == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
-	}
-
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retreive event"})
 		return
 	}
 
+	if event == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+
 	c.JSON(http.StatusOK, event)
 }
 
@@ -113,21 +194,20 @@ func (app *application) updateEvent(c *gin.Context) {
 		return
 	}
 
-	user := app.GetUserFromContext(c)
 	existingEvent, err := app.models.Events.GetEventById(id)
 
-	if existingEvent == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
-	}
-
 	if err != nil {
 		fmt.Println(err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retreive event"})
 		return
 	}
 
-	if existingEvent.OwnerId != user.ID {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You are not authorized to update this event"})
+	if existingEvent == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+
+	if !app.authorize(c, actionUpdateEvent, existingEvent) {
 		return
 	}
 
@@ -138,14 +218,30 @@ func (app *application) updateEvent(c *gin.Context) {
 		return
 	}
 
+	if err := updatedEvent.ValidateRecurrence(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	updatedEvent.ID = id
+	updatedEvent.OwnerId = existingEvent.OwnerId
+	updatedEvent.Occurrences = nil
 
 	if err := app.models.Events.UpdateEvent(updatedEvent); err != nil {
+		if errors.Is(err, database.ErrEventNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+			return
+		}
 		fmt.Println(err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update event"})
 		return
 	}
 
+	if _, err := app.models.Attendees.PromoteFromWaitlist(c, id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to promote waitlisted attendees"})
+		return
+	}
+
 	c.JSON(http.StatusOK, updatedEvent)
 }
 
@@ -168,7 +264,6 @@ func (app *application) deleteEvent(c *gin.Context) {
 		return
 	}
 
-	user := app.GetUserFromContext(c)
 	existingEvent, err := app.models.Events.GetEventById(eventId)
 
 	if err != nil {
@@ -181,13 +276,17 @@ func (app *application) deleteEvent(c *gin.Context) {
 		return
 	}
 
-	if existingEvent.OwnerId != user.ID {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You are not authorized to delete this event"})
+	if !app.authorize(c, actionDeleteEvent, existingEvent) {
 		return
 	}
 
 	if err := app.models.Events.DeleteEvent(eventId); err != nil {
+		if errors.Is(err, database.ErrEventNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete"})
+		return
 	}
 
 	c.JSON(http.StatusNoContent, nil)
@@ -196,13 +295,15 @@ func (app *application) deleteEvent(c *gin.Context) {
 // AddAttendeeToEvent adds an attendee to an event
 //
 //	@Summary		Adds an attendee to an event
-//	@Description	Adds an attendee to an event
+//	@Description	Adds an attendee to an event, or to its waitlist when the event is full. For recurring events, occurrence registers the attendee for a single occurrence instead of the whole series. With ticket_type_id the attendee gets a complimentary ticket of that type: it is never charged and may be issued outside the sales window, but counts against the ticket type's quantity, and a full event responds with 409 instead of waitlisting.
 //	@Tags			attendees
 //	@Accept			json
 //	@Produce		json
-//	@Param			eventId	path		int	true	"Event ID"
-//	@Param			userId	path		int	true	"User ID"
-//	@Success		201		{object}	database.Attendee
+//	@Param			eventId			path		int		true	"Event ID"
+//	@Param			userId			path		int		true	"User ID"
+//	@Param			occurrence		query		string	false	"Start of a single occurrence (RFC 3339)"
+//	@Param			ticket_type_id	query		int		false	"Ticket type of a complimentary ticket"
+//	@Success		201			{object}	database.Attendee
 //	@Router			/events/{eventId}/attendees/{userId} [post]
 //	@Security		BearerAuth
 func (app *application) addAttendeeToEvent(c *gin.Context) {
@@ -242,32 +343,48 @@ func (app *application) addAttendeeToEvent(c *gin.Context) {
 		return
 	}
 
-	user := app.GetUserFromContext(c)
-
-	if event.OwnerId != user.ID {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You are not authorized to add an attendee"})
+	if !app.authorize(c, actionManageAttendees, event) {
 		return
 	}
 
-	existingAttendee, err := app.models.Attendees.GetByEventAndAttendee(c, event.ID, userToAdd.ID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve attendee"})
+	occurrence, ok := parseOccurrence(c)
+	if !ok || !checkOccurrence(c, event, occurrence) {
 		return
 	}
 
-	if existingAttendee != nil {
-		c.JSON(http.StatusConflict, gin.H{"error": "Attendee is already exists"})
-		return
-	}
+	if raw := c.Query("ticket_type_id"); raw != "" {
+		ticketTypeId, err := strconv.Atoi(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ticket type id"})
+			return
+		}
 
-	attendee := database.Attendee{
-		EventId: event.ID,
-		UserId:  userToAdd.ID,
+		attendee, _, err := app.models.Payments.Reserve(c, database.Purchase{
+			EventId:       event.ID,
+			UserId:        userToAdd.ID,
+			TicketTypeId:  ticketTypeId,
+			Occurrence:    occurrence,
+			Complimentary: true,
+		}, time.Now().UTC())
+		if err != nil {
+			respondReservationError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, attendee)
+		return
 	}
 
-	_, err = app.models.Attendees.Insert(c, &attendee)
+	attendee, err := app.models.Attendees.Register(c, event.ID, userToAdd.ID, occurrence)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add attendee"})
+		switch {
+		case errors.Is(err, database.ErrAlreadyRegistered):
+			c.JSON(http.StatusConflict, gin.H{"error": "Attendee is already exists"})
+		case errors.Is(err, database.ErrEventNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add attendee"})
+		}
 		return
 	}
 
@@ -277,12 +394,13 @@ func (app *application) addAttendeeToEvent(c *gin.Context) {
 // GetAttendeesForEvent returns all attendees for a given event
 //
 //	@Summary		Returns all attendees for a given event
-//	@Description	Returns all attendees for a given event
+//	@Description	Returns all attendees for a given event, or only those attending a single occurrence of a recurring event
 //	@Tags			attendees
 //	@Accept			json
 //	@Produce		json
-//	@Param			eventId	path		int	true	"Event ID"
-//	@Success		200		{array}		database.User
+//	@Param			eventId		path		int		true	"Event ID"
+//	@Param			occurrence	query		string	false	"Start of a single occurrence (RFC 3339)"
+//	@Success		200			{array}		database.User
 //	@Router			/events/{eventId}/attendees [get]
 func (app *application) getAttendeesForEvent(c *gin.Context) {
 	eventId, err := strconv.Atoi(c.Param("eventId"))
@@ -291,7 +409,12 @@ func (app *application) getAttendeesForEvent(c *gin.Context) {
 		return
 	}
 
-	users, err := app.models.Attendees.GetAttendeesByEvent(c, eventId)
+	occurrence, ok := parseOccurrence(c)
+	if !ok {
+		return
+	}
+
+	users, err := app.models.Attendees.GetAttendeesByEvent(c, eventId, occurrence)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve attendees for event"})
 		return
@@ -300,16 +423,49 @@ func (app *application) getAttendeesForEvent(c *gin.Context) {
 	c.JSON(http.StatusOK, users)
 }
 
+// GetWaitlistForEvent returns the waitlist of a given event
+//
+//	@Summary		Returns the waitlist of a given event
+//	@Description	Returns the waitlisted users of a given event in promotion order, optionally for a single occurrence of a recurring event
+//	@Tags			attendees
+//	@Accept			json
+//	@Produce		json
+//	@Param			eventId		path		int		true	"Event ID"
+//	@Param			occurrence	query		string	false	"Start of a single occurrence (RFC 3339)"
+//	@Success		200			{array}		database.User
+//	@Router			/events/{eventId}/waitlist [get]
+func (app *application) getWaitlistForEvent(c *gin.Context) {
+	eventId, err := strconv.Atoi(c.Param("eventId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event id"})
+		return
+	}
+
+	occurrence, ok := parseOccurrence(c)
+	if !ok {
+		return
+	}
+
+	users, err := app.models.Attendees.GetWaitlistByEvent(c, eventId, occurrence)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve waitlist for event"})
+		return
+	}
+
+	c.JSON(http.StatusOK, users)
+}
+
 // DeleteAttendeeFromEvent deletes an attendee from an event
 //
 //	@Summary		Deletes an attendee from an event
-//	@Description	Deletes an attendee from an event
+//	@Description	Deletes an attendee from an event, or from a single occurrence of a recurring event, and promotes the next waitlisted user into the freed seat
 //	@Tags			attendees
 //	@Accept			json
 //	@Produce		json
-//	@Param			eventId	path	int	true	"Event ID"
-//	@Param			userId	path	int	true	"User ID"
-//	@Success		204		{string}	string	"No Content"
+//	@Param			eventId		path		int		true	"Event ID"
+//	@Param			userId		path		int		true	"User ID"
+//	@Param			occurrence	query		string	false	"Start of a single occurrence (RFC 3339)"
+//	@Success		204			{string}	string	"No Content"
 //	@Router			/events/{eventId}/attendees/{userId} [delete]
 //	@Security		BearerAuth
 func (app *application) deleteAttendeeFromEvent(c *gin.Context) {
@@ -338,16 +494,25 @@ func (app *application) deleteAttendeeFromEvent(c *gin.Context) {
 		return
 	}
 
-	user := app.GetUserFromContext(c)
+	if !app.authorize(c, actionManageAttendees, event) {
+		return
+	}
 
-	if event.OwnerId != user.ID {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You are not authorized to delete an attendee from event"})
+	occurrence, ok := parseOccurrence(c)
+	if !ok {
 		return
 	}
 
-	err = app.models.Attendees.Delete(c, userId, eventId)
+	_, err = app.models.Attendees.Unregister(c, eventId, userId, occurrence)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete attendees"})
+		switch {
+		case errors.Is(err, database.ErrAttendeeNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Attendee not found"})
+		case errors.Is(err, database.ErrEventNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete attendees"})
+		}
 		return
 	}
 
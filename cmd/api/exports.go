@@ -0,0 +1,438 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"rest-api-event-app/internal/database"
+	"rest-api-event-app/internal/jobs"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xuri/excelize/v2"
+)
+
+const (
+	mimeCSV        = "text/csv"
+	mimeJSONLines  = "application/x-ndjson"
+	mimeXLSX       = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	attendeesSheet = "Attendees"
+)
+
+// exportFormats maps the format of an export to its media type and file
+// extension.
+var exportFormats = map[string]struct{ mime, ext string }{
+	"csv":   {mimeCSV, "csv"},
+	"jsonl": {mimeJSONLines, "jsonl"},
+	"xlsx":  {mimeXLSX, "xlsx"},
+}
+
+var attendeeExportHeader = []string{"user_id", "name", "email", "occurrence", "status", "rsvp_status", "registered_at", "checked_in_at"}
+
+const (
+	jobRunExport    = "exports.run"
+	jobPurgeExports = "exports.purge"
+)
+
+type exportQuery struct {
+	Format string `form:"format" binding:"omitempty,oneof=csv jsonl xlsx"`
+}
+
+// ExportAttendees queues an export of the attendee list of an event
+//
+//	@Summary		Exports the attendees of an event
+//	@Description	Queues an export of every registration of an event, including the waitlist and declined RSVPs, with registration time, RSVP status and check-in time, as CSV (the default), JSON Lines or XLSX. The export is written to a file in the background; poll the returned export until it succeeded, then download its file. CSV cells that start with =, +, -, @, a tab or a carriage return are prefixed with a quote so spreadsheet apps do not run them as formulas.
+//	@Tags			attendees
+//	@Produce		json
+//	@Param			eventId	path		int		true	"Event ID"
+//	@Param			format	query		string	false	"Export format"	Enums(csv, jsonl, xlsx)
+//	@Success		202		{object}	database.Export
+//	@Router			/events/{eventId}/attendees/export [post]
+//	@Security		BearerAuth
+func (app *application) exportAttendees(c *gin.Context) {
+	eventId, err := strconv.Atoi(c.Param("eventId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event id"})
+		return
+	}
+
+	var query exportQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if query.Format == "" {
+		query.Format = "csv"
+	}
+
+	event, err := app.models.Events.GetEventById(eventId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve event"})
+		return
+	}
+
+	if event == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+
+	if !app.authorize(c, actionManageAttendees, event) {
+		return
+	}
+
+	user := app.GetUserFromContext(c)
+
+	export, err := app.models.Exports.Insert(c, &database.Export{UserId: user.ID, EventId: event.ID, Format: query.Format}, app.jobs.NewJob(jobRunExport))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue export"})
+		return
+	}
+
+	app.jobs.Notify()
+
+	c.JSON(http.StatusAccepted, export)
+}
+
+// GetExport returns an attendee export of the authenticated user
+//
+//	@Summary		Returns an attendee export
+//	@Description	Returns an export the authenticated user requested. Its status is queued until it was written, then succeeded or failed. Finished exports and their files are removed after a while.
+//	@Tags			attendees
+//	@Produce		json
+//	@Param			exportId	path		int	true	"Export ID"
+//	@Success		200			{object}	database.Export
+//	@Router			/exports/{exportId} [get]
+//	@Security		BearerAuth
+func (app *application) getExport(c *gin.Context) {
+	export, ok := app.userExport(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, export)
+}
+
+// DownloadExport downloads the file of an attendee export
+//
+//	@Summary		Downloads the file of an attendee export
+//	@Description	Downloads the file of an export the authenticated user requested, once it succeeded. The user must still be allowed to manage the attendees of the event.
+//	@Tags			attendees
+//	@Produce		text/csv
+//	@Produce		application/x-ndjson
+//	@Produce		application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+//	@Param			exportId	path		int		true	"Export ID"
+//	@Success		200			{file}		file	"Attendee export"
+//	@Router			/exports/{exportId}/file [get]
+//	@Security		BearerAuth
+func (app *application) downloadExport(c *gin.Context) {
+	export, ok := app.userExport(c)
+	if !ok {
+		return
+	}
+
+	if export.Status != database.ExportStatusSucceeded {
+		c.JSON(http.StatusConflict, gin.H{"error": "The export has not succeeded"})
+		return
+	}
+
+	event, err := app.models.Events.GetEventById(export.EventId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve event"})
+		return
+	}
+
+	if event == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+
+	if !app.authorize(c, actionManageAttendees, event) {
+		return
+	}
+
+	// A large export can take longer to download than the server's
+	// WriteTimeout allows, which would cut the download off.
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Now().Add(database.ExportTimeoutDuration)); err != nil {
+		log.Printf("Extending the write deadline of export %d failed: %s", export.ID, err)
+	}
+
+	c.Header("Content-Type", exportFormats[export.Format].mime)
+	c.FileAttachment(app.exportPath(export), fmt.Sprintf("event-%d-attendees.%s", event.ID, exportFormats[export.Format].ext))
+}
+
+// userExport loads the export of a request, responding with 404 when the
+// authenticated user requested no such export.
+func (app *application) userExport(c *gin.Context) (*database.Export, bool) {
+	exportId, err := strconv.Atoi(c.Param("exportId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid export id"})
+		return nil, false
+	}
+
+	user := app.GetUserFromContext(c)
+
+	export, err := app.models.Exports.GetByUser(c, user.ID, exportId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve export"})
+		return nil, false
+	}
+
+	if export == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Export not found"})
+		return nil, false
+	}
+
+	return export, true
+}
+
+func (app *application) exportPath(export *database.Export) string {
+	return filepath.Join(app.exportDir, fmt.Sprintf("%d.%s", export.ID, exportFormats[export.Format].ext))
+}
+
+// runExport writes a queued export to its file. A failure is retried, and
+// fails the export once the job runs out of attempts.
+func (app *application) runExport(ctx context.Context, job *database.Job) error {
+	var payload database.ExportJob
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return jobs.Permanent(err)
+	}
+
+	export, err := app.models.Exports.Get(ctx, payload.ExportId)
+	if err != nil || export == nil || export.Status != database.ExportStatusQueued {
+		return err
+	}
+
+	if err := app.writeExport(ctx, export); err != nil {
+		if job.Attempts >= job.MaxAttempts {
+			if _, finishErr := app.models.Exports.Finish(ctx, export.ID, database.ExportStatusFailed, "Failed to export attendees", time.Now().UTC()); finishErr != nil {
+				log.Printf("Recording the failure of export %d failed: %s", export.ID, finishErr)
+			}
+		}
+		return err
+	}
+
+	_, err = app.models.Exports.Finish(ctx, export.ID, database.ExportStatusSucceeded, "", time.Now().UTC())
+	return err
+}
+
+// writeExport writes an export to a temporary file and moves it into place
+// once complete, so a download never sees a partial file, even when the job
+// runs twice at once.
+func (app *application) writeExport(ctx context.Context, export *database.Export) (err error) {
+	file, err := os.CreateTemp(app.exportDir, fmt.Sprintf("%d-*.tmp", export.ID))
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			file.Close()
+			os.Remove(file.Name())
+		}
+	}()
+
+	var exporter attendeeExporter
+	switch export.Format {
+	case "jsonl":
+		exporter = &jsonLinesExporter{enc: json.NewEncoder(file)}
+	case "xlsx":
+		exporter, err = newXLSXExporter(file)
+	default:
+		exporter, err = newCSVExporter(file)
+	}
+	if err != nil {
+		return err
+	}
+
+	err = app.models.Attendees.ExportByEvent(ctx, export.EventId, exporter.WriteRow)
+	if closeErr := exporter.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	if err = file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), app.exportPath(export))
+}
+
+// purgeExports removes the exports that finished longer than the retention
+// period ago, together with their files.
+func (app *application) purgeExports(ctx context.Context, job *database.Job) error {
+	const batchSize = 100
+
+	for {
+		exports, err := app.models.Exports.GetFinishedBefore(ctx, time.Now().UTC().Add(-app.exportRetention), batchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, export := range exports {
+			if err := os.Remove(app.exportPath(export)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return err
+			}
+
+			if err := app.models.Exports.Delete(ctx, export.ID); err != nil {
+				return err
+			}
+		}
+
+		if len(exports) < batchSize {
+			return nil
+		}
+	}
+}
+
+type attendeeExporter interface {
+	WriteRow(row *database.AttendeeExportRow) error
+	Close() error
+}
+
+// attendeeRecord formats a row for the tabular formats, with times in UTC.
+func attendeeRecord(row *database.AttendeeExportRow) []string {
+	return []string{
+		strconv.Itoa(row.UserId),
+		row.Name,
+		row.Email,
+		formatExportTime(row.Occurrence),
+		row.Status,
+		row.RSVPStatus,
+		formatExportTime(&row.RegisteredAt),
+		formatExportTime(row.CheckedInAt),
+	}
+}
+
+func formatExportTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+
+	return t.UTC().Format(time.RFC3339)
+}
+
+type csvExporter struct {
+	w *csv.Writer
+}
+
+func newCSVExporter(w io.Writer) (*csvExporter, error) {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(attendeeExportHeader); err != nil {
+		return nil, err
+	}
+
+	return &csvExporter{w: writer}, nil
+}
+
+func (e *csvExporter) WriteRow(row *database.AttendeeExportRow) error {
+	record := attendeeRecord(row)
+	for i, value := range record {
+		record[i] = csvSafe(value)
+	}
+
+	return e.w.Write(record)
+}
+
+// csvSafe keeps spreadsheet apps from running a cell as a formula, which
+// attendees could otherwise smuggle in through their name, by prefixing
+// cells that start like one with a quote. XLSX cells are written as text
+// and need no such escaping.
+func csvSafe(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+
+	return value
+}
+
+func (e *csvExporter) Close() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+type jsonLinesExporter struct {
+	enc *json.Encoder
+}
+
+func (e *jsonLinesExporter) WriteRow(row *database.AttendeeExportRow) error {
+	return e.enc.Encode(row)
+}
+
+func (e *jsonLinesExporter) Close() error {
+	return nil
+}
+
+// xlsxExporter writes rows through excelize's stream writer, which spills
+// them to a temporary file instead of keeping the sheet in memory. The
+// workbook can only be sent once it is complete.
+type xlsxExporter struct {
+	w      io.Writer
+	file   *excelize.File
+	stream *excelize.StreamWriter
+	row    int
+}
+
+func newXLSXExporter(w io.Writer) (*xlsxExporter, error) {
+	file := excelize.NewFile()
+	if err := file.SetSheetName("Sheet1", attendeesSheet); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	stream, err := file.NewStreamWriter(attendeesSheet)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	e := &xlsxExporter{w: w, file: file, stream: stream}
+	if err := e.writeCells(attendeeExportHeader); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return e, nil
+}
+
+func (e *xlsxExporter) WriteRow(row *database.AttendeeExportRow) error {
+	return e.writeCells(attendeeRecord(row))
+}
+
+func (e *xlsxExporter) writeCells(values []string) error {
+	e.row++
+
+	cells := make([]any, len(values))
+	for i, value := range values {
+		cells[i] = value
+	}
+
+	cell, err := excelize.CoordinatesToCellName(1, e.row)
+	if err != nil {
+		return err
+	}
+
+	return e.stream.SetRow(cell, cells)
+}
+
+func (e *xlsxExporter) Close() error {
+	defer e.file.Close()
+
+	if err := e.stream.Flush(); err != nil {
+		return err
+	}
+
+	return e.file.Write(e.w)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"rest-api-event-app/internal/database"
+	"testing"
+	"time"
+)
+
+func TestCSVSafe(t *testing.T) {
+	tests := []struct {
+		value, want string
+	}{
+		{"", ""},
+		{"Ada Lovelace", "Ada Lovelace"},
+		{"=HYPERLINK(\"http://evil\")", "'=HYPERLINK(\"http://evil\")"},
+		{"+1 555 0100", "'+1 555 0100"},
+		{"-2+3", "'-2+3"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+		{"\tcmd", "'\tcmd"},
+		{"\rcmd", "'\rcmd"},
+		{"a=b", "a=b"},
+	}
+
+	for _, tt := range tests {
+		if got := csvSafe(tt.value); got != tt.want {
+			t.Errorf("csvSafe(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestCSVExporterEscapesFormulas(t *testing.T) {
+	var buf bytes.Buffer
+	exporter, err := newCSVExporter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	registeredAt := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+	err = exporter.WriteRow(&database.AttendeeExportRow{
+		UserId:       7,
+		Name:         "=1+1",
+		Email:        "ada@example.com",
+		Status:       database.AttendeeStatusConfirmed,
+		RSVPStatus:   database.RSVPGoing,
+		RegisteredAt: registeredAt,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := exporter.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "user_id,name,email,occurrence,status,rsvp_status,registered_at,checked_in_at\n" +
+		"7,'=1+1,ada@example.com,,confirmed,going,2025-05-01T12:00:00Z,\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
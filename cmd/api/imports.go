@@ -0,0 +1,670 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"rest-api-event-app/internal/database"
+	"rest-api-event-app/internal/ical"
+	"rest-api-event-app/internal/jobs"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+const (
+	maxImportFileSize = 10 << 20
+	maxImportRows     = 2000
+)
+
+const (
+	jobRunImport    = "imports.run"
+	jobPurgeImports = "imports.purge"
+)
+
+type importQuery struct {
+	DryRun bool   `form:"dry_run"`
+	Format string `form:"format" binding:"omitempty,oneof=csv ics"`
+}
+
+// importReport describes the outcome of a bulk import. Rows are numbered as
+// in the uploaded file: CSV rows by line, with the header on line 1, and
+// iCalendar events by the line of their BEGIN:VEVENT.
+type importReport struct {
+	DryRun    bool                 `json:"dry_run"`
+	Total     int                  `json:"total"`
+	Imported  int                  `json:"imported"`
+	Updated   int                  `json:"updated"`
+	Errors    []importRowError     `json:"errors"`
+	Events    []*database.Event    `json:"events,omitempty"`
+	Attendees []*database.Attendee `json:"attendees,omitempty"`
+}
+
+type importRowError struct {
+	Row   int    `json:"row"`
+	Error string `json:"error"`
+}
+
+// importRequest is what an import keeps of its file once it is validated:
+// the rows to save, and the line of the file each came from.
+type importRequest struct {
+	Lines         []int                   `json:"lines"`
+	Events        []importedEvent         `json:"events,omitempty"`
+	Registrations []database.Registration `json:"registrations,omitempty"`
+}
+
+// importedEvent carries the UID of an event, which Event leaves out of its
+// JSON.
+type importedEvent struct {
+	*database.Event
+	UID string `json:"uid,omitempty"`
+}
+
+// ImportEvents creates events from a CSV or iCalendar file
+//
+//	@Summary		Imports events from a CSV or iCalendar file
+//	@Description	Creates an event, owned by the authenticated user, for every row of a CSV file or every VEVENT of an iCalendar file. CSV files need a header with the columns name, description, starts_at, ends_at, timezone and location, and may add capacity, recurrence_rule, exception_dates (separated by ';') and uid. Times are RFC 3339, or local to the timezone column as YYYY-MM-DD HH:MM. An event with the UID of an event the user imported before updates that event instead of creating a copy, and is counted in updated; a UID may appear once per file. The file is validated right away: if any row is invalid, the errors of every row are reported with status 422 and nothing is imported. Otherwise the import is queued and its events are saved in the background, all in one transaction; poll the returned import for its report. If any event fails to save, none are saved and the import fails with the error in its report. A dry run inserts everything but rolls back.
+//	@Tags			import
+//	@Accept			multipart/form-data
+//	@Produce		json
+//	@Param			file	formData	file	true	"CSV or iCalendar file"
+//	@Param			format	query		string	false	"File format, detected from the file name when omitted"	Enums(csv, ics)
+//	@Param			dry_run	query		bool	false	"Validate without creating anything"
+//	@Success		202		{object}	database.Import
+//	@Failure		422		{object}	importReport
+//	@Router			/events/import [post]
+//	@Security		BearerAuth
+func (app *application) importEvents(c *gin.Context) {
+	var query importQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	file, format, ok := openImportFile(c, query.Format)
+	if !ok {
+		return
+	}
+
+	defer file.Close()
+
+	var rows []int
+	var events []*database.Event
+	var err error
+	if format == "ics" {
+		rows, events, err = readEventsICS(file)
+	} else {
+		rows, events, err = readEventsCSV(file)
+	}
+
+	report := &importReport{DryRun: query.DryRun, Errors: []importRowError{}}
+	if err != nil {
+		var rowErrs rowErrors
+		if !errors.As(err, &rowErrs) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		report.Errors = rowErrs
+	}
+
+	report.Total = len(events) + len(report.Errors)
+	if report.Total > maxImportRows {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("An import may contain at most %d rows", maxImportRows)})
+		return
+	}
+
+	user := app.GetUserFromContext(c)
+	uids := map[string]int{}
+	for i, event := range events {
+		event.OwnerId = user.ID
+
+		if len(event.ImportUID) > 255 {
+			report.Errors = append(report.Errors, importRowError{Row: rows[i], Error: "UID must be at most 255 characters"})
+			continue
+		}
+
+		if event.ImportUID != "" {
+			if row, ok := uids[event.ImportUID]; ok {
+				report.Errors = append(report.Errors, importRowError{Row: rows[i], Error: fmt.Sprintf("UID %q is already used on row %d", event.ImportUID, row)})
+				continue
+			}
+			uids[event.ImportUID] = rows[i]
+		}
+
+		if err := binding.Validator.ValidateStruct(event); err != nil {
+			report.Errors = append(report.Errors, importRowError{Row: rows[i], Error: err.Error()})
+			continue
+		}
+
+		if err := event.ValidateRecurrence(); err != nil {
+			report.Errors = append(report.Errors, importRowError{Row: rows[i], Error: err.Error()})
+		}
+	}
+
+	if len(report.Errors) > 0 {
+		sortImportErrors(report.Errors)
+		c.JSON(http.StatusUnprocessableEntity, report)
+		return
+	}
+
+	request := importRequest{Lines: rows, Events: make([]importedEvent, len(events))}
+	for i, event := range events {
+		request.Events[i] = importedEvent{Event: event, UID: event.ImportUID}
+	}
+
+	app.queueImport(c, &database.Import{UserId: user.ID, Kind: database.ImportKindEvents, DryRun: query.DryRun}, request)
+}
+
+// ImportAttendees registers users for an event from a CSV file
+//
+//	@Summary		Imports attendees from a CSV file
+//	@Description	Registers the users of a CSV file for an event, matched by the email column. An optional occurrence column registers for a single occurrence of a recurring event (RFC 3339). Registrations follow the same capacity and waitlist rules as adding attendees one by one, in file order. The file is validated right away: if any row names no user or an invalid occurrence, the errors of every row are reported with status 422 and nothing is imported. Otherwise the import is queued and the registrations are added in the background, all in one transaction; poll the returned import for its report. If any registration fails, none are added and the import fails with the error in its report. A dry run rolls back after every row went through.
+//	@Tags			import
+//	@Accept			multipart/form-data
+//	@Produce		json
+//	@Param			eventId	path		int		true	"Event ID"
+//	@Param			file	formData	file	true	"CSV file"
+//	@Param			dry_run	query		bool	false	"Validate without adding anyone"
+//	@Success		202		{object}	database.Import
+//	@Failure		422		{object}	importReport
+//	@Router			/events/{eventId}/attendees/import [post]
+//	@Security		BearerAuth
+func (app *application) importAttendees(c *gin.Context) {
+	eventId, err := strconv.Atoi(c.Param("eventId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event id"})
+		return
+	}
+
+	var query importQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	event, err := app.models.Events.GetEventById(eventId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve event"})
+		return
+	}
+
+	if event == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+
+	if !app.authorize(c, actionManageAttendees, event) {
+		return
+	}
+
+	file, _, ok := openImportFile(c, "csv")
+	if !ok {
+		return
+	}
+
+	defer file.Close()
+
+	header, records, lines, err := readCSV(file, "email")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if len(records) > maxImportRows {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("An import may contain at most %d rows", maxImportRows)})
+		return
+	}
+
+	report := &importReport{DryRun: query.DryRun, Total: len(records), Errors: []importRowError{}}
+
+	var rows []int
+	var registrations []database.Registration
+	for i, record := range records {
+		email := strings.TrimSpace(field(header, record, "email"))
+		if email == "" {
+			report.Errors = append(report.Errors, importRowError{Row: lines[i], Error: "email is required"})
+			continue
+		}
+
+		user, err := app.models.Users.GetUserByEmail(email)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve user"})
+			return
+		}
+
+		if user == nil {
+			report.Errors = append(report.Errors, importRowError{Row: lines[i], Error: "no user with email " + email})
+			continue
+		}
+
+		registration := database.Registration{UserId: user.ID}
+		if value := strings.TrimSpace(field(header, record, "occurrence")); value != "" {
+			occurrence, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				report.Errors = append(report.Errors, importRowError{Row: lines[i], Error: "invalid occurrence, expected an RFC 3339 time"})
+				continue
+			}
+
+			if !event.IsRecurring() || !event.IsOccurrence(occurrence) {
+				report.Errors = append(report.Errors, importRowError{Row: lines[i], Error: database.ErrInvalidOccurrence.Error()})
+				continue
+			}
+
+			occurrence = occurrence.UTC()
+			registration.Occurrence = &occurrence
+		}
+
+		rows = append(rows, lines[i])
+		registrations = append(registrations, registration)
+	}
+
+	if len(report.Errors) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, report)
+		return
+	}
+
+	user := app.GetUserFromContext(c)
+	app.queueImport(c, &database.Import{UserId: user.ID, EventId: &event.ID, Kind: database.ImportKindAttendees, DryRun: query.DryRun}, importRequest{Lines: rows, Registrations: registrations})
+}
+
+// queueImport saves a validated import and queues the job that runs it,
+// responding with the import.
+func (app *application) queueImport(c *gin.Context, imp *database.Import, request importRequest) {
+	var err error
+	if imp.Request, err = json.Marshal(request); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue import"})
+		return
+	}
+
+	imp, err = app.models.Imports.Insert(c, imp, app.jobs.NewJob(jobRunImport))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue import"})
+		return
+	}
+
+	app.jobs.Notify()
+
+	c.JSON(http.StatusAccepted, imp)
+}
+
+// GetImport returns a bulk import of the authenticated user
+//
+//	@Summary		Returns a bulk import
+//	@Description	Returns an import the authenticated user started. Its status is queued until it ran, then succeeded or failed. A finished import carries its report, with the events or attendees saved, or the row that failed to save. Finished imports are removed after a while.
+//	@Tags			import
+//	@Produce		json
+//	@Param			importId	path		int	true	"Import ID"
+//	@Success		200			{object}	database.Import
+//	@Router			/imports/{importId} [get]
+//	@Security		BearerAuth
+func (app *application) getImport(c *gin.Context) {
+	importId, err := strconv.Atoi(c.Param("importId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid import id"})
+		return
+	}
+
+	user := app.GetUserFromContext(c)
+
+	imp, err := app.models.Imports.GetByUser(c, user.ID, importId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve import"})
+		return
+	}
+
+	if imp == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Import not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, imp)
+}
+
+// runImport saves a queued import and finishes it with its report. Rows
+// that fail to save fail the import; anything else is retried, and fails
+// the import once the job runs out of attempts.
+func (app *application) runImport(ctx context.Context, job *database.Job) error {
+	var payload database.ImportJob
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return jobs.Permanent(err)
+	}
+
+	imp, err := app.models.Imports.Get(ctx, payload.ImportId)
+	if err != nil || imp == nil || imp.Status != database.ImportStatusQueued {
+		return err
+	}
+
+	var request importRequest
+	if err := json.Unmarshal(imp.Request, &request); err != nil {
+		return app.failImport(ctx, imp, nil, "Failed to read the import", jobs.Permanent(err))
+	}
+
+	report := &importReport{DryRun: imp.DryRun, Total: len(request.Lines), Errors: []importRowError{}}
+
+	var message string
+	switch imp.Kind {
+	case database.ImportKindEvents:
+		message = "Failed to save event"
+		events := make([]*database.Event, len(request.Events))
+		for i, imported := range request.Events {
+			events[i] = imported.Event
+			events[i].ImportUID = imported.UID
+		}
+
+		err = app.models.Events.ImportEvents(ctx, imp.ID, events, imp.DryRun, func(updated int) ([]byte, error) {
+			report.Imported = len(events)
+			report.Updated = updated
+			report.Events = events
+			return json.Marshal(report)
+		})
+	case database.ImportKindAttendees:
+		message = "Failed to register user"
+		if imp.EventId == nil {
+			return app.failImport(ctx, imp, nil, "Failed to read the import", jobs.Permanent(errors.New("attendee import without an event")))
+		}
+
+		err = app.models.Attendees.ImportRegistrations(ctx, imp.ID, *imp.EventId, request.Registrations, imp.DryRun, func(attendees []*database.Attendee) ([]byte, error) {
+			report.Imported = len(attendees)
+			report.Attendees = attendees
+			return json.Marshal(report)
+		})
+	default:
+		return app.failImport(ctx, imp, nil, "Failed to read the import", jobs.Permanent(fmt.Errorf("unknown import kind %q", imp.Kind)))
+	}
+
+	var rowErr *database.RowError
+	switch {
+	case err == nil, errors.Is(err, database.ErrImportFinished):
+		return nil
+	case errors.Is(err, database.ErrEventNotFound):
+		return app.failImport(ctx, imp, nil, "Event not found", nil)
+	case errors.As(err, &rowErr) && rowErr.Index < len(request.Lines):
+		if errors.Is(err, database.ErrAlreadyRegistered) {
+			message = "user is already registered for this event"
+		}
+		report.Errors = append(report.Errors, importRowError{Row: request.Lines[rowErr.Index], Error: message})
+		return app.failImport(ctx, imp, report, "", nil)
+	case job.Attempts >= job.MaxAttempts:
+		return app.failImport(ctx, imp, nil, "Failed to import "+imp.Kind, err)
+	default:
+		return err
+	}
+}
+
+// failImport finishes an import as failed and returns err, the outcome of
+// its job.
+func (app *application) failImport(ctx context.Context, imp *database.Import, report *importReport, reason string, err error) error {
+	var data []byte
+	if report != nil {
+		var marshalErr error
+		if data, marshalErr = json.Marshal(report); marshalErr != nil {
+			return marshalErr
+		}
+	}
+
+	failErr := app.models.Imports.Fail(ctx, imp.ID, data, reason, time.Now().UTC())
+	if failErr != nil && !errors.Is(failErr, database.ErrImportFinished) {
+		log.Printf("Recording the failure of import %d failed: %s", imp.ID, failErr)
+		if err == nil {
+			return failErr
+		}
+	}
+
+	return err
+}
+
+// purgeImports removes the imports that finished longer than the retention
+// period ago.
+func (app *application) purgeImports(ctx context.Context, job *database.Job) error {
+	_, err := app.models.Imports.DeleteFinished(ctx, time.Now().UTC().Add(-app.importRetention))
+	return err
+}
+
+// openImportFile opens the uploaded file of an import and determines its
+// format from the format parameter or else the file name. It responds with
+// 400 and returns false when there is no usable file.
+func openImportFile(c *gin.Context, format string) (multipart.File, string, bool) {
+	header, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A file is required"})
+		return nil, "", false
+	}
+
+	if header.Size > maxImportFileSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "The file is too large"})
+		return nil, "", false
+	}
+
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(header.Filename)) {
+		case ".csv":
+			format = "csv"
+		case ".ics", ".ical", ".ifb", ".icalendar":
+			format = "ics"
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown file format, pass format=csv or format=ics"})
+			return nil, "", false
+		}
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read the file"})
+		return nil, "", false
+	}
+
+	return file, format, true
+}
+
+// rowErrors is returned by the readers when some rows could not be parsed;
+// the rows that could are still returned alongside it.
+type rowErrors []importRowError
+
+func (e rowErrors) Error() string {
+	return fmt.Sprintf("%d rows could not be read", len(e))
+}
+
+func readEventsCSV(r io.Reader) ([]int, []*database.Event, error) {
+	header, records, lines, err := readCSV(r, "name", "description", "starts_at", "ends_at", "timezone", "location")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var rows []int
+	var events []*database.Event
+	var errs rowErrors
+	for i, record := range records {
+		event, err := eventFromCSV(header, record)
+		if err != nil {
+			errs = append(errs, importRowError{Row: lines[i], Error: err.Error()})
+			continue
+		}
+
+		rows = append(rows, lines[i])
+		events = append(events, event)
+	}
+
+	if len(errs) > 0 {
+		return rows, events, errs
+	}
+
+	return rows, events, nil
+}
+
+func eventFromCSV(header map[string]int, record []string) (*database.Event, error) {
+	event := &database.Event{
+		Name:           strings.TrimSpace(field(header, record, "name")),
+		Description:    strings.TrimSpace(field(header, record, "description")),
+		TimeZone:       strings.TrimSpace(field(header, record, "timezone")),
+		Location:       strings.TrimSpace(field(header, record, "location")),
+		RecurrenceRule: strings.TrimSpace(field(header, record, "recurrence_rule")),
+		ImportUID:      strings.TrimSpace(field(header, record, "uid")),
+	}
+
+	if event.TimeZone == "" {
+		event.TimeZone = "UTC"
+	}
+
+	loc, err := time.LoadLocation(event.TimeZone)
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone %q", event.TimeZone)
+	}
+
+	if event.StartsAt, err = parseImportTime(field(header, record, "starts_at"), loc); err != nil {
+		return nil, fmt.Errorf("starts_at: %w", err)
+	}
+
+	if event.EndsAt, err = parseImportTime(field(header, record, "ends_at"), loc); err != nil {
+		return nil, fmt.Errorf("ends_at: %w", err)
+	}
+
+	if value := strings.TrimSpace(field(header, record, "capacity")); value != "" {
+		if event.Capacity, err = strconv.Atoi(value); err != nil {
+			return nil, fmt.Errorf("capacity: invalid number %q", value)
+		}
+	}
+
+	for _, value := range strings.Split(field(header, record, "exception_dates"), ";") {
+		if strings.TrimSpace(value) == "" {
+			continue
+		}
+
+		exdate, err := parseImportTime(value, loc)
+		if err != nil {
+			return nil, fmt.Errorf("exception_dates: %w", err)
+		}
+		event.ExceptionDates = append(event.ExceptionDates, exdate)
+	}
+
+	return event, nil
+}
+
+var importTimeLayouts = []string{"2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02T15:04:05", "2006-01-02T15:04"}
+
+// parseImportTime parses an RFC 3339 time, or a local time in loc as written
+// by spreadsheets.
+func parseImportTime(value string, loc *time.Location) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, errors.New("is required")
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	for _, layout := range importTimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid time %q, expected RFC 3339 or YYYY-MM-DD HH:MM", value)
+}
+
+func readEventsICS(r io.Reader) ([]int, []*database.Event, error) {
+	decoded, parseErrs, err := ical.Decode(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var rows []int
+	var events []*database.Event
+	for _, event := range decoded {
+		rows = append(rows, event.Line)
+		events = append(events, &database.Event{
+			Name:           event.Summary,
+			Description:    event.Description,
+			StartsAt:       event.Start,
+			EndsAt:         event.End,
+			TimeZone:       event.TimeZone,
+			Location:       event.Location,
+			RecurrenceRule: event.RRule,
+			ExceptionDates: event.ExDates,
+			ImportUID:      event.UID,
+		})
+	}
+
+	if len(parseErrs) > 0 {
+		errs := make(rowErrors, len(parseErrs))
+		for i, parseErr := range parseErrs {
+			errs[i] = importRowError{Row: parseErr.Line, Error: parseErr.Err.Error()}
+		}
+		return rows, events, errs
+	}
+
+	return rows, events, nil
+}
+
+// readCSV reads a CSV file with a header row, which must name every required
+// column. It returns the column index of every header, the records and the
+// line each record starts on.
+func readCSV(r io.Reader, required ...string) (map[string]int, [][]string, []int, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	names, err := reader.Read()
+	if err != nil {
+		if err == io.EOF {
+			return nil, nil, nil, errors.New("the file is empty")
+		}
+		return nil, nil, nil, err
+	}
+
+	header := map[string]int{}
+	for i, name := range names {
+		// Spreadsheet apps often start UTF-8 files with a byte order mark.
+		name = strings.TrimPrefix(name, "\ufeff")
+		header[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	for _, name := range required {
+		if _, ok := header[name]; !ok {
+			return nil, nil, nil, fmt.Errorf("missing column %q", name)
+		}
+	}
+
+	var records [][]string
+	var lines []int
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		line, _ := reader.FieldPos(0)
+		records = append(records, record)
+		lines = append(lines, line)
+	}
+
+	return header, records, lines, nil
+}
+
+func field(header map[string]int, record []string, name string) string {
+	i, ok := header[name]
+	if !ok || i >= len(record) {
+		return ""
+	}
+
+	return record[i]
+}
+
+func sortImportErrors(errs []importRowError) {
+	sort.SliceStable(errs, func(i, j int) bool {
+		return errs[i].Row < errs[j].Row
+	})
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"rest-api-event-app/internal/database"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// registerJobs registers the handler of every kind of background job.
+func (app *application) registerJobs() {
+	app.jobs.Handle(jobSendReminder, app.sendReminder)
+	app.jobs.Handle(jobDeliverWebhook, app.deliverWebhook)
+	app.jobs.Handle(jobRunImport, app.runImport)
+	app.jobs.Handle(jobPurgeImports, app.purgeImports)
+	app.jobs.Handle(jobRunExport, app.runExport)
+	app.jobs.Handle(jobPurgeExports, app.purgeExports)
+	app.jobs.Handle(jobPurgeRevokedTokens, app.purgeRevokedTokens)
+	app.jobs.Handle(jobSendPasswordReset, app.sendPasswordReset)
+
+	app.jobs.Every(jobPurgeImports, time.Hour)
+	app.jobs.Every(jobPurgeExports, time.Hour)
+	app.jobs.Every(jobPurgeRevokedTokens, time.Hour)
+
+	// Without a provider no payment can be pending, nor be looked up.
+	if app.paymentProvider != nil {
+		app.jobs.Handle(jobReconcilePayments, app.reconcilePayments)
+		app.jobs.Every(jobReconcilePayments, app.paymentReconcile)
+	}
+}
+
+type listJobsQuery struct {
+	Kind   string `form:"kind"`
+	Status string `form:"status" binding:"omitempty,oneof=queued running succeeded dead"`
+	Limit  int    `form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+// GetJobs returns background jobs
+//
+//	@Summary		Returns background jobs
+//	@Description	Returns background jobs, newest first, with their attempts and the error of the latest failed one. Dead jobs ran out of attempts, or failed in a way a retry cannot fix. Only admins may call this endpoint.
+//	@Tags			admin
+//	@Produce		json
+//	@Param			kind	query	string	false	"Only jobs of this kind"
+//	@Param			status	query	string	false	"Only jobs in this status"	Enums(queued, running, succeeded, dead)
+//	@Param			limit	query	int		false	"Maximum number of jobs (max 100, default 50)"
+//	@Success		200		{array}	database.Job
+//	@Router			/admin/jobs [get]
+//	@Security		BearerAuth
+func (app *application) getJobs(c *gin.Context) {
+	var query listJobsQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if query.Limit == 0 {
+		query.Limit = 50
+	}
+
+	jobs, err := app.models.Jobs.List(c, query.Kind, query.Status, query.Limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve jobs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, jobs)
+}
+
+// RequeueJob runs a dead background job again
+//
+//	@Summary		Runs a dead background job again
+//	@Description	Queues a dead job to run right away with a fresh set of attempts. Only admins may call this endpoint.
+//	@Tags			admin
+//	@Produce		json
+//	@Param			jobId	path		int	true	"Job ID"
+//	@Success		200		{object}	database.Job
+//	@Router			/admin/jobs/{jobId}/requeue [post]
+//	@Security		BearerAuth
+func (app *application) requeueJob(c *gin.Context) {
+	jobId, err := strconv.ParseInt(c.Param("jobId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid job id"})
+		return
+	}
+
+	job, err := app.models.Jobs.Requeue(c, jobId, time.Now().UTC())
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrJobNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		case errors.Is(err, database.ErrJobNotDead):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to requeue job"})
+		}
+		return
+	}
+
+	app.jobs.Notify()
+
+	c.JSON(http.StatusOK, job)
+}
@@ -1,14 +1,29 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
-	"rest-api-event-app/cmd/migrate"
+	"os"
+	"rest-api-event-app/cmd/migrate/migrations"
 	_ "rest-api-event-app/docs"
 	"rest-api-event-app/internal/database"
 	"rest-api-event-app/internal/env"
+	"rest-api-event-app/internal/jobs"
+	"rest-api-event-app/internal/mailer"
+	"rest-api-event-app/internal/notify"
+	"rest-api-event-app/internal/payments"
+	"rest-api-event-app/internal/webhooks"
+	"slices"
+	"strings"
+	"time"
+	_ "time/tzdata"
 
 	_ "github.com/go-sql-driver/mysql"
 
+	"github.com/golang-migrate/migrate/v4"
 	"github.com/joho/godotenv"
 )
 
@@ -22,31 +37,240 @@ import (
 // @name Authorization
 
 type application struct {
-	port      int
-	jwtSecret string
-	models    database.Models
+	port             int
+	jwtSecret        string
+	ticketSecret     []byte
+	checkInLead      time.Duration
+	appURL           string
+	accessTokenTTL   time.Duration
+	refreshTokenTTL  time.Duration
+	passwordResetTTL time.Duration
+	verificationTTL  time.Duration
+	importRetention  time.Duration
+	exportDir        string
+	exportRetention  time.Duration
+	models           database.Models
+	mailer           mailer.Mailer
+	paymentProvider  payments.PaymentProvider
+	paymentHold      time.Duration
+	paymentReconcile time.Duration
+	webhooks         webhookDispatcher
+	outbox           outboxDispatcher
+	jobs             *jobs.Pool
+	notifiers        map[string]notify.Notifier
+	reminders        reminderScheduler
 }
 
 func main() {
+	autoMigrate := flag.Bool("migrate", false, "apply pending database migrations before starting the server")
+	flag.Parse()
+
 	if err := godotenv.Load(); err != nil {
 		log.Fatal("Error loading .env file")
 	}
 
-	dbConn, err := migrate.NewDatabase()
+	// The ticket secret signs tickets and check-in manifests. There is no
+	// default: one that ships with the source would let anybody forge tickets.
+	ticketSecret := env.GetEnvString("TICKET_SECRET", "")
+	if ticketSecret == "" {
+		log.Fatal("TICKET_SECRET must be set")
+	}
+
+	dbConn, err := database.NewDatabase()
 	if err != nil {
 		log.Fatalf("Could not initialize database connection: %s", err)
 	}
 
 	defer dbConn.CloseDB()
 
+	if *autoMigrate {
+		if err := migrateUp(dbConn); err != nil {
+			log.Fatalf("Could not apply migrations: %s", err)
+		}
+	}
+
+	mail, err := newMailer()
+	if err != nil {
+		log.Fatalf("Could not initialize mailer: %s", err)
+	}
+
+	paymentProvider, err := newPaymentProvider()
+	if err != nil {
+		log.Fatalf("Could not initialize payment provider: %s", err)
+	}
+
 	models := database.NewModels(dbConn.GetDB())
+
+	if paymentProvider == nil {
+		paid, err := models.TicketTypes.HasPaid(context.Background())
+		if err != nil {
+			log.Fatalf("Could not check for paid ticket types: %s", err)
+		}
+		if paid {
+			log.Fatal("PAYMENT_PROVIDER must be set while events have paid ticket types")
+		}
+	}
+
+	notifiers, err := newNotifiers(mail, &models)
+	if err != nil {
+		log.Fatalf("Could not initialize notifiers: %s", err)
+	}
+
+	jwtSecret := env.GetEnvString("JWT_SECRET", "some-secret-150902")
 	app := &application{
-		port:      env.GetEnvInt("PORT", 8080),
-		jwtSecret: env.GetEnvString("JWT_SECRET", "some-secret-150902"),
-		models:    models,
+		port:             env.GetEnvInt("PORT", 8080),
+		jwtSecret:        jwtSecret,
+		ticketSecret:     []byte(ticketSecret),
+		checkInLead:      env.GetEnvDuration("CHECK_IN_OPENS_BEFORE", 2*time.Hour),
+		appURL:           env.GetEnvString("APP_URL", "http://localhost:8080"),
+		accessTokenTTL:   env.GetEnvDuration("ACCESS_TOKEN_TTL", 15*time.Minute),
+		refreshTokenTTL:  env.GetEnvDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour),
+		passwordResetTTL: env.GetEnvDuration("PASSWORD_RESET_TTL", time.Hour),
+		verificationTTL:  env.GetEnvDuration("EMAIL_VERIFICATION_TTL", 48*time.Hour),
+		importRetention:  env.GetEnvDuration("IMPORT_RETENTION", 7*24*time.Hour),
+		exportDir:        env.GetEnvString("EXPORT_DIR", "tmp/exports"),
+		exportRetention:  env.GetEnvDuration("EXPORT_RETENTION", 24*time.Hour),
+		models:           models,
+		mailer:           mail,
+		paymentProvider:  paymentProvider,
+		paymentHold:      env.GetEnvDuration("PAYMENT_HOLD", 15*time.Minute),
+		paymentReconcile: env.GetEnvDuration("PAYMENT_RECONCILE_INTERVAL", time.Minute),
+		webhooks:         newWebhookDispatcher(),
+		outbox: outboxDispatcher{
+			pollInterval: env.GetEnvDuration("OUTBOX_POLL_INTERVAL", time.Second),
+			batchSize:    env.GetEnvInt("OUTBOX_BATCH_SIZE", 100),
+			backoff:      env.GetEnvDuration("OUTBOX_BACKOFF", 5*time.Second),
+			maxBackoff:   env.GetEnvDuration("OUTBOX_MAX_BACKOFF", 10*time.Minute),
+			retention:    env.GetEnvDuration("OUTBOX_RETENTION", 7*24*time.Hour),
+		},
+		jobs: jobs.NewPool(&models.Jobs, jobs.Config{
+			Workers:      env.GetEnvInt("JOB_WORKERS", 4),
+			Lease:        env.GetEnvDuration("JOB_LEASE", 5*time.Minute),
+			PollInterval: env.GetEnvDuration("JOB_POLL_INTERVAL", time.Second),
+			Backoff:      env.GetEnvDuration("JOB_BACKOFF", 10*time.Second),
+			MaxBackoff:   env.GetEnvDuration("JOB_MAX_BACKOFF", time.Hour),
+			MaxAttempts:  env.GetEnvInt("JOB_MAX_ATTEMPTS", 10),
+			DrainTimeout: env.GetEnvDuration("JOB_DRAIN_TIMEOUT", 30*time.Second),
+			Retention:    env.GetEnvDuration("JOB_RETENTION", 7*24*time.Hour),
+		}),
+		notifiers: notifiers,
+		reminders: reminderScheduler{
+			offsets:  reminderOffsets(env.GetEnvDurations("REMINDER_OFFSETS", []time.Duration{24 * time.Hour, time.Hour})),
+			interval: env.GetEnvDuration("REMINDER_SCAN_INTERVAL", time.Minute),
+		},
+	}
+
+	// Export files are written by whichever server runs the job and served
+	// by whichever gets the download, so servers have to share EXPORT_DIR.
+	if err := os.MkdirAll(app.exportDir, 0o755); err != nil {
+		log.Fatalf("Could not create export directory: %s", err)
 	}
 
+	app.registerJobs()
+
 	if err := app.serve(); err != nil {
 		log.Fatal(err)
 	}
 }
+
+func newMailer() (mailer.Mailer, error) {
+	switch driver := env.GetEnvString("MAILER", "file"); driver {
+	case "smtp":
+		return &mailer.SMTPMailer{
+			Addr:     env.GetEnvString("SMTP_ADDR", "localhost:25"),
+			Username: env.GetEnvString("SMTP_USERNAME", ""),
+			Password: env.GetEnvString("SMTP_PASSWORD", ""),
+			From:     env.GetEnvString("MAIL_FROM", "no-reply@localhost"),
+		}, nil
+	case "file":
+		return mailer.NewFileOutbox(env.GetEnvString("MAIL_OUTBOX_DIR", "tmp/outbox"))
+	case "memory":
+		return mailer.NewMemoryOutbox(), nil
+	default:
+		return nil, fmt.Errorf("unknown mailer %q", driver)
+	}
+}
+
+// newPaymentProvider returns the provider named by PAYMENT_PROVIDER, or nil
+// when none is set, in which case only free tickets can be sold. The fake
+// provider takes every payment without charging anyone, so it has to be
+// asked for by name.
+func newPaymentProvider() (payments.PaymentProvider, error) {
+	switch provider := env.GetEnvString("PAYMENT_PROVIDER", ""); provider {
+	case "":
+		return nil, nil
+	case "fake":
+		log.Print("PAYMENT_PROVIDER is fake: paid tickets are confirmed without charging anyone")
+		return payments.NewFakeProvider(), nil
+	default:
+		return nil, fmt.Errorf("unknown payment provider %q", provider)
+	}
+}
+
+// newNotifiers returns the channels notifications are sent through, keyed
+// by name, as listed in NOTIFY_CHANNELS.
+func newNotifiers(mail mailer.Mailer, models *database.Models) (map[string]notify.Notifier, error) {
+	notifiers := map[string]notify.Notifier{}
+	for _, channel := range strings.Split(env.GetEnvString("NOTIFY_CHANNELS", "email,in_app"), ",") {
+		switch channel = strings.TrimSpace(channel); channel {
+		case "":
+		case notify.ChannelEmail:
+			notifiers[channel] = &notify.EmailNotifier{Mailer: mail}
+		case notify.ChannelInApp:
+			notifiers[channel] = &notify.InAppNotifier{Notifications: &models.Notifications}
+		default:
+			return nil, fmt.Errorf("unknown notification channel %q", channel)
+		}
+	}
+
+	return notifiers, nil
+}
+
+// reminderOffsets sorts reminder offsets longest first, dropping duplicates
+// and those under a minute.
+func reminderOffsets(offsets []time.Duration) []time.Duration {
+	offsets = slices.DeleteFunc(slices.Clone(offsets), func(offset time.Duration) bool {
+		return offset < time.Minute
+	})
+	for i := range offsets {
+		offsets[i] = offsets[i].Truncate(time.Minute)
+	}
+
+	slices.Sort(offsets)
+	slices.Reverse(offsets)
+
+	return slices.Compact(offsets)
+}
+
+func newWebhookDispatcher() webhookDispatcher {
+	timeout := env.GetEnvDuration("WEBHOOK_TIMEOUT", 10*time.Second)
+
+	return webhookDispatcher{
+		sender: &webhooks.Sender{
+			Client:    webhooks.NewClient(timeout),
+			UserAgent: "rest-api-event-app-webhooks/1.0",
+		},
+		timeout:     timeout,
+		maxAttempts: env.GetEnvInt("WEBHOOK_MAX_ATTEMPTS", 8),
+		backoff:     env.GetEnvDuration("WEBHOOK_BACKOFF", 30*time.Second),
+		maxBackoff:  env.GetEnvDuration("WEBHOOK_MAX_BACKOFF", 6*time.Hour),
+	}
+}
+
+func migrateUp(dbConn *database.Database) error {
+	m, err := migrations.New(dbConn.GetDB())
+	if err != nil {
+		return err
+	}
+
+	defer m.Close()
+
+	if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return err
+	}
+
+	version, _, _ := m.Version()
+	log.Printf("Database migrated to version %d", version)
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"rest-api-event-app/internal/database"
+	"rest-api-event-app/internal/testdb"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Tests that need MySQL get it from testdb.Open; see package testdb.
+
+func createTestUser(t *testing.T, models *database.Models) *database.User {
+	t.Helper()
+
+	name := testdb.UniqueName("user")
+
+	user, err := models.Users.InsertUser(context.Background(), &database.User{Email: name + "@example.com", Name: name, Password: "x"})
+	if err != nil {
+		t.Fatalf("creating user: %s", err)
+	}
+
+	return user
+}
+
+func createTestEvent(t *testing.T, models *database.Models, ownerId, capacity int) *database.Event {
+	t.Helper()
+
+	start := time.Now().UTC().Add(24 * time.Hour).Truncate(time.Second)
+
+	event, err := models.Events.InsertEvent(context.Background(), &database.Event{
+		OwnerId:     ownerId,
+		Name:        testdb.UniqueName("event"),
+		Description: "An event created by a test",
+		StartsAt:    start,
+		EndsAt:      start.Add(2 * time.Hour),
+		TimeZone:    "UTC",
+		Location:    "Test hall",
+		Capacity:    capacity,
+	})
+	if err != nil {
+		t.Fatalf("creating event: %s", err)
+	}
+
+	return event
+}
+
+// serveAs runs handler for a request made by user, with the given path
+// parameters and JSON body, and returns the response.
+func serveAs(user *database.User, handler gin.HandlerFunc, params gin.Params, body string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Params = params
+	c.Set("user", user)
+
+	handler(c)
+
+	return w
+}
@@ -1,7 +1,6 @@
 package main
 
 import (
-	"log"
 	"net/http"
 	"strconv"
 	"strings"
@@ -35,24 +34,68 @@ func (app *application) AuthMiddleware() gin.HandlerFunc {
 			return []byte(app.jwtSecret), nil
 		})
 
-		if err != nil || !token.Valid {
+		if err != nil || !token.Valid || claims.RegisteredClaims.ID == "" || claims.ExpiresAt == nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token invalid"})
 			c.Abort()
 			return
 		}
 
-		log.Println("claims :", claims)
+		revoked, err := app.models.RevokedTokens.IsRevoked(c, claims.RegisteredClaims.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Something went wrong"})
+			c.Abort()
+			return
+		}
+
+		if revoked {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token has been revoked"})
+			c.Abort()
+			return
+		}
 
 		userId, _ := strconv.Atoi(claims.ID)
 
 		user, err := app.models.Users.GetUserById(userId)
-		if err != nil {
+		if err != nil || user == nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized access"})
 			c.Abort()
 			return
 		}
 
 		c.Set("user", user)
+		c.Set("claims", claims)
 		c.Next()
 	}
 }
+
+// RequireVerified rejects users who have not verified their email address
+// yet. It must run after AuthMiddleware.
+func (app *application) RequireVerified() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := app.GetUserFromContext(c)
+		if !user.Verified {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Email address must be verified first"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireRole only lets users with one of the given roles through. It must
+// run after AuthMiddleware.
+func (app *application) RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := app.GetUserFromContext(c)
+		for _, role := range roles {
+			if user.Role == role {
+				c.Next()
+				return
+			}
+		}
+
+		c.JSON(http.StatusForbidden, gin.H{"error": "You are not authorized to access this resource"})
+		c.Abort()
+	}
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"rest-api-event-app/internal/database"
+	"rest-api-event-app/internal/notify"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type listNotificationsQuery struct {
+	Unread bool  `form:"unread"`
+	Before int64 `form:"before" binding:"omitempty,min=1"`
+	Limit  int   `form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+type notificationsResponse struct {
+	UnreadCount   int                      `json:"unread_count"`
+	Notifications []*database.Notification `json:"notifications"`
+	NextBefore    int64                    `json:"next_before,omitempty"`
+}
+
+type markAllNotificationsReadResponse struct {
+	Marked int `json:"marked"`
+}
+
+// GetNotifications returns the authenticated user's notifications
+//
+//	@Summary		Returns the authenticated user's notifications
+//	@Description	Returns the authenticated user's in-app notifications, newest first, together with how many are unread. Pass next_before of a response as before to get the next page.
+//	@Tags			notifications
+//	@Produce		json
+//	@Param			unread	query		bool	false	"Only unread notifications"
+//	@Param			before	query		int		false	"Only notifications older than this notification ID"
+//	@Param			limit	query		int		false	"Maximum number of notifications (max 100, default 20)"
+//	@Success		200		{object}	notificationsResponse
+//	@Router			/me/notifications [get]
+//	@Security		BearerAuth
+func (app *application) getNotifications(c *gin.Context) {
+	var query listNotificationsQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if query.Limit == 0 {
+		query.Limit = 20
+	}
+
+	user := app.GetUserFromContext(c)
+
+	notifications, err := app.models.Notifications.GetByUser(c, user.ID, query.Unread, query.Before, query.Limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve notifications"})
+		return
+	}
+
+	unread, err := app.models.Notifications.CountUnread(c, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve notifications"})
+		return
+	}
+
+	response := notificationsResponse{UnreadCount: unread, Notifications: notifications}
+	if len(notifications) == query.Limit {
+		response.NextBefore = notifications[len(notifications)-1].ID
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// MarkNotificationRead marks a notification of the authenticated user as read
+//
+//	@Summary		Marks a notification as read
+//	@Description	Marks a notification of the authenticated user as read. Marking a notification that is already read keeps the time it was first read.
+//	@Tags			notifications
+//	@Produce		json
+//	@Param			notificationId	path		int	true	"Notification ID"
+//	@Success		200				{object}	database.Notification
+//	@Router			/me/notifications/{notificationId}/read [post]
+//	@Security		BearerAuth
+func (app *application) markNotificationRead(c *gin.Context) {
+	notificationId, err := strconv.ParseInt(c.Param("notificationId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid notification id"})
+		return
+	}
+
+	user := app.GetUserFromContext(c)
+
+	notification, err := app.models.Notifications.MarkRead(c, user.ID, notificationId, time.Now().UTC())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mark notification as read"})
+		return
+	}
+
+	if notification == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, notification)
+}
+
+// MarkAllNotificationsRead marks every notification of the authenticated user as read
+//
+//	@Summary		Marks every notification as read
+//	@Description	Marks every unread notification of the authenticated user as read and returns how many there were
+//	@Tags			notifications
+//	@Produce		json
+//	@Success		200	{object}	markAllNotificationsReadResponse
+//	@Router			/me/notifications/read-all [post]
+//	@Security		BearerAuth
+func (app *application) markAllNotificationsRead(c *gin.Context) {
+	user := app.GetUserFromContext(c)
+
+	marked, err := app.models.Notifications.MarkAllRead(c, user.ID, time.Now().UTC())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mark notifications as read"})
+		return
+	}
+
+	c.JSON(http.StatusOK, markAllNotificationsReadResponse{Marked: marked})
+}
+
+// notifyEventChange tells the attendees of an event that it was
+// rescheduled, moved, updated or cancelled. The owner of the event made the
+// change and is left out. The notifications are keyed by the outbox
+// message, so a message handled twice notifies nobody twice.
+func (app *application) notifyEventChange(ctx context.Context, message *database.OutboxMessage, change database.EventChange) error {
+	if change.Event == nil {
+		return nil
+	}
+
+	event := change.Event
+	event.Localize()
+
+	notification := database.Notification{DedupeKey: fmt.Sprintf("outbox:%d", message.ID)}
+
+	switch message.Topic {
+	case database.TopicEventUpdated:
+		if change.Previous == nil {
+			return nil
+		}
+
+		previous := change.Previous
+		previous.Localize()
+
+		switch {
+		case rescheduled(previous, event):
+			notification.Kind = notify.KindEventRescheduled
+			notification.Title = fmt.Sprintf("Your event was rescheduled: %s", event.Name)
+			notification.Body = fmt.Sprintf("%s now starts on %s (%s) at %s.", event.Name, event.StartsAt.Format("Mon, 2 Jan 2006 15:04"), event.TimeZone, event.Location)
+		case previous.Location != event.Location:
+			notification.Kind = notify.KindEventMoved
+			notification.Title = fmt.Sprintf("Your event has moved: %s", event.Name)
+			notification.Body = fmt.Sprintf("%s now takes place at %s.", event.Name, event.Location)
+		case previous.Name != event.Name || previous.Description != event.Description:
+			notification.Kind = notify.KindEventUpdated
+			notification.Title = fmt.Sprintf("Your event was updated: %s", event.Name)
+			notification.Body = fmt.Sprintf("The organizer updated the details of %s.", event.Name)
+		default:
+			// Nothing attendees need to know about, such as a new capacity.
+			return nil
+		}
+
+		notification.EventId = &event.ID
+	case database.TopicEventDeleted:
+		notification.Kind = notify.KindEventCancelled
+		notification.Title = fmt.Sprintf("Your event was cancelled: %s", event.Name)
+		notification.Body = fmt.Sprintf("The organizer cancelled %s, which was to start on %s (%s).", event.Name, event.StartsAt.Format("Mon, 2 Jan 2006 15:04"), event.TimeZone)
+	default:
+		return nil
+	}
+
+	userIds := slices.DeleteFunc(change.UserIds, func(userId int) bool {
+		return userId == message.OwnerId
+	})
+
+	return app.models.Notifications.InsertForUsers(ctx, userIds, notification)
+}
+
+// notifyAttendeeChange tells a user who was added to an event by an
+// organizer about it. Users who register or buy a ticket themselves already
+// know.
+func (app *application) notifyAttendeeChange(ctx context.Context, message *database.OutboxMessage, change database.AttendeeChange) error {
+	attendee := change.Attendee
+	if message.Topic != database.TopicAttendeeAdded || !change.ByOrganizer || attendee == nil || attendee.UserId == message.OwnerId {
+		return nil
+	}
+
+	event, err := app.models.Events.GetEventById(attendee.EventId)
+	if err != nil {
+		return err
+	}
+
+	// The event was deleted since, and its attendees told so.
+	if event == nil {
+		return nil
+	}
+
+	start := event.StartsAt
+	if attendee.Occurrence != nil {
+		start = attendee.Occurrence.In(event.StartsAt.Location())
+	}
+
+	notification := &database.Notification{
+		UserId:    attendee.UserId,
+		Kind:      notify.KindAttendeeAdded,
+		Title:     fmt.Sprintf("You were added to %s", event.Name),
+		Body:      fmt.Sprintf("You were added to %s, starting on %s (%s) at %s.", event.Name, start.Format("Mon, 2 Jan 2006 15:04"), event.TimeZone, event.Location),
+		EventId:   &event.ID,
+		DedupeKey: fmt.Sprintf("outbox:%d", message.ID),
+	}
+
+	if attendee.Status == database.AttendeeStatusWaitlisted {
+		notification.Title = fmt.Sprintf("You were added to the waitlist of %s", event.Name)
+		notification.Body = fmt.Sprintf("You were added to the waitlist of %s, starting on %s (%s) at %s. The event is full for now.", event.Name, start.Format("Mon, 2 Jan 2006 15:04"), event.TimeZone, event.Location)
+	}
+
+	_, err = app.models.Notifications.Insert(ctx, notification)
+	return err
+}
+
+// rescheduled reports whether an update changed when an event takes place.
+func rescheduled(previous, event *database.Event) bool {
+	return !previous.StartsAt.Equal(event.StartsAt) ||
+		!previous.EndsAt.Equal(event.EndsAt) ||
+		previous.TimeZone != event.TimeZone ||
+		previous.RecurrenceRule != event.RecurrenceRule ||
+		!slices.EqualFunc(previous.ExceptionDates, event.ExceptionDates, time.Time.Equal)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"net/http"
+	"rest-api-event-app/internal/database"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type occurrencesQuery struct {
+	From time.Time `form:"from" binding:"required" time_format:"2006-01-02"`
+	To   time.Time `form:"to" binding:"required,gtefield=From" time_format:"2006-01-02"`
+}
+
+// GetEventOccurrences returns the occurrences of an event in a date range
+//
+//	@Summary		Returns the occurrences of an event
+//	@Description	Expands the recurrence rule of an event into its occurrences between two UTC dates, skipping exception dates. Events that do not recur have a single occurrence.
+//	@Tags			events
+//	@Accept			json
+//	@Produce		json
+//	@Param			eventId	path	int		true	"Event ID"
+//	@Param			from	query	string	true	"First UTC date (YYYY-MM-DD)"
+//	@Param			to		query	string	true	"Last UTC date (YYYY-MM-DD)"
+//	@Success		200		{array}	database.Occurrence
+//	@Router			/events/{eventId}/occurrences [get]
+func (app *application) getEventOccurrences(c *gin.Context) {
+	eventId, err := strconv.Atoi(c.Param("eventId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event id"})
+		return
+	}
+
+	var query occurrencesQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	event, err := app.models.Events.GetEventById(eventId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve event"})
+		return
+	}
+
+	if event == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+
+	occurrences, err := event.Expand(query.From, query.To.AddDate(0, 0, 1))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to expand occurrences"})
+		return
+	}
+
+	c.JSON(http.StatusOK, occurrences)
+}
+
+// parseOccurrence reads the optional occurrence query parameter, the RFC 3339
+// start of a single occurrence. It responds with 400 and returns false when
+// the parameter is malformed.
+func parseOccurrence(c *gin.Context) (*time.Time, bool) {
+	value := c.Query("occurrence")
+	if value == "" {
+		return nil, true
+	}
+
+	occurrence, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid occurrence, expected an RFC 3339 time"})
+		return nil, false
+	}
+
+	occurrence = occurrence.UTC()
+	return &occurrence, true
+}
+
+// checkOccurrence responds with 400 and returns false unless occurrence is
+// nil, meaning the whole event, or the start of an occurrence of the event.
+func checkOccurrence(c *gin.Context, event *database.Event, occurrence *time.Time) bool {
+	if occurrence == nil {
+		return true
+	}
+
+	if !event.IsRecurring() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only recurring events have occurrences"})
+		return false
+	}
+
+	if !event.IsOccurrence(*occurrence) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": database.ErrInvalidOccurrence.Error()})
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"rest-api-event-app/internal/database"
+	"rest-api-event-app/internal/notify"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type organizerRequest struct {
+	UserId             int  `json:"user_id" binding:"required,min=1"`
+	CanEdit            bool `json:"can_edit"`
+	CanManageAttendees bool `json:"can_manage_attendees"`
+	CanCheckIn         bool `json:"can_check_in"`
+}
+
+// GetOrganizersForEvent returns the co-organizers of an event
+//
+//	@Summary		Returns the co-organizers of an event
+//	@Description	Returns the co-organizers of an event, with their permissions and whether they accepted their invitation. Only the owner, its co-organizers, moderators and admins may see them.
+//	@Tags			organizers
+//	@Accept			json
+//	@Produce		json
+//	@Param			eventId	path	int	true	"Event ID"
+//	@Success		200		{array}	database.Organizer
+//	@Router			/events/{eventId}/organizers [get]
+//	@Security		BearerAuth
+func (app *application) getOrganizersForEvent(c *gin.Context) {
+	eventId, err := strconv.Atoi(c.Param("eventId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event id"})
+		return
+	}
+
+	event, err := app.models.Events.GetEventById(eventId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve event"})
+		return
+	}
+
+	if event == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+
+	if !app.authorize(c, actionViewOrganizers, event) {
+		return
+	}
+
+	organizers, err := app.models.Organizers.GetByEvent(c, event.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve organizers"})
+		return
+	}
+
+	c.JSON(http.StatusOK, organizers)
+}
+
+// AddOrganizerToEvent invites a co-organizer to an event
+//
+//	@Summary		Invites a co-organizer to an event
+//	@Description	Invites a user to co-organize an event, or updates the permissions of an existing co-organizer. Invited users are notified in the app and get their permissions once they accept the invitation.
+//	@Tags			organizers
+//	@Accept			json
+//	@Produce		json
+//	@Param			eventId		path		int					true	"Event ID"
+//	@Param			organizer	body		organizerRequest	true	"Organizer"
+//	@Success		200			{object}	database.Organizer	"Permissions updated"
+//	@Success		201			{object}	database.Organizer	"Invited"
+//	@Router			/events/{eventId}/organizers [post]
+//	@Security		BearerAuth
+func (app *application) addOrganizerToEvent(c *gin.Context) {
+	eventId, err := strconv.Atoi(c.Param("eventId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event id"})
+		return
+	}
+
+	var request organizerRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	event, err := app.models.Events.GetEventById(eventId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve event"})
+		return
+	}
+
+	if event == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+
+	if !app.authorize(c, actionManageOrganizers, event) {
+		return
+	}
+
+	if request.UserId == event.OwnerId {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The owner of an event cannot be a co-organizer"})
+		return
+	}
+
+	user, err := app.models.Users.GetUserById(request.UserId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve user"})
+		return
+	}
+
+	if user == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	organizer, invited, err := app.models.Organizers.Invite(c, &database.Organizer{
+		EventId:            event.ID,
+		UserId:             user.ID,
+		Name:               user.Name,
+		Email:              user.Email,
+		CanEdit:            request.CanEdit,
+		CanManageAttendees: request.CanManageAttendees,
+		CanCheckIn:         request.CanCheckIn,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to invite organizer"})
+		return
+	}
+
+	if !invited {
+		c.JSON(http.StatusOK, organizer)
+		return
+	}
+
+	// The invitation stands even if the notification fails; it is also
+	// listed under /me/organizer-invitations.
+	_, err = app.models.Notifications.Insert(c, &database.Notification{
+		UserId:  user.ID,
+		Kind:    notify.KindOrganizerInvited,
+		Title:   fmt.Sprintf("You were invited to co-organize %s", event.Name),
+		Body:    fmt.Sprintf("Accept the invitation to help run %s.", event.Name),
+		EventId: &event.ID,
+	})
+	if err != nil {
+		log.Printf("Notifying user %d of their invitation to event %d failed: %s", user.ID, event.ID, err)
+	}
+
+	c.JSON(http.StatusCreated, organizer)
+}
+
+// AcceptOrganizerInvitation accepts the authenticated user's invitation to co-organize an event
+//
+//	@Summary		Accepts an invitation to co-organize an event
+//	@Description	Accepts the authenticated user's invitation to co-organize an event, which grants the permissions it was sent with. Declining is done by removing oneself from the organizers.
+//	@Tags			organizers
+//	@Produce		json
+//	@Param			eventId	path		int	true	"Event ID"
+//	@Success		200		{object}	database.Organizer
+//	@Router			/events/{eventId}/organizers/accept [post]
+//	@Security		BearerAuth
+func (app *application) acceptOrganizerInvitation(c *gin.Context) {
+	eventId, err := strconv.Atoi(c.Param("eventId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event id"})
+		return
+	}
+
+	user := app.GetUserFromContext(c)
+
+	organizer, err := app.models.Organizers.Accept(c, eventId, user.ID, time.Now().UTC())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to accept invitation"})
+		return
+	}
+
+	if organizer == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invitation not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, organizer)
+}
+
+// GetOrganizerInvitations returns the authenticated user's pending invitations
+//
+//	@Summary		Returns the authenticated user's invitations to co-organize events
+//	@Description	Returns the invitations to co-organize events the authenticated user has not accepted yet, newest first
+//	@Tags			organizers
+//	@Produce		json
+//	@Success		200	{array}	database.Organizer
+//	@Router			/me/organizer-invitations [get]
+//	@Security		BearerAuth
+func (app *application) getOrganizerInvitations(c *gin.Context) {
+	user := app.GetUserFromContext(c)
+
+	invitations, err := app.models.Organizers.GetInvitationsByUser(c, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve invitations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, invitations)
+}
+
+// DeleteOrganizerFromEvent removes a co-organizer from an event
+//
+//	@Summary		Removes a co-organizer from an event
+//	@Description	Removes a co-organizer from an event, or withdraws their invitation. Co-organizers may remove themselves, which also declines a pending invitation.
+//	@Tags			organizers
+//	@Accept			json
+//	@Produce		json
+//	@Param			eventId	path	int	true	"Event ID"
+//	@Param			userId	path	int	true	"User ID"
+//	@Success		204		{string}	string	"No Content"
+//	@Router			/events/{eventId}/organizers/{userId} [delete]
+//	@Security		BearerAuth
+func (app *application) deleteOrganizerFromEvent(c *gin.Context) {
+	eventId, err := strconv.Atoi(c.Param("eventId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event id"})
+		return
+	}
+
+	userId, err := strconv.Atoi(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user id"})
+		return
+	}
+
+	event, err := app.models.Events.GetEventById(eventId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve event"})
+		return
+	}
+
+	if event == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+
+	// Co-organizers may step down themselves without needing the owner.
+	if app.GetUserFromContext(c).ID != userId && !app.authorize(c, actionManageOrganizers, event) {
+		return
+	}
+
+	deleted, err := app.models.Organizers.Delete(c, eventId, userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove organizer"})
+		return
+	}
+
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organizer not found"})
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
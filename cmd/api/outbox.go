@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"rest-api-event-app/internal/database"
+	"rest-api-event-app/internal/webhooks"
+	"time"
+)
+
+// outboxDispatcher publishes the messages the models write to the outbox.
+// Every message is published at least once: a message that fails is
+// retried with exponential backoff, from backoff up to maxBackoff, until it
+// goes through. Published messages are kept for retention.
+type outboxDispatcher struct {
+	pollInterval time.Duration
+	batchSize    int
+	backoff      time.Duration
+	maxBackoff   time.Duration
+	retention    time.Duration
+}
+
+// runOutboxDispatcher publishes pending outbox messages until ctx is
+// cancelled.
+func (app *application) runOutboxDispatcher(ctx context.Context) {
+	ticker := time.NewTicker(app.outbox.pollInterval)
+	defer ticker.Stop()
+
+	lastPurge := time.Time{}
+	for {
+		app.publishPendingMessages(ctx)
+
+		if time.Since(lastPurge) > time.Hour {
+			lastPurge = time.Now()
+			if _, err := app.models.Outbox.DeletePublished(ctx, time.Now().UTC().Add(-app.outbox.retention)); err != nil {
+				log.Printf("Purging published outbox messages failed: %s", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// publishPendingMessages publishes every message that is due, a batch at a
+// time, in the order they were written.
+func (app *application) publishPendingMessages(ctx context.Context) {
+	o := &app.outbox
+
+	for ctx.Err() == nil {
+		messages, err := app.models.Outbox.ClaimPending(ctx, time.Now().UTC(), o.batchSize, time.Minute)
+		if err != nil {
+			log.Printf("Claiming outbox messages failed: %s", err)
+			return
+		}
+
+		for _, message := range messages {
+			app.publishMessage(ctx, message)
+		}
+
+		if len(messages) < o.batchSize {
+			return
+		}
+	}
+}
+
+func (app *application) publishMessage(ctx context.Context, message *database.OutboxMessage) {
+	publishErr := app.handleMessage(ctx, message)
+
+	// The outcome is recorded even when shutting down, so the message is not
+	// published again needlessly.
+	ctx = context.WithoutCancel(ctx)
+	now := time.Now().UTC()
+
+	var err error
+	if publishErr == nil {
+		err = app.models.Outbox.MarkPublished(ctx, message.ID, now)
+	} else {
+		log.Printf("Publishing outbox message %d (%s) failed: %s", message.ID, message.Topic, publishErr)
+		next := now.Add(webhooks.Backoff(message.Attempts+1, app.outbox.backoff, app.outbox.maxBackoff))
+		err = app.models.Outbox.MarkFailed(ctx, message.ID, publishErr.Error(), next)
+	}
+
+	if err != nil {
+		log.Printf("Recording the outcome of outbox message %d failed: %s", message.ID, err)
+	}
+}
+
+// handleMessage hands a message to everything that reacts to its topic.
+// When one of them fails the message is retried as a whole, so each has to
+// cope with seeing a message more than once.
+func (app *application) handleMessage(ctx context.Context, message *database.OutboxMessage) error {
+	switch message.Topic {
+	case database.TopicEventCreated, database.TopicEventUpdated, database.TopicEventDeleted:
+		var change database.EventChange
+		if err := json.Unmarshal(message.Payload, &change); err != nil {
+			log.Printf("Dropping outbox message %d with malformed payload: %s", message.ID, err)
+			return nil
+		}
+
+		if err := app.publishWebhook(ctx, message, change.Event); err != nil {
+			return err
+		}
+
+		return app.notifyEventChange(ctx, message, change)
+	case database.TopicAttendeeAdded, database.TopicAttendeeRemoved:
+		var change database.AttendeeChange
+		if err := json.Unmarshal(message.Payload, &change); err != nil {
+			log.Printf("Dropping outbox message %d with malformed payload: %s", message.ID, err)
+			return nil
+		}
+
+		if err := app.publishWebhook(ctx, message, change.Attendee); err != nil {
+			return err
+		}
+
+		return app.notifyAttendeeChange(ctx, message, change)
+	default:
+		log.Printf("Dropping outbox message %d with unknown topic %q", message.ID, message.Topic)
+		return nil
+	}
+}
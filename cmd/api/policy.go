@@ -0,0 +1,81 @@
+package main
+
+import (
+	"net/http"
+	"rest-api-event-app/internal/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+type eventAction string
+
+const (
+	actionUpdateEvent      eventAction = "update"
+	actionDeleteEvent      eventAction = "delete"
+	actionManageAttendees  eventAction = "manage attendees for"
+	actionCheckIn          eventAction = "check in guests for"
+	actionManageOrganizers eventAction = "manage organizers for"
+	actionViewOrganizers   eventAction = "view the organizers of"
+)
+
+// roleEventActions lists what each role may do on events it does not own.
+// Owners may always perform every action on their own events.
+var roleEventActions = map[string][]eventAction{
+	database.RoleAdmin:     {actionUpdateEvent, actionDeleteEvent, actionManageAttendees, actionCheckIn, actionManageOrganizers, actionViewOrganizers},
+	database.RoleModerator: {actionUpdateEvent, actionDeleteEvent, actionManageAttendees, actionCheckIn, actionViewOrganizers},
+}
+
+// can reports whether the user may perform the action on the event, either as
+// its owner, through their role or as a co-organizer with the matching
+// permission. Co-organizers who have not accepted their invitation yet hold
+// no permissions.
+func (app *application) can(c *gin.Context, user *database.User, action eventAction, event *database.Event) (bool, error) {
+	if user.ID == 0 {
+		return false, nil
+	}
+
+	if event.OwnerId == user.ID {
+		return true, nil
+	}
+
+	for _, allowed := range roleEventActions[user.Role] {
+		if allowed == action {
+			return true, nil
+		}
+	}
+
+	organizer, err := app.models.Organizers.Get(c, event.ID, user.ID)
+	if err != nil || organizer == nil || !organizer.Accepted() {
+		return false, err
+	}
+
+	switch action {
+	case actionViewOrganizers:
+		return true, nil
+	case actionUpdateEvent:
+		return organizer.CanEdit, nil
+	case actionManageAttendees:
+		return organizer.CanManageAttendees, nil
+	case actionCheckIn:
+		return organizer.CanCheckIn, nil
+	}
+
+	return false, nil
+}
+
+// authorize responds with 403 and returns false when the user from the
+// request context may not perform the action on the event.
+func (app *application) authorize(c *gin.Context, action eventAction, event *database.Event) bool {
+	allowed, err := app.can(c, app.GetUserFromContext(c), action, event)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions"})
+		return false
+	}
+
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You are not authorized to " + string(action) + " this event"})
+		return false
+	}
+
+	return true
+}
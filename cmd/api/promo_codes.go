@@ -0,0 +1,272 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"rest-api-event-app/internal/database"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type promoCodeReport struct {
+	PromoCode      *database.PromoCode         `json:"promo_code"`
+	Redemptions    int                         `json:"redemptions"`
+	DiscountTotals map[string]int64            `json:"discount_totals"`
+	Entries        []*database.PromoRedemption `json:"entries"`
+}
+
+// GetPromoCodesForEvent returns the promo codes of an event
+//
+//	@Summary		Returns the promo codes of an event
+//	@Description	Returns the promo codes of an event with the number of times each has been redeemed
+//	@Tags			promo codes
+//	@Produce		json
+//	@Param			eventId	path	int	true	"Event ID"
+//	@Success		200		{array}	database.PromoCode
+//	@Router			/events/{eventId}/promo-codes [get]
+//	@Security		BearerAuth
+func (app *application) getPromoCodesForEvent(c *gin.Context) {
+	event, ok := app.promoCodeEvent(c)
+	if !ok {
+		return
+	}
+
+	promoCodes, err := app.models.PromoCodes.GetByEvent(c, event.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve promo codes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, promoCodes)
+}
+
+// CreatePromoCode adds a promo code to an event
+//
+//	@Summary		Adds a promo code to an event
+//	@Description	Adds a promo code to an event. A percent discount takes discount_value percent off the ticket price, rounded down; a fixed discount takes discount_value, in the minor unit of currency, off tickets priced in that currency. max_uses caps redemptions, with 0 allowing any number, expires_at ends the code, and ticket_type_ids restricts it to some ticket types. Codes are case-insensitive.
+//	@Tags			promo codes
+//	@Accept			json
+//	@Produce		json
+//	@Param			eventId		path		int					true	"Event ID"
+//	@Param			promoCode	body		database.PromoCode	true	"Promo code"
+//	@Success		201			{object}	database.PromoCode
+//	@Router			/events/{eventId}/promo-codes [post]
+//	@Security		BearerAuth
+func (app *application) createPromoCode(c *gin.Context) {
+	event, ok := app.promoCodeEvent(c)
+	if !ok {
+		return
+	}
+
+	promoCode, ok := bindPromoCode(c)
+	if !ok {
+		return
+	}
+
+	promoCode.EventId = event.ID
+
+	result, err := app.models.PromoCodes.Insert(c, promoCode)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrPromoCodeExists):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, database.ErrTicketTypeNotFound):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "ticket_type_ids must be ticket types of this event"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create promo code"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, result)
+}
+
+// UpdatePromoCode updates a promo code of an event
+//
+//	@Summary		Updates a promo code of an event
+//	@Description	Replaces the settings of a promo code. Redemptions already made keep their discount, and max_uses cannot drop below their number.
+//	@Tags			promo codes
+//	@Accept			json
+//	@Produce		json
+//	@Param			eventId		path		int					true	"Event ID"
+//	@Param			promoCodeId	path		int					true	"Promo code ID"
+//	@Param			promoCode	body		database.PromoCode	true	"Promo code"
+//	@Success		200			{object}	database.PromoCode
+//	@Router			/events/{eventId}/promo-codes/{promoCodeId} [put]
+//	@Security		BearerAuth
+func (app *application) updatePromoCode(c *gin.Context) {
+	promoCodeId, err := strconv.Atoi(c.Param("promoCodeId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid promo code id"})
+		return
+	}
+
+	event, ok := app.promoCodeEvent(c)
+	if !ok {
+		return
+	}
+
+	promoCode, ok := bindPromoCode(c)
+	if !ok {
+		return
+	}
+
+	promoCode.ID = promoCodeId
+	promoCode.EventId = event.ID
+
+	result, err := app.models.PromoCodes.Update(c, promoCode)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrPromoCodeNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Promo code not found"})
+		case errors.Is(err, database.ErrPromoCodeExists), errors.Is(err, database.ErrMaxUsesBelowRedeemed):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, database.ErrTicketTypeNotFound):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "ticket_type_ids must be ticket types of this event"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update promo code"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// DeletePromoCode removes a promo code from an event
+//
+//	@Summary		Removes a promo code from an event
+//	@Description	Removes a promo code that has never been redeemed. Redeemed codes are kept for their redemption report; set expires_at to end them instead.
+//	@Tags			promo codes
+//	@Produce		json
+//	@Param			eventId		path		int		true	"Event ID"
+//	@Param			promoCodeId	path		int		true	"Promo code ID"
+//	@Success		204			{string}	string	"No Content"
+//	@Router			/events/{eventId}/promo-codes/{promoCodeId} [delete]
+//	@Security		BearerAuth
+func (app *application) deletePromoCode(c *gin.Context) {
+	promoCodeId, err := strconv.Atoi(c.Param("promoCodeId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid promo code id"})
+		return
+	}
+
+	event, ok := app.promoCodeEvent(c)
+	if !ok {
+		return
+	}
+
+	deleted, err := app.models.PromoCodes.Delete(c, event.ID, promoCodeId)
+	if err != nil {
+		if errors.Is(err, database.ErrPromoCodeInUse) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete promo code"})
+		return
+	}
+
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Promo code not found"})
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// GetPromoCodeRedemptions reports the redemptions of a promo code
+//
+//	@Summary		Reports the redemptions of a promo code
+//	@Description	Returns every redemption of a promo code, newest first, with who redeemed it, the price before and the discount given, and the status of the payment. Discounts are totalled per currency. Redemptions of payments that failed are not listed, as they do not count against max_uses.
+//	@Tags			promo codes
+//	@Produce		json
+//	@Param			eventId		path		int	true	"Event ID"
+//	@Param			promoCodeId	path		int	true	"Promo code ID"
+//	@Success		200			{object}	promoCodeReport
+//	@Router			/events/{eventId}/promo-codes/{promoCodeId}/redemptions [get]
+//	@Security		BearerAuth
+func (app *application) getPromoCodeRedemptions(c *gin.Context) {
+	promoCodeId, err := strconv.Atoi(c.Param("promoCodeId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid promo code id"})
+		return
+	}
+
+	event, ok := app.promoCodeEvent(c)
+	if !ok {
+		return
+	}
+
+	promoCode, err := app.models.PromoCodes.Get(c, event.ID, promoCodeId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve promo code"})
+		return
+	}
+
+	if promoCode == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Promo code not found"})
+		return
+	}
+
+	redemptions, err := app.models.PromoCodes.GetRedemptions(c, promoCode.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve redemptions"})
+		return
+	}
+
+	report := promoCodeReport{
+		PromoCode:      promoCode,
+		Redemptions:    len(redemptions),
+		DiscountTotals: map[string]int64{},
+		Entries:        redemptions,
+	}
+	for _, redemption := range redemptions {
+		report.DiscountTotals[redemption.Currency] += redemption.DiscountAmount
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// promoCodeEvent loads the event of a promo code request and checks that the
+// user may manage its promo codes, responding with an error otherwise.
+func (app *application) promoCodeEvent(c *gin.Context) (*database.Event, bool) {
+	eventId, err := strconv.Atoi(c.Param("eventId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event id"})
+		return nil, false
+	}
+
+	event, err := app.models.Events.GetEventById(eventId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve event"})
+		return nil, false
+	}
+
+	if event == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return nil, false
+	}
+
+	if !app.authorize(c, actionUpdateEvent, event) {
+		return nil, false
+	}
+
+	return event, true
+}
+
+// bindPromoCode reads and validates a promo code from the request body,
+// responding with 400 when it is invalid.
+func bindPromoCode(c *gin.Context) (*database.PromoCode, bool) {
+	var promoCode database.PromoCode
+	if err := c.ShouldBindJSON(&promoCode); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	if err := promoCode.Normalize(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	return &promoCode, true
+}
@@ -0,0 +1,424 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"rest-api-event-app/internal/database"
+	"rest-api-event-app/internal/payments"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	jobReconcilePayments = "payments.reconcile"
+	reconcileBatchSize   = 100
+)
+
+type purchaseRequest struct {
+	TicketTypeId  int        `json:"ticket_type_id" binding:"required,min=1"`
+	Occurrence    *time.Time `json:"occurrence"`
+	PaymentSource string     `json:"payment_source" binding:"max=255"`
+	PromoCode     string     `json:"promo_code" binding:"max=50"`
+}
+
+type purchaseResponse struct {
+	Attendee *database.Attendee `json:"attendee"`
+	Payment  *database.Payment  `json:"payment,omitempty"`
+}
+
+type refundRequest struct {
+	Amount int64 `json:"amount" binding:"omitempty,min=1"`
+}
+
+// BuyTicket registers the authenticated user for an event with a ticket
+//
+//	@Summary		Buys a ticket for an event
+//	@Description	Registers the authenticated user with a ticket type of an event. Paid tickets are charged to payment_source, a payment method collected by the client, and the registration is only confirmed once the payment succeeds; a declined payment releases the seat and responds with 402. The seat is held for the payment for PAYMENT_HOLD (15 minutes by default); a payment still unsettled by then is reconciled with the payment provider in the background. A promo_code of the event takes its discount off the price, and tickets it makes free, like free ticket types, are confirmed straight away. Sold out ticket types and full events respond with 409 rather than joining the waitlist, and paid tickets respond with 503 when the server has no payment provider.
+//	@Tags			tickets
+//	@Accept			json
+//	@Produce		json
+//	@Param			eventId		path		int				true	"Event ID"
+//	@Param			purchase	body		purchaseRequest	true	"Purchase"
+//	@Success		201			{object}	purchaseResponse
+//	@Router			/events/{eventId}/tickets [post]
+//	@Security		BearerAuth
+func (app *application) buyTicket(c *gin.Context) {
+	eventId, err := strconv.Atoi(c.Param("eventId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event id"})
+		return
+	}
+
+	var request purchaseRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	event, err := app.models.Events.GetEventById(eventId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve event"})
+		return
+	}
+
+	if event == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+
+	if request.Occurrence != nil {
+		occurrence := request.Occurrence.UTC()
+		request.Occurrence = &occurrence
+	}
+
+	if !checkOccurrence(c, event, request.Occurrence) {
+		return
+	}
+
+	user := app.GetUserFromContext(c)
+
+	attendee, payment, err := app.models.Payments.Reserve(c, database.Purchase{
+		EventId:      event.ID,
+		UserId:       user.ID,
+		TicketTypeId: request.TicketTypeId,
+		Occurrence:   request.Occurrence,
+		PromoCode:    request.PromoCode,
+		HoldFor:      app.paymentHold,
+	}, time.Now().UTC())
+	if err != nil {
+		respondReservationError(c, err)
+		return
+	}
+
+	if payment == nil {
+		c.JSON(http.StatusCreated, purchaseResponse{Attendee: attendee})
+		return
+	}
+
+	// Paid ticket types cannot be created without a provider, but one may
+	// have been left from before the provider was removed.
+	if app.paymentProvider == nil {
+		if _, err := app.models.Payments.Fail(c, payment.ID, "no payment provider"); err != nil {
+			log.Printf("Releasing the seat of payment %d failed: %s", payment.ID, err)
+		}
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Paid tickets cannot be bought on this server"})
+		return
+	}
+
+	charge, err := app.paymentProvider.Charge(c, payments.ChargeRequest{
+		Amount:         payment.Amount,
+		Currency:       payment.Currency,
+		Source:         request.PaymentSource,
+		Description:    fmt.Sprintf("%s (payment %d)", event.Name, payment.ID),
+		IdempotencyKey: chargeKey(payment),
+	})
+	if err != nil {
+		if _, failErr := app.models.Payments.Fail(c, payment.ID, err.Error()); failErr != nil {
+			log.Printf("Releasing the seat of failed payment %d failed: %s", payment.ID, failErr)
+		}
+
+		if errors.Is(err, payments.ErrDeclined) {
+			c.JSON(http.StatusPaymentRequired, gin.H{"error": "Payment was declined"})
+			return
+		}
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Payment could not be processed"})
+		return
+	}
+
+	confirmed, completed, err := app.models.Payments.Complete(c, payment.ID, charge.ID)
+	if err != nil {
+		if errors.Is(err, database.ErrRegistrationCancelled) {
+			app.refundInFull(c, completed)
+			c.JSON(http.StatusConflict, gin.H{"error": "Registration was cancelled while paying; the payment has been refunded"})
+			return
+		}
+
+		if errors.Is(err, database.ErrPaymentSettled) {
+			app.respondSettledPayment(c, attendee, payment, charge)
+			return
+		}
+
+		// The charge went through, so the pending payment keeps its seat
+		// until reconciliation settles it once its hold runs out.
+		log.Printf("Recording charge %s for payment %d failed: %s", charge.ID, payment.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record payment"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, purchaseResponse{Attendee: confirmed, Payment: completed})
+}
+
+// respondSettledPayment answers a purchase whose payment was settled by
+// reconciliation while it was being charged, because its seat hold ran out.
+// If reconciliation found the charge the purchase went through; otherwise the
+// seat was released and the charge is given back.
+func (app *application) respondSettledPayment(c *gin.Context, attendee *database.Attendee, payment *database.Payment, charge *payments.Charge) {
+	settled, err := app.models.Payments.Get(c, payment.EventId, payment.ID)
+	if err != nil || settled == nil {
+		log.Printf("Retrieving settled payment %d failed: %v", payment.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record payment"})
+		return
+	}
+
+	if settled.Status != database.PaymentStatusFailed {
+		confirmed, err := app.models.Attendees.GetByEventAndAttendee(c, attendee.EventId, attendee.UserId, attendee.Occurrence)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve registration"})
+			return
+		}
+		c.JSON(http.StatusCreated, purchaseResponse{Attendee: confirmed, Payment: settled})
+		return
+	}
+
+	_, err = app.paymentProvider.Refund(c, payments.RefundRequest{
+		ChargeID:       charge.ID,
+		Amount:         charge.Amount,
+		IdempotencyKey: chargeKey(payment) + "-expired",
+	})
+	if err != nil {
+		log.Printf("Refunding charge %s of expired payment %d failed: %s", charge.ID, payment.ID, err)
+	}
+
+	c.JSON(http.StatusConflict, gin.H{"error": "The seat was released because the payment took too long; the payment has been refunded"})
+}
+
+// chargeKey is the idempotency key a payment is charged with, which also
+// finds the charge again when reconciling the payment.
+func chargeKey(payment *database.Payment) string {
+	return fmt.Sprintf("payment-%d", payment.ID)
+}
+
+// refundInFull gives back a payment whose registration no longer exists.
+func (app *application) refundInFull(ctx context.Context, payment *database.Payment) {
+	refund, err := app.paymentProvider.Refund(ctx, payments.RefundRequest{
+		ChargeID:       payment.ChargeId,
+		Amount:         payment.Amount - payment.RefundedAmount,
+		IdempotencyKey: fmt.Sprintf("payment-%d-refund-%d", payment.ID, payment.RefundedAmount),
+	})
+	if err == nil {
+		_, err = app.models.Payments.RecordRefund(ctx, payment.ID, refund.Amount, payment.RefundedAmount)
+	}
+
+	if err != nil {
+		log.Printf("Refunding payment %d failed: %s", payment.ID, err)
+	}
+}
+
+// reconcilePayments settles the pending payments whose seat hold ran out,
+// which is left to this job when a purchase was interrupted between
+// reserving and recording the outcome of the charge. A payment whose charge
+// went through succeeds; any other fails and releases its seat.
+func (app *application) reconcilePayments(ctx context.Context, job *database.Job) error {
+	expired, err := app.models.Payments.GetExpired(ctx, time.Now().UTC(), reconcileBatchSize)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, payment := range expired {
+		if err := app.reconcilePayment(ctx, payment); err != nil {
+			errs = append(errs, fmt.Errorf("payment %d: %w", payment.ID, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (app *application) reconcilePayment(ctx context.Context, payment *database.Payment) error {
+	charge, err := app.paymentProvider.FindCharge(ctx, chargeKey(payment))
+	switch {
+	case errors.Is(err, payments.ErrChargeNotFound):
+		_, err = app.models.Payments.Fail(ctx, payment.ID, "seat hold expired before the payment went through")
+	case err != nil:
+		return err
+	default:
+		var completed *database.Payment
+		_, completed, err = app.models.Payments.Complete(ctx, payment.ID, charge.ID)
+		if errors.Is(err, database.ErrRegistrationCancelled) {
+			app.refundInFull(ctx, completed)
+			err = nil
+		}
+	}
+
+	// A purchase that was only slow settled the payment itself meanwhile.
+	if errors.Is(err, database.ErrPaymentSettled) {
+		return nil
+	}
+
+	return err
+}
+
+// respondReservationError maps the errors of reserving a ticket to a
+// response.
+func respondReservationError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, database.ErrEventNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+	case errors.Is(err, database.ErrTicketTypeNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Ticket type not found"})
+	case errors.Is(err, database.ErrAlreadyRegistered):
+		c.JSON(http.StatusConflict, gin.H{"error": "Already registered for this event"})
+	case errors.Is(err, database.ErrPromoCodeNotFound):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid promo code"})
+	case errors.Is(err, database.ErrTicketTypeNotOnSale),
+		errors.Is(err, database.ErrTicketTypeSoldOut),
+		errors.Is(err, database.ErrEventFull),
+		errors.Is(err, database.ErrPromoCodeExpired),
+		errors.Is(err, database.ErrPromoCodeExhausted),
+		errors.Is(err, database.ErrPromoCodeNotApplicable):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reserve ticket"})
+	}
+}
+
+// GetPaymentsForEvent returns the payments made for an event
+//
+//	@Summary		Returns the payments made for an event
+//	@Description	Returns every payment made for an event, newest first, including failed and refunded ones. Amounts are in the currency's minor unit.
+//	@Tags			tickets
+//	@Produce		json
+//	@Param			eventId	path	int	true	"Event ID"
+//	@Success		200		{array}	database.Payment
+//	@Router			/events/{eventId}/payments [get]
+//	@Security		BearerAuth
+func (app *application) getPaymentsForEvent(c *gin.Context) {
+	eventId, err := strconv.Atoi(c.Param("eventId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event id"})
+		return
+	}
+
+	event, err := app.models.Events.GetEventById(eventId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve event"})
+		return
+	}
+
+	if event == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+
+	if !app.authorize(c, actionManageAttendees, event) {
+		return
+	}
+
+	eventPayments, err := app.models.Payments.GetByEvent(c, event.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve payments"})
+		return
+	}
+
+	c.JSON(http.StatusOK, eventPayments)
+}
+
+// RefundPayment refunds a payment made for an event
+//
+//	@Summary		Refunds a payment
+//	@Description	Refunds part of a payment, or whatever is left of it when amount is omitted. A payment refunded in full cancels the registration it paid for and promotes the next waitlisted user into the seat.
+//	@Tags			tickets
+//	@Accept			json
+//	@Produce		json
+//	@Param			eventId		path		int				true	"Event ID"
+//	@Param			paymentId	path		int				true	"Payment ID"
+//	@Param			refund		body		refundRequest	false	"Refund"
+//	@Success		200			{object}	database.Payment
+//	@Router			/events/{eventId}/payments/{paymentId}/refund [post]
+//	@Security		BearerAuth
+func (app *application) refundPayment(c *gin.Context) {
+	eventId, err := strconv.Atoi(c.Param("eventId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event id"})
+		return
+	}
+
+	paymentId, err := strconv.Atoi(c.Param("paymentId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payment id"})
+		return
+	}
+
+	var request refundRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	event, err := app.models.Events.GetEventById(eventId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve event"})
+		return
+	}
+
+	if event == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+
+	if !app.authorize(c, actionManageAttendees, event) {
+		return
+	}
+
+	payment, err := app.models.Payments.Get(c, event.ID, paymentId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve payment"})
+		return
+	}
+
+	if payment == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payment not found"})
+		return
+	}
+
+	if payment.Status != database.PaymentStatusSucceeded {
+		c.JSON(http.StatusConflict, gin.H{"error": "Only succeeded payments can be refunded"})
+		return
+	}
+
+	remaining := payment.Amount - payment.RefundedAmount
+	amount := request.Amount
+	if amount == 0 {
+		amount = remaining
+	}
+
+	if amount > remaining {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("At most %d can be refunded", remaining)})
+		return
+	}
+
+	// The key only changes once a refund is recorded, so a refund retried
+	// or raced against another is only made once by the provider.
+	refund, err := app.paymentProvider.Refund(c, payments.RefundRequest{
+		ChargeID:       payment.ChargeId,
+		Amount:         amount,
+		IdempotencyKey: fmt.Sprintf("payment-%d-refund-%d", payment.ID, payment.RefundedAmount),
+	})
+	if err != nil {
+		log.Printf("Refunding payment %d failed: %s", payment.ID, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Refund could not be processed"})
+		return
+	}
+
+	payment, err = app.models.Payments.RecordRefund(c, payment.ID, refund.Amount, payment.RefundedAmount)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRefundConflict):
+			c.JSON(http.StatusConflict, gin.H{"error": "The payment was refunded at the same time; check it before refunding again"})
+		case errors.Is(err, database.ErrPaymentNotRefundable):
+			c.JSON(http.StatusConflict, gin.H{"error": "Only succeeded payments can be refunded"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record refund"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, payment)
+}
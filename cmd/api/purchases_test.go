@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"rest-api-event-app/internal/database"
+	"rest-api-event-app/internal/payments"
+	"rest-api-event-app/internal/testdb"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// purchaseTest is an event with a single paid ticket type, sold by an app
+// that takes payments with a FakeProvider.
+type purchaseTest struct {
+	app        *application
+	provider   *payments.FakeProvider
+	owner      *database.User
+	event      *database.Event
+	ticketType *database.TicketType
+}
+
+func newPurchaseTest(t *testing.T, capacity int) *purchaseTest {
+	t.Helper()
+
+	models := database.NewModels(testdb.Open(t))
+	provider := payments.NewFakeProvider()
+	app := &application{models: models, paymentProvider: provider, paymentHold: 15 * time.Minute}
+
+	owner := createTestUser(t, &models)
+	event := createTestEvent(t, &models, owner.ID, capacity)
+
+	ticketType, err := models.TicketTypes.Insert(context.Background(), &database.TicketType{EventId: event.ID, Name: "General", Price: 2500, Currency: "EUR"})
+	if err != nil {
+		t.Fatalf("creating ticket type: %s", err)
+	}
+
+	return &purchaseTest{app: app, provider: provider, owner: owner, event: event, ticketType: ticketType}
+}
+
+func (p *purchaseTest) buy(t *testing.T, buyer *database.User, source string) (int, purchaseResponse) {
+	t.Helper()
+
+	body := fmt.Sprintf(`{"ticket_type_id":%d,"payment_source":%q}`, p.ticketType.ID, source)
+	w := serveAs(buyer, p.app.buyTicket, gin.Params{{Key: "eventId", Value: strconv.Itoa(p.event.ID)}}, body)
+
+	var response purchaseResponse
+	if w.Code == http.StatusCreated {
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("decoding purchase: %s", err)
+		}
+	}
+
+	return w.Code, response
+}
+
+func (p *purchaseTest) refund(t *testing.T, paymentId int, body string) (int, *database.Payment) {
+	t.Helper()
+
+	params := gin.Params{{Key: "eventId", Value: strconv.Itoa(p.event.ID)}, {Key: "paymentId", Value: strconv.Itoa(paymentId)}}
+	w := serveAs(p.owner, p.app.refundPayment, params, body)
+
+	var payment database.Payment
+	if w.Code == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), &payment); err != nil {
+			t.Fatalf("decoding payment: %s", err)
+		}
+	}
+
+	return w.Code, &payment
+}
+
+func (p *purchaseTest) payment(t *testing.T, id int) *database.Payment {
+	t.Helper()
+
+	payment, err := p.app.models.Payments.Get(context.Background(), p.event.ID, id)
+	if err != nil || payment == nil {
+		t.Fatalf("getting payment %d: %v, %v", id, payment, err)
+	}
+
+	return payment
+}
+
+func TestBuyTicketDeclined(t *testing.T) {
+	p := newPurchaseTest(t, 1)
+
+	if status, _ := p.buy(t, createTestUser(t, &p.app.models), payments.DeclinedSource); status != http.StatusPaymentRequired {
+		t.Fatalf("declined purchase responded %d, want 402", status)
+	}
+
+	eventPayments, err := p.app.models.Payments.GetByEvent(context.Background(), p.event.ID)
+	if err != nil || len(eventPayments) != 1 || eventPayments[0].Status != database.PaymentStatusFailed {
+		t.Fatalf("payments after a decline: %v, %v; want one failed", eventPayments, err)
+	}
+
+	if charges := p.provider.Charges(); len(charges) != 0 {
+		t.Errorf("provider took %d charges, want none", len(charges))
+	}
+
+	// The declined purchase gave the only seat back.
+	if status, _ := p.buy(t, createTestUser(t, &p.app.models), "tok_visa"); status != http.StatusCreated {
+		t.Errorf("purchase after a decline responded %d, want 201", status)
+	}
+}
+
+func TestBuyAndRefundTicket(t *testing.T) {
+	p := newPurchaseTest(t, 10)
+
+	status, purchase := p.buy(t, createTestUser(t, &p.app.models), "tok_visa")
+	if status != http.StatusCreated {
+		t.Fatalf("purchase responded %d, want 201", status)
+	}
+
+	payment := purchase.Payment
+	if payment == nil || payment.Status != database.PaymentStatusSucceeded || payment.Amount != 2500 || purchase.Attendee.Status != database.AttendeeStatusConfirmed {
+		t.Fatalf("purchase = %+v, %+v; want a confirmed seat paid 2500", purchase.Attendee, payment)
+	}
+
+	charges := p.provider.Charges()
+	if len(charges) != 1 || charges[0].ID != payment.ChargeId || charges[0].Amount != 2500 {
+		t.Fatalf("provider charges = %+v, want the payment's charge of 2500", charges)
+	}
+
+	status, refunded := p.refund(t, payment.ID, `{"amount":1000}`)
+	if status != http.StatusOK || refunded.Status != database.PaymentStatusSucceeded || refunded.RefundedAmount != 1000 {
+		t.Fatalf("partial refund = %d %+v, want 1000 refunded", status, refunded)
+	}
+
+	if status, _ := p.refund(t, payment.ID, `{"amount":2000}`); status != http.StatusBadRequest {
+		t.Errorf("refunding more than is left responded %d, want 400", status)
+	}
+
+	status, refunded = p.refund(t, payment.ID, "")
+	if status != http.StatusOK || refunded.Status != database.PaymentStatusRefunded || refunded.RefundedAmount != 2500 {
+		t.Fatalf("refund of the rest = %d %+v, want the payment refunded in full", status, refunded)
+	}
+
+	var total int64
+	for _, refund := range p.provider.Refunds() {
+		total += refund.Amount
+	}
+	if total != 2500 {
+		t.Errorf("provider refunded %d, want 2500", total)
+	}
+
+	if status, _ := p.refund(t, payment.ID, ""); status != http.StatusConflict {
+		t.Errorf("refunding a refunded payment responded %d, want 409", status)
+	}
+}
+
+// TestReconcilePayments settles payments whose purchase stopped after
+// reserving the seat: one was charged before it stopped, the other was not.
+func TestReconcilePayments(t *testing.T) {
+	p := newPurchaseTest(t, 10)
+	ctx := context.Background()
+
+	reserved := time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+	reserve := func() *database.Payment {
+		_, payment, err := p.app.models.Payments.Reserve(ctx, database.Purchase{
+			EventId:      p.event.ID,
+			UserId:       createTestUser(t, &p.app.models).ID,
+			TicketTypeId: p.ticketType.ID,
+			HoldFor:      p.app.paymentHold,
+		}, reserved)
+		if err != nil || payment == nil {
+			t.Fatalf("reserving: %v, %v", payment, err)
+		}
+		return payment
+	}
+
+	charged, uncharged := reserve(), reserve()
+
+	charge, err := p.provider.Charge(ctx, payments.ChargeRequest{Amount: charged.Amount, Currency: charged.Currency, Source: "tok_visa", IdempotencyKey: chargeKey(charged)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, payment := range []*database.Payment{charged, uncharged} {
+		if err := p.app.reconcilePayment(ctx, payment); err != nil {
+			t.Fatalf("reconciling payment %d: %s", payment.ID, err)
+		}
+	}
+
+	if settled := p.payment(t, charged.ID); settled.Status != database.PaymentStatusSucceeded || settled.ChargeId != charge.ID {
+		t.Errorf("charged payment = %+v, want it succeeded with charge %s", settled, charge.ID)
+	}
+
+	if settled := p.payment(t, uncharged.ID); settled.Status != database.PaymentStatusFailed {
+		t.Errorf("uncharged payment = %+v, want it failed", settled)
+	}
+
+	// Reconciling again finds the payments settled and leaves them be.
+	if err := p.app.reconcilePayment(ctx, charged); err != nil {
+		t.Errorf("reconciling a settled payment: %s", err)
+	}
+}
+
+func TestBuyPaidTicketWithoutProvider(t *testing.T) {
+	p := newPurchaseTest(t, 1)
+	p.app.paymentProvider = nil
+
+	if status, _ := p.buy(t, createTestUser(t, &p.app.models), "tok_visa"); status != http.StatusServiceUnavailable {
+		t.Fatalf("purchase without a provider responded %d, want 503", status)
+	}
+
+	eventPayments, err := p.app.models.Payments.GetByEvent(context.Background(), p.event.ID)
+	if err != nil || len(eventPayments) != 1 || eventPayments[0].Status != database.PaymentStatusFailed {
+		t.Errorf("payments without a provider: %v, %v; want one failed, releasing its seat", eventPayments, err)
+	}
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"rest-api-event-app/internal/database"
+	"rest-api-event-app/internal/jobs"
+	"rest-api-event-app/internal/notify"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const jobSendReminder = "reminders.send"
+
+// reminderScheduler queues the reminders of upcoming events. offsets are
+// how long before an occurrence starts its reminders go out, longest first.
+type reminderScheduler struct {
+	offsets  []time.Duration
+	interval time.Duration
+}
+
+type reminderJob struct {
+	EventId       int       `json:"event_id"`
+	Occurrence    time.Time `json:"occurrence"`
+	OffsetMinutes int       `json:"offset_minutes"`
+}
+
+type reminderSettings struct {
+	Enabled        bool  `json:"enabled"`
+	OffsetsMinutes []int `json:"offsets_minutes"`
+}
+
+type updateReminderSettingsRequest struct {
+	Enabled *bool `json:"enabled" binding:"required"`
+}
+
+// GetReminderSettings returns whether the authenticated user gets reminders of an event
+//
+//	@Summary		Returns the authenticated user's reminder settings for an event
+//	@Description	Returns whether the authenticated user is reminded of an event, and how many minutes before each occurrence reminders are sent
+//	@Tags			reminders
+//	@Produce		json
+//	@Param			eventId	path		int	true	"Event ID"
+//	@Success		200		{object}	reminderSettings
+//	@Router			/events/{eventId}/reminders [get]
+//	@Security		BearerAuth
+func (app *application) getReminderSettings(c *gin.Context) {
+	event, ok := app.reminderEvent(c)
+	if !ok {
+		return
+	}
+
+	user := app.GetUserFromContext(c)
+
+	optedOut, err := app.models.Reminders.IsOptedOut(c, user.ID, event.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve reminder settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, app.reminders.settings(!optedOut))
+}
+
+// UpdateReminderSettings opts the authenticated user in or out of the reminders of an event
+//
+//	@Summary		Opts in or out of the reminders of an event
+//	@Description	Attendees are reminded of every occurrence of the events they attend, by email and in the app. Setting enabled to false stops the reminders of this event for the authenticated user; setting it to true starts them again.
+//	@Tags			reminders
+//	@Accept			json
+//	@Produce		json
+//	@Param			eventId		path		int								true	"Event ID"
+//	@Param			settings	body		updateReminderSettingsRequest	true	"Settings"
+//	@Success		200			{object}	reminderSettings
+//	@Router			/events/{eventId}/reminders [put]
+//	@Security		BearerAuth
+func (app *application) updateReminderSettings(c *gin.Context) {
+	var request updateReminderSettingsRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	event, ok := app.reminderEvent(c)
+	if !ok {
+		return
+	}
+
+	user := app.GetUserFromContext(c)
+
+	var err error
+	if *request.Enabled {
+		err = app.models.Reminders.OptIn(c, user.ID, event.ID)
+	} else {
+		err = app.models.Reminders.OptOut(c, user.ID, event.ID)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update reminder settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, app.reminders.settings(*request.Enabled))
+}
+
+// reminderEvent loads the event of a reminder settings request, responding
+// with an error when there is none.
+func (app *application) reminderEvent(c *gin.Context) (*database.Event, bool) {
+	eventId, err := strconv.Atoi(c.Param("eventId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event id"})
+		return nil, false
+	}
+
+	event, err := app.models.Events.GetEventById(eventId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve event"})
+		return nil, false
+	}
+
+	if event == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return nil, false
+	}
+
+	return event, true
+}
+
+func (r *reminderScheduler) settings(enabled bool) reminderSettings {
+	settings := reminderSettings{Enabled: enabled, OffsetsMinutes: []int{}}
+	for _, offset := range r.offsets {
+		settings.OffsetsMinutes = append(settings.OffsetsMinutes, int(offset/time.Minute))
+	}
+
+	return settings
+}
+
+// dueOffset returns the offset of the reminder due for an occurrence
+// starting at start: the shortest offset already reached. A longer one that
+// was missed, say while the server was down, is skipped rather than sent
+// late next to it.
+func (r *reminderScheduler) dueOffset(start, now time.Time) (time.Duration, bool) {
+	until := start.Sub(now)
+	if until <= 0 {
+		return 0, false
+	}
+
+	for i := len(r.offsets) - 1; i >= 0; i-- {
+		if until <= r.offsets[i] {
+			return r.offsets[i], true
+		}
+	}
+
+	return 0, false
+}
+
+// runReminderScheduler queues due reminders until ctx is cancelled.
+func (app *application) runReminderScheduler(ctx context.Context) {
+	if len(app.reminders.offsets) == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(app.reminders.interval)
+	defer ticker.Stop()
+
+	for {
+		app.scheduleReminders(ctx, time.Now().UTC())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// scheduleReminders queues a job for every reminder that is due. Each job
+// has a unique key, so a reminder is queued once however often it is
+// scheduled, by however many servers.
+func (app *application) scheduleReminders(ctx context.Context, now time.Time) {
+	until := now.Add(app.reminders.offsets[0])
+
+	events, err := app.models.Events.GetEventsStartingBetween(ctx, now, until)
+	if err != nil {
+		log.Printf("Finding events to remind of failed: %s", err)
+		return
+	}
+
+	for _, event := range events {
+		occurrences, err := event.Expand(now, until)
+		if err != nil {
+			log.Printf("Expanding event %d failed: %s", event.ID, err)
+			continue
+		}
+
+		for _, occurrence := range occurrences {
+			start := occurrence.StartsAt.UTC()
+			if !start.After(now) || start.After(until) {
+				continue
+			}
+
+			offset, ok := app.reminders.dueOffset(start, now)
+			if !ok {
+				continue
+			}
+
+			payload := reminderJob{EventId: event.ID, Occurrence: start, OffsetMinutes: int(offset / time.Minute)}
+			key := fmt.Sprintf("reminder:%d:%d:%d", payload.EventId, start.Unix(), payload.OffsetMinutes)
+
+			_, err := app.jobs.Enqueue(ctx, jobSendReminder, payload, jobs.Options{UniqueKey: key})
+			if err != nil && !errors.Is(err, database.ErrDuplicateJob) {
+				log.Printf("Queueing reminder %s failed: %s", key, err)
+			}
+		}
+	}
+}
+
+// sendReminder reminds the attendees of an occurrence of an event that it
+// starts soon, through every notification channel. Each user is claimed
+// per channel before being notified, so a retried job, or one queued again,
+// never reminds anyone twice.
+func (app *application) sendReminder(ctx context.Context, job *database.Job) error {
+	var payload reminderJob
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return jobs.Permanent(err)
+	}
+
+	event, err := app.models.Events.GetEventById(payload.EventId)
+	if err != nil {
+		return err
+	}
+
+	// The event was deleted or moved since the reminder was queued, or the
+	// reminder is too late to be of use.
+	start := payload.Occurrence
+	if event == nil || !event.IsOccurrence(start) || !start.After(time.Now()) {
+		return nil
+	}
+
+	var occurrence *time.Time
+	if event.IsRecurring() {
+		occurrence = &start
+	}
+
+	users, err := app.models.Attendees.GetAttendeesByEvent(ctx, event.ID, occurrence)
+	if err != nil {
+		return err
+	}
+
+	optedOut, err := app.models.Reminders.GetOptedOut(ctx, event.ID)
+	if err != nil {
+		return err
+	}
+
+	reminder := database.Reminder{EventId: event.ID, Occurrence: start, Offset: time.Duration(payload.OffsetMinutes) * time.Minute}
+	localStart := start.In(event.StartsAt.Location())
+
+	// The title says how long is actually left rather than the offset, since
+	// a reminder may go out later than its offset, such as the first one of
+	// an event created shortly before it starts.
+	startsIn := formatTimeUntil(time.Until(start))
+
+	var failed error
+	for _, user := range users {
+		if optedOut[user.ID] {
+			continue
+		}
+
+		notification := notify.Notification{
+			User:    user,
+			Kind:    notify.KindEventReminder,
+			Title:   fmt.Sprintf("Reminder: %s starts in %s", event.Name, startsIn),
+			Body:    fmt.Sprintf("%s starts on %s (%s) at %s.", event.Name, localStart.Format("Mon, 2 Jan 2006 15:04"), event.TimeZone, event.Location),
+			EventId: event.ID,
+		}
+
+		for channel, notifier := range app.notifiers {
+			claimed, err := app.models.Reminders.Claim(ctx, reminder, user.ID, channel, time.Now().UTC())
+			if err != nil {
+				return err
+			}
+
+			if !claimed {
+				continue
+			}
+
+			if err := notifier.Notify(ctx, notification); err != nil {
+				failed = fmt.Errorf("reminding user %d by %s: %w", user.ID, channel, err)
+				if err := app.models.Reminders.Unclaim(context.WithoutCancel(ctx), reminder, user.ID, channel); err != nil {
+					log.Printf("Releasing reminder of user %d by %s failed: %s", user.ID, channel, err)
+				}
+			}
+		}
+	}
+
+	return failed
+}
+
+// formatTimeUntil spells out how long until an occurrence starts, such as
+// "3 hours" or "30 minutes", rounded to the unit it is given in.
+func formatTimeUntil(d time.Duration) string {
+	value, unit := max(1, int(d.Round(time.Minute)/time.Minute)), "minute"
+	switch {
+	case d >= 48*time.Hour:
+		value, unit = int(d.Round(24*time.Hour)/(24*time.Hour)), "day"
+	case d >= 2*time.Hour || d.Round(time.Minute) == time.Hour:
+		value, unit = int(d.Round(time.Hour)/time.Hour), "hour"
+	}
+
+	if value == 1 {
+		return "1 " + unit
+	}
+
+	return fmt.Sprintf("%d %ss", value, unit)
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFormatTimeUntil(t *testing.T) {
+	tests := []struct {
+		until time.Duration
+		want  string
+	}{
+		{30 * time.Second, "1 minute"},
+		{29*time.Minute + 40*time.Second, "30 minutes"},
+		{90 * time.Minute, "90 minutes"},
+		{59*time.Minute + 50*time.Second, "1 hour"},
+		{3*time.Hour - 2*time.Minute, "3 hours"},
+		{24*time.Hour - 30*time.Second, "24 hours"},
+		{72*time.Hour - time.Minute, "3 days"},
+	}
+
+	for _, tt := range tests {
+		if got := formatTimeUntil(tt.until); got != tt.want {
+			t.Errorf("formatTimeUntil(%s) = %q, want %q", tt.until, got, tt.want)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"net/http"
+	"rest-api-event-app/internal/database"
 
 	"github.com/gin-gonic/gin"
 	swaggerFile "github.com/swaggo/files"
@@ -14,22 +15,93 @@ func (app *application) routes() http.Handler {
 	v1 := g.Group("/api/v1")
 	{
 		v1.GET("/events", app.getAllEvent)
+		v1.GET("/events/search", app.searchEvents)
 		v1.GET("/events/:eventId", app.getEvent)
 		v1.GET("/events/:eventId/attendees", app.getAttendeesForEvent)
+		v1.GET("/events/:eventId/waitlist", app.getWaitlistForEvent)
+		v1.GET("/events/:eventId/occurrences", app.getEventOccurrences)
+		v1.GET("/events/:eventId/ics", app.getEventICS)
+		v1.GET("/events/:eventId/ticket-types", app.getTicketTypesForEvent)
 		v1.GET("/attendees/:attendeeId/events", app.getEventByAttendee)
+		v1.GET("/calendar/:token", app.getCalendarFeed)
 
 		v1.POST("/auth/register", app.registerUser)
 		v1.POST("/auth/login", app.login)
+		v1.POST("/auth/refresh", app.refreshToken)
+		v1.POST("/auth/forgot-password", app.forgotPassword)
+		v1.POST("/auth/reset-password", app.resetPassword)
+		v1.GET("/auth/verify", app.verifyEmail)
+		v1.POST("/auth/verify", app.verifyEmail)
 	}
 
 	authGroup := v1.Group("/")
 	authGroup.Use(app.AuthMiddleware())
 	{
-		authGroup.POST("/events", app.createEvent)
+		authGroup.POST("/auth/logout", app.logout)
+		authGroup.POST("/auth/logout-all", app.logoutAll)
+		authGroup.POST("/auth/verify/resend", app.resendVerification)
+
+		authGroup.POST("/events", app.RequireVerified(), app.createEvent)
+		authGroup.POST("/events/import", app.RequireVerified(), app.importEvents)
 		authGroup.PUT("/events/:eventId", app.updateEvent)
 		authGroup.DELETE("/events/:eventId", app.deleteEvent)
-		authGroup.POST("/events/:eventId/attendees/:userId", app.addAttendeeToEvent)
+		authGroup.POST("/events/:eventId/attendees/:userId", app.RequireVerified(), app.addAttendeeToEvent)
 		authGroup.DELETE("/events/:eventId/attendees/:userId", app.deleteAttendeeFromEvent)
+		authGroup.POST("/events/:eventId/attendees/import", app.RequireVerified(), app.importAttendees)
+		authGroup.POST("/events/:eventId/attendees/export", app.exportAttendees)
+		authGroup.GET("/imports/:importId", app.getImport)
+		authGroup.GET("/exports/:exportId", app.getExport)
+		authGroup.GET("/exports/:exportId/file", app.downloadExport)
+
+		authGroup.GET("/events/:eventId/organizers", app.getOrganizersForEvent)
+		authGroup.POST("/events/:eventId/organizers", app.addOrganizerToEvent)
+		authGroup.POST("/events/:eventId/organizers/accept", app.acceptOrganizerInvitation)
+		authGroup.DELETE("/events/:eventId/organizers/:userId", app.deleteOrganizerFromEvent)
+
+		authGroup.GET("/events/:eventId/rsvp", app.getMyRSVP)
+		authGroup.PUT("/events/:eventId/rsvp", app.RequireVerified(), app.rsvpToEvent)
+		authGroup.DELETE("/events/:eventId/rsvp", app.cancelMyRSVP)
+		authGroup.GET("/events/:eventId/reminders", app.getReminderSettings)
+		authGroup.PUT("/events/:eventId/reminders", app.updateReminderSettings)
+
+		authGroup.POST("/events/:eventId/ticket-types", app.createTicketType)
+		authGroup.PUT("/events/:eventId/ticket-types/:ticketTypeId", app.updateTicketType)
+		authGroup.DELETE("/events/:eventId/ticket-types/:ticketTypeId", app.deleteTicketType)
+		authGroup.GET("/events/:eventId/promo-codes", app.getPromoCodesForEvent)
+		authGroup.POST("/events/:eventId/promo-codes", app.createPromoCode)
+		authGroup.PUT("/events/:eventId/promo-codes/:promoCodeId", app.updatePromoCode)
+		authGroup.DELETE("/events/:eventId/promo-codes/:promoCodeId", app.deletePromoCode)
+		authGroup.GET("/events/:eventId/promo-codes/:promoCodeId/redemptions", app.getPromoCodeRedemptions)
+		authGroup.POST("/events/:eventId/tickets", app.RequireVerified(), app.buyTicket)
+		authGroup.GET("/events/:eventId/payments", app.getPaymentsForEvent)
+		authGroup.POST("/events/:eventId/payments/:paymentId/refund", app.refundPayment)
+
+		authGroup.GET("/events/:eventId/tickets/me", app.getMyTicket)
+		authGroup.POST("/events/:eventId/check-in", app.checkInAttendee)
+		authGroup.GET("/events/:eventId/check-in/manifest", app.getCheckInManifest)
+		authGroup.POST("/events/:eventId/check-in/sync", app.syncCheckIns)
+
+		authGroup.POST("/me/calendar-feed", app.createCalendarFeed)
+		authGroup.DELETE("/me/calendar-feed", app.deleteCalendarFeed)
+		authGroup.GET("/me/organizer-invitations", app.getOrganizerInvitations)
+
+		authGroup.GET("/me/notifications", app.getNotifications)
+		authGroup.POST("/me/notifications/read-all", app.markAllNotificationsRead)
+		authGroup.POST("/me/notifications/:notificationId/read", app.markNotificationRead)
+
+		authGroup.POST("/webhooks", app.createWebhook)
+		authGroup.GET("/webhooks", app.getWebhooks)
+		authGroup.DELETE("/webhooks/:webhookId", app.deleteWebhook)
+		authGroup.GET("/webhooks/:webhookId/deliveries", app.getWebhookDeliveries)
+		authGroup.POST("/webhooks/:webhookId/deliveries/:deliveryId/redeliver", app.redeliverWebhook)
+	}
+
+	adminGroup := authGroup.Group("/admin")
+	adminGroup.Use(app.RequireRole(database.RoleAdmin))
+	{
+		adminGroup.PUT("/users/:userId/role", app.updateUserRole)
+		adminGroup.GET("/jobs", app.getJobs)
+		adminGroup.POST("/jobs/:jobId/requeue", app.requeueJob)
 	}
 
 	g.GET("/swagger/*any", func(ctx *gin.Context) {
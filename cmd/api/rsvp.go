@@ -0,0 +1,180 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"rest-api-event-app/internal/database"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type rsvpRequest struct {
+	Status     string     `json:"status" binding:"required,oneof=going maybe declined"`
+	Occurrence *time.Time `json:"occurrence"`
+}
+
+// GetMyRSVP returns the authenticated user's RSVP for an event
+//
+//	@Summary		Returns the authenticated user's RSVP for an event
+//	@Description	Returns the authenticated user's RSVP for an event, or for a single occurrence of a recurring event
+//	@Tags			rsvp
+//	@Accept			json
+//	@Produce		json
+//	@Param			eventId		path		int		true	"Event ID"
+//	@Param			occurrence	query		string	false	"Start of a single occurrence (RFC 3339)"
+//	@Success		200			{object}	database.Attendee
+//	@Router			/events/{eventId}/rsvp [get]
+//	@Security		BearerAuth
+func (app *application) getMyRSVP(c *gin.Context) {
+	eventId, err := strconv.Atoi(c.Param("eventId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event id"})
+		return
+	}
+
+	occurrence, ok := parseOccurrence(c)
+	if !ok {
+		return
+	}
+
+	user := app.GetUserFromContext(c)
+
+	attendee, err := app.models.Attendees.GetByEventAndAttendee(c, eventId, user.ID, occurrence)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve RSVP"})
+		return
+	}
+
+	if attendee == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "RSVP not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, attendee)
+}
+
+// RSVPToEvent registers the authenticated user for an event
+//
+//	@Summary		Registers the authenticated user for an event
+//	@Description	Sets the authenticated user's RSVP to going, maybe or declined. Going and maybe take a seat, or a waitlist spot when the event is full; declined releases it. For recurring events, occurrence answers for a single occurrence instead of the whole series. Events with ticket types only accept going or maybe from users who already hold a ticket; others respond with 402.
+//	@Tags			rsvp
+//	@Accept			json
+//	@Produce		json
+//	@Param			eventId	path		int			true	"Event ID"
+//	@Param			rsvp	body		rsvpRequest	true	"RSVP"
+//	@Success		200		{object}	database.Attendee
+//	@Router			/events/{eventId}/rsvp [put]
+//	@Security		BearerAuth
+func (app *application) rsvpToEvent(c *gin.Context) {
+	eventId, err := strconv.Atoi(c.Param("eventId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event id"})
+		return
+	}
+
+	var rsvp rsvpRequest
+	if err := c.ShouldBindJSON(&rsvp); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	event, err := app.models.Events.GetEventById(eventId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve event"})
+		return
+	}
+
+	if event == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+
+	if rsvp.Occurrence != nil {
+		occurrence := rsvp.Occurrence.UTC()
+		rsvp.Occurrence = &occurrence
+	}
+
+	if !checkOccurrence(c, event, rsvp.Occurrence) {
+		return
+	}
+
+	user := app.GetUserFromContext(c)
+
+	// Seats of events with ticket types are only given out with a ticket.
+	if rsvp.Status != database.RSVPDeclined {
+		ticketed, err := app.models.TicketTypes.ExistForEvent(c, event.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve ticket types"})
+			return
+		}
+
+		if ticketed {
+			existing, err := app.models.Attendees.GetByEventAndAttendee(c, event.ID, user.ID, rsvp.Occurrence)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve RSVP"})
+				return
+			}
+
+			if existing == nil || existing.Status == database.AttendeeStatusDeclined {
+				c.JSON(http.StatusPaymentRequired, gin.H{"error": "This event requires a ticket; buy one to register"})
+				return
+			}
+		}
+	}
+
+	attendee, err := app.models.Attendees.Respond(c, eventId, user.ID, rsvp.Occurrence, rsvp.Status)
+	if err != nil {
+		if errors.Is(err, database.ErrEventNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save RSVP"})
+		return
+	}
+
+	c.JSON(http.StatusOK, attendee)
+}
+
+// CancelMyRSVP cancels the authenticated user's attendance
+//
+//	@Summary		Cancels the authenticated user's attendance
+//	@Description	Removes the authenticated user from an event, or from a single occurrence of a recurring event, and promotes the next waitlisted user into the freed seat
+//	@Tags			rsvp
+//	@Accept			json
+//	@Produce		json
+//	@Param			eventId		path		int		true	"Event ID"
+//	@Param			occurrence	query		string	false	"Start of a single occurrence (RFC 3339)"
+//	@Success		204			{string}	string	"No Content"
+//	@Router			/events/{eventId}/rsvp [delete]
+//	@Security		BearerAuth
+func (app *application) cancelMyRSVP(c *gin.Context) {
+	eventId, err := strconv.Atoi(c.Param("eventId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event id"})
+		return
+	}
+
+	occurrence, ok := parseOccurrence(c)
+	if !ok {
+		return
+	}
+
+	user := app.GetUserFromContext(c)
+
+	_, err = app.models.Attendees.Unregister(c, eventId, user.ID, occurrence)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrAttendeeNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "RSVP not found"})
+		case errors.Is(err, database.ErrEventNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel RSVP"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
@@ -1,12 +1,21 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 )
 
+// serve runs the HTTP server and the background workers until the process
+// is interrupted, then shuts down gracefully: the server stops accepting
+// requests and finishes the ones in flight while the workers drain.
 func (app *application) serve() error {
 	server := &http.Server{
 		Addr:         fmt.Sprintf(":%d", app.port),
@@ -16,7 +25,42 @@ func (app *application) serve() error {
 		WriteTimeout: 30 * time.Second,
 	}
 
-	log.Printf("Starting server on port %d", app.port)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	return server.ListenAndServe()
+	var workers sync.WaitGroup
+	for _, run := range []func(context.Context){app.runOutboxDispatcher, app.runReminderScheduler, app.jobs.Run} {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			run(ctx)
+		}()
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		log.Printf("Starting server on port %d", app.port)
+		serveErr <- server.ListenAndServe()
+	}()
+
+	var err error
+	select {
+	case err = <-serveErr:
+		stop()
+	case <-ctx.Done():
+		log.Printf("Shutting down")
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		err = server.Shutdown(shutdownCtx)
+	}
+
+	workers.Wait()
+
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+
+	return err
 }
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"rest-api-event-app/internal/database"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetTicketTypesForEvent returns the ticket types of an event
+//
+//	@Summary		Returns the ticket types of an event
+//	@Description	Returns the ticket types of an event, cheapest first, with their price in the currency's minor unit, quantity, sales window and the number sold
+//	@Tags			tickets
+//	@Produce		json
+//	@Param			eventId	path	int	true	"Event ID"
+//	@Success		200		{array}	database.TicketType
+//	@Router			/events/{eventId}/ticket-types [get]
+func (app *application) getTicketTypesForEvent(c *gin.Context) {
+	eventId, err := strconv.Atoi(c.Param("eventId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event id"})
+		return
+	}
+
+	ticketTypes, err := app.models.TicketTypes.GetByEvent(c, eventId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve ticket types"})
+		return
+	}
+
+	c.JSON(http.StatusOK, ticketTypes)
+}
+
+// CreateTicketType adds a ticket type to an event
+//
+//	@Summary		Adds a ticket type to an event
+//	@Description	Adds a ticket type, such as Early Bird or VIP, to an event. price is in the minor unit of currency, an ISO 4217 code, and 0 makes the ticket free. Paid ticket types respond with 422 while the server has no payment provider. quantity caps how many are sold, with 0 leaving only the event's capacity. Once an event has ticket types, attendees register by buying a ticket.
+//	@Tags			tickets
+//	@Accept			json
+//	@Produce		json
+//	@Param			eventId		path		int					true	"Event ID"
+//	@Param			ticketType	body		database.TicketType	true	"Ticket type"
+//	@Success		201			{object}	database.TicketType
+//	@Router			/events/{eventId}/ticket-types [post]
+//	@Security		BearerAuth
+func (app *application) createTicketType(c *gin.Context) {
+	eventId, err := strconv.Atoi(c.Param("eventId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event id"})
+		return
+	}
+
+	event, err := app.models.Events.GetEventById(eventId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve event"})
+		return
+	}
+
+	if event == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+
+	if !app.authorize(c, actionUpdateEvent, event) {
+		return
+	}
+
+	ticketType, ok := app.bindTicketType(c)
+	if !ok {
+		return
+	}
+
+	ticketType.EventId = event.ID
+
+	result, err := app.models.TicketTypes.Insert(c, ticketType)
+	if err != nil {
+		if errors.Is(err, database.ErrTicketTypeExists) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create ticket type"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, result)
+}
+
+// UpdateTicketType updates a ticket type of an event
+//
+//	@Summary		Updates a ticket type of an event
+//	@Description	Replaces the name, price, quantity and sales window of a ticket type. Tickets already sold keep the price they were bought at, and the quantity cannot drop below the number sold.
+//	@Tags			tickets
+//	@Accept			json
+//	@Produce		json
+//	@Param			eventId			path		int					true	"Event ID"
+//	@Param			ticketTypeId	path		int					true	"Ticket type ID"
+//	@Param			ticketType		body		database.TicketType	true	"Ticket type"
+//	@Success		200				{object}	database.TicketType
+//	@Router			/events/{eventId}/ticket-types/{ticketTypeId} [put]
+//	@Security		BearerAuth
+func (app *application) updateTicketType(c *gin.Context) {
+	eventId, err := strconv.Atoi(c.Param("eventId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event id"})
+		return
+	}
+
+	ticketTypeId, err := strconv.Atoi(c.Param("ticketTypeId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ticket type id"})
+		return
+	}
+
+	event, err := app.models.Events.GetEventById(eventId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve event"})
+		return
+	}
+
+	if event == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+
+	if !app.authorize(c, actionUpdateEvent, event) {
+		return
+	}
+
+	ticketType, ok := app.bindTicketType(c)
+	if !ok {
+		return
+	}
+
+	ticketType.ID = ticketTypeId
+	ticketType.EventId = event.ID
+
+	result, err := app.models.TicketTypes.Update(c, ticketType)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrTicketTypeNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Ticket type not found"})
+		case errors.Is(err, database.ErrTicketTypeExists), errors.Is(err, database.ErrQuantityBelowSold):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update ticket type"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// DeleteTicketType removes a ticket type from an event
+//
+//	@Summary		Removes a ticket type from an event
+//	@Description	Removes a ticket type that has never been sold. Ticket types that have been sold cannot be removed; end their sales window instead.
+//	@Tags			tickets
+//	@Produce		json
+//	@Param			eventId			path		int		true	"Event ID"
+//	@Param			ticketTypeId	path		int		true	"Ticket type ID"
+//	@Success		204				{string}	string	"No Content"
+//	@Router			/events/{eventId}/ticket-types/{ticketTypeId} [delete]
+//	@Security		BearerAuth
+func (app *application) deleteTicketType(c *gin.Context) {
+	eventId, err := strconv.Atoi(c.Param("eventId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event id"})
+		return
+	}
+
+	ticketTypeId, err := strconv.Atoi(c.Param("ticketTypeId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ticket type id"})
+		return
+	}
+
+	event, err := app.models.Events.GetEventById(eventId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve event"})
+		return
+	}
+
+	if event == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+
+	if !app.authorize(c, actionUpdateEvent, event) {
+		return
+	}
+
+	deleted, err := app.models.TicketTypes.Delete(c, event.ID, ticketTypeId)
+	if err != nil {
+		if errors.Is(err, database.ErrTicketTypeInUse) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete ticket type"})
+		return
+	}
+
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Ticket type not found"})
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// bindTicketType reads and validates a ticket type from the request body,
+// responding with 400 when it is invalid, and with 422 when it has a price
+// but the server has no payment provider to take it.
+func (app *application) bindTicketType(c *gin.Context) (*database.TicketType, bool) {
+	var ticketType database.TicketType
+	if err := c.ShouldBindJSON(&ticketType); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	if err := ticketType.ValidateSalesWindow(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	if ticketType.Price > 0 && app.paymentProvider == nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Paid tickets cannot be sold without a payment provider"})
+		return nil, false
+	}
+
+	return &ticketType, true
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"rest-api-event-app/internal/database"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/skip2/go-qrcode"
+)
+
+const ticketQRSize = 320
+
+var (
+	errMalformedTicket  = errors.New("malformed ticket")
+	errNoOpenOccurrence = errors.New("no occurrence of this event is open for check-in")
+)
+
+// ticket identifies the registration a ticket was issued for.
+type ticket struct {
+	EventId    int
+	AttendeeId int
+	Code       string
+}
+
+type ticketResponse struct {
+	Token string `json:"token"`
+}
+
+type checkInRequest struct {
+	Token      string     `json:"token" binding:"required"`
+	Occurrence *time.Time `json:"occurrence"`
+}
+
+type checkInResponse struct {
+	Attendee *database.Attendee `json:"attendee"`
+	CheckIn  *database.CheckIn  `json:"check_in"`
+	Name     string             `json:"name"`
+	Email    string             `json:"email"`
+}
+
+// signTicket returns the token encoded in a ticket's QR code. It names the
+// event, the registration and its ticket code, followed by an HMAC of the
+// three, so door staff can verify it without trusting the client.
+func (app *application) signTicket(t ticket) string {
+	payload := fmt.Sprintf("%d.%d.%s", t.EventId, t.AttendeeId, t.Code)
+	return payload + "." + app.ticketSignature(payload)
+}
+
+func (app *application) ticketSignature(payload string) string {
+	mac := hmac.New(sha256.New, app.ticketSecret)
+	mac.Write([]byte("ticket:v1:" + payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// parseTicket verifies the signature of a ticket token and returns what it
+// was issued for.
+func (app *application) parseTicket(token string) (ticket, error) {
+	i := strings.LastIndexByte(token, '.')
+	if i < 0 {
+		return ticket{}, errMalformedTicket
+	}
+
+	payload, signature := token[:i], token[i+1:]
+	if !hmac.Equal([]byte(signature), []byte(app.ticketSignature(payload))) {
+		return ticket{}, errMalformedTicket
+	}
+
+	parts := strings.Split(payload, ".")
+	if len(parts) != 3 {
+		return ticket{}, errMalformedTicket
+	}
+
+	eventId, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return ticket{}, errMalformedTicket
+	}
+
+	attendeeId, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return ticket{}, errMalformedTicket
+	}
+
+	return ticket{EventId: eventId, AttendeeId: attendeeId, Code: parts[2]}, nil
+}
+
+// GetMyTicket returns the authenticated user's ticket for an event
+//
+//	@Summary		Returns the authenticated user's ticket
+//	@Description	Returns the ticket of the authenticated user's confirmed registration as a QR code PNG, or as JSON holding the signed token when the Accept header asks for application/json. Door staff scan it with the check-in endpoint.
+//	@Tags			tickets
+//	@Produce		png
+//	@Produce		json
+//	@Param			eventId		path		int		true	"Event ID"
+//	@Param			occurrence	query		string	false	"Start of a single occurrence (RFC 3339)"
+//	@Success		200			{object}	ticketResponse
+//	@Router			/events/{eventId}/tickets/me [get]
+//	@Security		BearerAuth
+func (app *application) getMyTicket(c *gin.Context) {
+	eventId, err := strconv.Atoi(c.Param("eventId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event id"})
+		return
+	}
+
+	occurrence, ok := parseOccurrence(c)
+	if !ok {
+		return
+	}
+
+	user := app.GetUserFromContext(c)
+
+	attendee, err := app.models.Attendees.GetByEventAndAttendee(c, eventId, user.ID, occurrence)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve registration"})
+		return
+	}
+
+	if attendee == nil || attendee.Status == database.AttendeeStatusDeclined {
+		c.JSON(http.StatusNotFound, gin.H{"error": "You are not registered for this event"})
+		return
+	}
+
+	if attendee.Status != database.AttendeeStatusConfirmed {
+		c.JSON(http.StatusConflict, gin.H{"error": "Tickets are issued once your seat is confirmed"})
+		return
+	}
+
+	token := app.signTicket(ticket{EventId: attendee.EventId, AttendeeId: attendee.ID, Code: attendee.TicketCode})
+
+	if c.NegotiateFormat("image/png", gin.MIMEJSON) == gin.MIMEJSON {
+		c.JSON(http.StatusOK, ticketResponse{Token: token})
+		return
+	}
+
+	png, err := qrcode.Encode(token, qrcode.Medium, ticketQRSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render ticket"})
+		return
+	}
+
+	c.Header("Cache-Control", "private, no-store")
+	c.Data(http.StatusOK, "image/png", png)
+}
+
+// CheckInAttendee checks in the holder of a scanned ticket
+//
+//	@Summary		Checks in a ticket holder
+//	@Description	Verifies a scanned ticket token and checks its registration in to an occurrence of the event: the one named in the request, or else the one under way or about to start. Check-in to an occurrence opens a while before it starts (CHECK_IN_OPENS_BEFORE, two hours by default) and closes when it ends. A ticket for a single occurrence is only valid at that occurrence, a ticket for the whole series at each of them. Every ticket is accepted once per occurrence; scanning it again responds with 409 and the time of the first check-in.
+//	@Tags			tickets
+//	@Accept			json
+//	@Produce		json
+//	@Param			eventId	path		int				true	"Event ID"
+//	@Param			ticket	body		checkInRequest	true	"Scanned ticket"
+//	@Success		200		{object}	checkInResponse
+//	@Router			/events/{eventId}/check-in [post]
+//	@Security		BearerAuth
+func (app *application) checkInAttendee(c *gin.Context) {
+	eventId, err := strconv.Atoi(c.Param("eventId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event id"})
+		return
+	}
+
+	var request checkInRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	event, err := app.models.Events.GetEventById(eventId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve event"})
+		return
+	}
+
+	if event == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+
+	if !app.authorize(c, actionCheckIn, event) {
+		return
+	}
+
+	t, err := app.parseTicket(strings.TrimSpace(request.Token))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ticket"})
+		return
+	}
+
+	if t.EventId != event.ID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "This ticket is for another event"})
+		return
+	}
+
+	now := time.Now().UTC()
+	occurrence, err := app.checkInOccurrence(event, request.Occurrence, now)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrInvalidOccurrence):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, errNoOpenOccurrence):
+			c.JSON(http.StatusConflict, gin.H{"error": "No occurrence of this event is open for check-in"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check in"})
+		}
+		return
+	}
+
+	attendee, checkIn, err := app.models.Attendees.CheckIn(c, event.ID, t.AttendeeId, t.Code, occurrence, now)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrAlreadyCheckedIn):
+			c.JSON(http.StatusConflict, gin.H{"error": "Ticket already used", "occurrence": checkIn.Occurrence, "checked_in_at": checkIn.CheckedInAt})
+		case errors.Is(err, database.ErrWrongOccurrence):
+			c.JSON(http.StatusConflict, gin.H{"error": "This ticket is for another occurrence", "occurrence": attendee.Occurrence})
+		case errors.Is(err, database.ErrTicketUnconfirmed):
+			c.JSON(http.StatusConflict, gin.H{"error": "Registration is not confirmed"})
+		case errors.Is(err, database.ErrTicketInvalid):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Ticket has been cancelled"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check in"})
+		}
+		return
+	}
+
+	user, err := app.models.Users.GetUserById(attendee.UserId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve user"})
+		return
+	}
+
+	response := checkInResponse{Attendee: attendee, CheckIn: checkIn}
+	if user != nil {
+		response.Name, response.Email = user.Name, user.Email
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// checkInOccurrence returns the start of the occurrence of an event a ticket
+// scanned at t is checked in to: the requested one, or else the earliest one
+// open for check-in at t. An occurrence is open from checkInLead before its
+// start until its end.
+func (app *application) checkInOccurrence(event *database.Event, requested *time.Time, t time.Time) (time.Time, error) {
+	if requested == nil {
+		occurrence, err := event.OccurrenceAt(t, app.checkInLead)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if occurrence == nil {
+			return time.Time{}, errNoOpenOccurrence
+		}
+		return occurrence.StartsAt.UTC(), nil
+	}
+
+	if !event.IsOccurrence(*requested) {
+		return time.Time{}, database.ErrInvalidOccurrence
+	}
+
+	start := requested.UTC()
+	end := start.Add(event.EndsAt.Sub(event.StartsAt))
+	if t.Before(start.Add(-app.checkInLead)) || !t.Before(end) {
+		return time.Time{}, errNoOpenOccurrence
+	}
+
+	return start, nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"rest-api-event-app/internal/database"
+	"testing"
+	"time"
+)
+
+func TestCheckInOccurrence(t *testing.T) {
+	app := &application{checkInLead: time.Hour}
+
+	start := time.Date(2025, 1, 6, 18, 0, 0, 0, time.UTC)
+	event := &database.Event{StartsAt: start, EndsAt: start.Add(2 * time.Hour), TimeZone: "UTC", RecurrenceRule: "FREQ=WEEKLY;COUNT=3"}
+	second := start.AddDate(0, 0, 7)
+
+	tests := []struct {
+		name      string
+		requested *time.Time
+		t         time.Time
+		want      time.Time
+		wantErr   error
+	}{
+		{name: "occurrence under way", t: second.Add(30 * time.Minute), want: second},
+		{name: "doors open", t: second.Add(-time.Hour), want: second},
+		{name: "doors not open yet", t: second.Add(-time.Hour - time.Second), wantErr: errNoOpenOccurrence},
+		{name: "requested and open", requested: &second, t: second, want: second},
+		{name: "requested but over", requested: &start, t: second, wantErr: errNoOpenOccurrence},
+		{name: "requested time is no occurrence", requested: ptr(second.Add(time.Hour)), t: second, wantErr: database.ErrInvalidOccurrence},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := app.checkInOccurrence(event, tt.requested, tt.t)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && !got.Equal(tt.want) {
+				t.Errorf("occurrence = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTicket(t *testing.T) {
+	app := &application{ticketSecret: []byte("test secret")}
+	want := ticket{EventId: 3, AttendeeId: 42, Code: "0123abcd"}
+
+	token := app.signTicket(want)
+	got, err := app.parseTicket(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("parseTicket = %+v, want %+v", got, want)
+	}
+
+	other := &application{ticketSecret: []byte("another secret")}
+	if _, err := other.parseTicket(token); !errors.Is(err, errMalformedTicket) {
+		t.Errorf("ticket signed with another secret: got %v, want errMalformedTicket", err)
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"rest-api-event-app/internal/database"
+	"rest-api-event-app/internal/jobs"
+	"rest-api-event-app/internal/webhooks"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const jobDeliverWebhook = "webhooks.deliver"
+
+// webhookDispatcher sends webhooks from the job queue, one job per delivery.
+// A delivery that fails is retried with exponential backoff, from backoff up
+// to maxBackoff, and moved to the dead state after maxAttempts attempts.
+type webhookDispatcher struct {
+	sender      *webhooks.Sender
+	timeout     time.Duration
+	maxAttempts int
+	backoff     time.Duration
+	maxBackoff  time.Duration
+}
+
+type createWebhookRequest struct {
+	URL        string   `json:"url" binding:"required,url,max=2048"`
+	EventTypes []string `json:"event_types" binding:"required,min=1,dive,oneof=event.created event.updated event.deleted attendee.added attendee.removed"`
+}
+
+type createWebhookResponse struct {
+	database.WebhookSubscription
+	Secret string `json:"secret"`
+}
+
+type webhookDeliveriesQuery struct {
+	Status string `form:"status" binding:"omitempty,oneof=pending delivered dead"`
+	Limit  int    `form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+// CreateWebhook subscribes a URL to webhooks of the authenticated user's events
+//
+//	@Summary		Subscribes a URL to webhooks
+//	@Description	Subscribes a URL to webhooks about the events the authenticated user owns. Each webhook is a JSON POST carrying the event type and its data, with an X-Webhook-Signature header of the form t=<unix time>,v1=<signature>, where the signature is the hex HMAC-SHA256 of "<t>.<body>" keyed with the subscription's secret. The URL has to resolve to public addresses; loopback, private and link-local addresses are rejected, and receivers must not redirect. The secret is only returned here. Receivers must answer with a 2xx status; anything else is retried with exponential backoff until the delivery runs out of attempts and is marked dead. The payload id stays the same across retries.
+//	@Tags			webhooks
+//	@Accept			json
+//	@Produce		json
+//	@Param			webhook	body		createWebhookRequest	true	"Subscription"
+//	@Success		201		{object}	createWebhookResponse
+//	@Router			/webhooks [post]
+//	@Security		BearerAuth
+func (app *application) createWebhook(c *gin.Context) {
+	var request createWebhookRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := webhooks.ValidateURL(c, request.URL); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	secret, err := webhooks.GenerateSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create webhook"})
+		return
+	}
+
+	user := app.GetUserFromContext(c)
+
+	subscription, err := app.models.Webhooks.InsertSubscription(c, &database.WebhookSubscription{
+		UserId:     user.ID,
+		URL:        request.URL,
+		EventTypes: request.EventTypes,
+		Secret:     secret,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create webhook"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, createWebhookResponse{WebhookSubscription: *subscription, Secret: subscription.Secret})
+}
+
+// GetWebhooks returns the webhook subscriptions of the authenticated user
+//
+//	@Summary		Returns the authenticated user's webhook subscriptions
+//	@Description	Returns the authenticated user's webhook subscriptions, without their secrets
+//	@Tags			webhooks
+//	@Produce		json
+//	@Success		200	{array}	database.WebhookSubscription
+//	@Router			/webhooks [get]
+//	@Security		BearerAuth
+func (app *application) getWebhooks(c *gin.Context) {
+	user := app.GetUserFromContext(c)
+
+	subscriptions, err := app.models.Webhooks.GetSubscriptionsByUser(c, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve webhooks"})
+		return
+	}
+
+	c.JSON(http.StatusOK, subscriptions)
+}
+
+// DeleteWebhook removes a webhook subscription
+//
+//	@Summary		Removes a webhook subscription
+//	@Description	Removes a webhook subscription of the authenticated user together with its delivery log. Deliveries still pending are dropped.
+//	@Tags			webhooks
+//	@Produce		json
+//	@Param			webhookId	path		int		true	"Webhook ID"
+//	@Success		204			{string}	string	"No Content"
+//	@Router			/webhooks/{webhookId} [delete]
+//	@Security		BearerAuth
+func (app *application) deleteWebhook(c *gin.Context) {
+	webhookId, err := strconv.Atoi(c.Param("webhookId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook id"})
+		return
+	}
+
+	user := app.GetUserFromContext(c)
+
+	deleted, err := app.models.Webhooks.DeleteSubscription(c, user.ID, webhookId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete webhook"})
+		return
+	}
+
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// GetWebhookDeliveries returns the delivery log of a webhook subscription
+//
+//	@Summary		Returns the delivery log of a webhook subscription
+//	@Description	Returns the deliveries of a webhook subscription, newest first, with their payload, the number of attempts made, and the status code or error of the latest one. Pending deliveries show when they are next attempted; dead ones ran out of attempts.
+//	@Tags			webhooks
+//	@Produce		json
+//	@Param			webhookId	path	int		true	"Webhook ID"
+//	@Param			status		query	string	false	"Only deliveries in this status"	Enums(pending, delivered, dead)
+//	@Param			limit		query	int		false	"Maximum number of deliveries (max 100, default 50)"
+//	@Success		200			{array}	database.WebhookDelivery
+//	@Router			/webhooks/{webhookId}/deliveries [get]
+//	@Security		BearerAuth
+func (app *application) getWebhookDeliveries(c *gin.Context) {
+	subscription, ok := app.webhookSubscription(c)
+	if !ok {
+		return
+	}
+
+	var query webhookDeliveriesQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if query.Limit == 0 {
+		query.Limit = 50
+	}
+
+	deliveries, err := app.models.Webhooks.GetDeliveries(c, subscription.ID, query.Status, query.Limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve deliveries"})
+		return
+	}
+
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// RedeliverWebhook sends a dead webhook delivery again
+//
+//	@Summary		Sends a dead webhook delivery again
+//	@Description	Queues a dead delivery to be sent right away, with a fresh set of attempts. Only dead deliveries can be redelivered.
+//	@Tags			webhooks
+//	@Produce		json
+//	@Param			webhookId	path		int		true	"Webhook ID"
+//	@Param			deliveryId	path		int		true	"Delivery ID"
+//	@Success		202			{string}	string	"Accepted"
+//	@Router			/webhooks/{webhookId}/deliveries/{deliveryId}/redeliver [post]
+//	@Security		BearerAuth
+func (app *application) redeliverWebhook(c *gin.Context) {
+	deliveryId, err := strconv.Atoi(c.Param("deliveryId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid delivery id"})
+		return
+	}
+
+	subscription, ok := app.webhookSubscription(c)
+	if !ok {
+		return
+	}
+
+	queued, err := app.models.Webhooks.Redeliver(c, subscription.ID, deliveryId, app.webhookJob())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to redeliver webhook"})
+		return
+	}
+
+	if !queued {
+		c.JSON(http.StatusConflict, gin.H{"error": "Only dead deliveries of this webhook can be redelivered"})
+		return
+	}
+
+	app.jobs.Notify()
+
+	c.JSON(http.StatusAccepted, nil)
+}
+
+// webhookSubscription loads the subscription of a webhook request, responding
+// with 404 when the authenticated user has no such subscription.
+func (app *application) webhookSubscription(c *gin.Context) (*database.WebhookSubscription, bool) {
+	webhookId, err := strconv.Atoi(c.Param("webhookId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook id"})
+		return nil, false
+	}
+
+	user := app.GetUserFromContext(c)
+
+	subscription, err := app.models.Webhooks.GetSubscription(c, user.ID, webhookId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve webhook"})
+		return nil, false
+	}
+
+	if subscription == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return nil, false
+	}
+
+	return subscription, true
+}
+
+// publishWebhook queues an outbox message as a webhook for the
+// subscriptions of the owner of its event, with data as the payload data.
+// A message handled again, because a later step failed, is not queued again
+// for the same subscription. The payload id is derived from the message, so
+// a delivery a receiver sees twice, as after a timeout, is still
+// recognisable as a duplicate.
+func (app *application) publishWebhook(ctx context.Context, message *database.OutboxMessage, data any) error {
+	body, err := json.Marshal(webhooks.Payload{
+		ID:        fmt.Sprintf("evt_%d", message.ID),
+		Type:      message.Topic,
+		CreatedAt: message.CreatedAt.UTC(),
+		Data:      data,
+	})
+	if err != nil {
+		return err
+	}
+
+	queued, err := app.models.Webhooks.Enqueue(ctx, message.OwnerId, message.ID, message.Topic, body, app.webhookJob())
+	if err != nil {
+		return err
+	}
+
+	if queued > 0 {
+		app.jobs.Notify()
+	}
+
+	return nil
+}
+
+// webhookJob returns the job that sends a delivery right away. The
+// dispatcher decides on retries itself, so the job gets one attempt more
+// than the delivery, leaving room for a run cut short by a crash.
+func (app *application) webhookJob() database.Job {
+	job := app.jobs.NewJob(jobDeliverWebhook)
+	job.MaxAttempts = app.webhooks.maxAttempts + 1
+	return job
+}
+
+// deliverWebhook sends a delivery and records the outcome in its log. A
+// failed attempt is retried after the dispatcher's backoff until the
+// delivery runs out of attempts and is marked dead.
+func (app *application) deliverWebhook(ctx context.Context, job *database.Job) error {
+	var payload database.WebhookJob
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return jobs.Permanent(err)
+	}
+
+	// The delivery went out on an earlier run, or its subscription was
+	// removed.
+	webhook, err := app.models.Webhooks.GetDue(ctx, payload.DeliveryId)
+	if err != nil || webhook == nil {
+		return err
+	}
+
+	d := &app.webhooks
+
+	sendCtx, cancel := context.WithTimeout(ctx, d.timeout)
+	statusCode, sendErr := d.sender.Send(sendCtx, webhooks.Request{
+		DeliveryID: webhook.ID,
+		URL:        webhook.URL,
+		Secret:     webhook.Secret,
+		EventType:  webhook.EventType,
+		Body:       webhook.Payload,
+	})
+	cancel()
+
+	// The pool gives the job back when it is cancelled while draining; the
+	// attempt is not counted against the delivery either.
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	now := time.Now().UTC()
+	if sendErr == nil {
+		return app.models.Webhooks.MarkDelivered(ctx, webhook.ID, statusCode, now)
+	}
+
+	attempts := webhook.Attempts + 1
+	if attempts >= d.maxAttempts || job.Attempts >= job.MaxAttempts {
+		if err := app.models.Webhooks.MarkFailed(ctx, webhook.ID, statusCode, sendErr.Error(), nil); err != nil {
+			return err
+		}
+		return jobs.Permanent(sendErr)
+	}
+
+	delay := webhooks.Backoff(attempts, d.backoff, d.maxBackoff)
+	next := now.Add(delay)
+	if err := app.models.Webhooks.MarkFailed(ctx, webhook.ID, statusCode, sendErr.Error(), &next); err != nil {
+		return err
+	}
+
+	return jobs.RetryAfter(sendErr, delay)
+}
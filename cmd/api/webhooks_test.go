@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"rest-api-event-app/internal/database"
+	"rest-api-event-app/internal/jobs"
+	"rest-api-event-app/internal/testdb"
+	"rest-api-event-app/internal/webhooks"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// webhookReceiver is a subscriber's endpoint. It answers with the given
+// status codes in turn, repeating the last one, and records every request
+// along with how many were not signed with its secret.
+type webhookReceiver struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	secret   string
+	statuses []int
+	bodies   [][]byte
+	ids      []string
+	invalid  int
+}
+
+func newWebhookReceiver(t *testing.T, statuses ...int) *webhookReceiver {
+	r := &webhookReceiver{statuses: statuses}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			t.Errorf("reading webhook: %s", err)
+		}
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		if err := webhooks.Verify(r.secret, req.Header.Get(webhooks.SignatureHeader), body, time.Minute, time.Now()); err != nil {
+			r.invalid++
+		}
+		r.bodies = append(r.bodies, body)
+		r.ids = append(r.ids, req.Header.Get(webhooks.DeliveryHeader))
+
+		status := r.statuses[min(len(r.bodies), len(r.statuses))-1]
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(r.Close)
+
+	return r
+}
+
+func (r *webhookReceiver) received() ([][]byte, []string, int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.bodies, r.ids, r.invalid
+}
+
+// newWebhookTest subscribes a receiver answering with statuses to
+// attendee.added webhooks and queues one, returning the delivery.
+func newWebhookTest(t *testing.T, maxAttempts int, statuses ...int) (*application, *webhookReceiver, *database.WebhookDelivery) {
+	t.Helper()
+
+	ctx := context.Background()
+	models := database.NewModels(testdb.Open(t))
+
+	app := &application{
+		models: models,
+		webhooks: webhookDispatcher{
+			sender:      &webhooks.Sender{},
+			timeout:     5 * time.Second,
+			maxAttempts: maxAttempts,
+			backoff:     time.Minute,
+			maxBackoff:  time.Hour,
+		},
+		jobs: jobs.NewPool(&models.Jobs, jobs.Config{}),
+	}
+
+	user := createTestUser(t, &models)
+	receiver := newWebhookReceiver(t, statuses...)
+
+	subscription, err := models.Webhooks.InsertSubscription(ctx, &database.WebhookSubscription{
+		UserId:     user.ID,
+		URL:        receiver.URL,
+		EventTypes: []string{webhooks.AttendeeAdded},
+		Secret:     "whsec_test",
+	})
+	if err != nil {
+		t.Fatalf("creating subscription: %s", err)
+	}
+
+	receiver.mu.Lock()
+	receiver.secret = subscription.Secret
+	receiver.mu.Unlock()
+
+	body := []byte(`{"id":"evt_1","type":"attendee.added","created_at":"2025-06-01T10:00:00Z","data":{}}`)
+	if _, err := models.Webhooks.Enqueue(ctx, user.ID, time.Now().UnixNano(), webhooks.AttendeeAdded, body, app.webhookJob()); err != nil {
+		t.Fatalf("queueing webhook: %s", err)
+	}
+
+	deliveries, err := models.Webhooks.GetDeliveries(ctx, subscription.ID, "", 10)
+	if err != nil || len(deliveries) != 1 {
+		t.Fatalf("getting deliveries: %v, %v", deliveries, err)
+	}
+
+	return app, receiver, deliveries[0]
+}
+
+// attemptWebhook runs the attempt-th job of a delivery and returns the
+// delivery log afterwards.
+func attemptWebhook(t *testing.T, app *application, delivery *database.WebhookDelivery, attempt int) (*database.WebhookDelivery, error) {
+	t.Helper()
+
+	payload, err := json.Marshal(database.WebhookJob{DeliveryId: delivery.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	job := app.webhookJob()
+	job.Payload = payload
+	job.Attempts = attempt
+
+	jobErr := app.deliverWebhook(context.Background(), &job)
+
+	deliveries, err := app.models.Webhooks.GetDeliveries(context.Background(), delivery.SubscriptionId, "", 10)
+	if err != nil || len(deliveries) != 1 {
+		t.Fatalf("getting deliveries: %v, %v", deliveries, err)
+	}
+
+	return deliveries[0], jobErr
+}
+
+func TestDeliverWebhookRetriesWithBackoff(t *testing.T) {
+	app, receiver, delivery := newWebhookTest(t, 5, http.StatusServiceUnavailable, http.StatusInternalServerError, http.StatusNoContent)
+
+	for attempt, status := range []int{http.StatusServiceUnavailable, http.StatusInternalServerError} {
+		before := time.Now().UTC()
+		logged, err := attemptWebhook(t, app, delivery, attempt+1)
+		if err == nil {
+			t.Fatalf("attempt %d: the job succeeded, want it retried", attempt+1)
+		}
+
+		if logged.Status != database.WebhookDeliveryPending || logged.Attempts != attempt+1 {
+			t.Fatalf("attempt %d: delivery %+v, want it pending after %d attempts", attempt+1, logged, attempt+1)
+		}
+
+		if logged.LastStatusCode == nil || *logged.LastStatusCode != status || logged.LastError == "" {
+			t.Errorf("attempt %d: logged status %v and error %q, want %d", attempt+1, logged.LastStatusCode, logged.LastError, status)
+		}
+
+		backoff := webhooks.Backoff(attempt+1, time.Minute, time.Hour)
+		if logged.NextAttemptAt == nil {
+			t.Fatalf("attempt %d: no next attempt logged", attempt+1)
+		}
+		if next := logged.NextAttemptAt.Sub(before); next < backoff-time.Second || next > backoff+5*time.Second {
+			t.Errorf("attempt %d: next attempt in %s, want %s", attempt+1, next, backoff)
+		}
+	}
+
+	logged, err := attemptWebhook(t, app, delivery, 3)
+	if err != nil {
+		t.Fatalf("attempt 3: %s", err)
+	}
+
+	if logged.Status != database.WebhookDeliveryDelivered || logged.Attempts != 3 || logged.DeliveredAt == nil || logged.LastError != "" {
+		t.Errorf("after delivery: %+v, want it delivered after 3 attempts", logged)
+	}
+
+	bodies, ids, invalid := receiver.received()
+	if len(bodies) != 3 || invalid != 0 {
+		t.Fatalf("receiver got %d requests, %d with a bad signature; want 3 signed ones", len(bodies), invalid)
+	}
+
+	for i, body := range bodies {
+		if string(body) != string(delivery.Payload) || ids[i] != strconv.Itoa(delivery.ID) {
+			t.Errorf("receiver got delivery %s with %s, want delivery %d with the same payload on every attempt", ids[i], body, delivery.ID)
+		}
+	}
+}
+
+func TestDeliverWebhookDeadLetters(t *testing.T) {
+	app, receiver, delivery := newWebhookTest(t, 3, http.StatusBadGateway)
+
+	for attempt := 1; attempt <= 3; attempt++ {
+		logged, err := attemptWebhook(t, app, delivery, attempt)
+		if err == nil {
+			t.Fatalf("attempt %d: the job succeeded, want it to fail", attempt)
+		}
+
+		want := database.WebhookDeliveryPending
+		if attempt == 3 {
+			want = database.WebhookDeliveryDead
+		}
+
+		if logged.Status != want || logged.Attempts != attempt {
+			t.Fatalf("attempt %d: delivery %+v, want it %s after %d attempts", attempt, logged, want, attempt)
+		}
+	}
+
+	logged, err := attemptWebhook(t, app, delivery, 4)
+	if err != nil {
+		t.Fatalf("running the job of a dead delivery: %s", err)
+	}
+
+	if logged.Status != database.WebhookDeliveryDead || logged.NextAttemptAt != nil || logged.LastStatusCode == nil || *logged.LastStatusCode != http.StatusBadGateway {
+		t.Errorf("dead delivery: %+v, want it dead with the last status code", logged)
+	}
+
+	if bodies, _, _ := receiver.received(); len(bodies) != 3 {
+		t.Errorf("receiver got %d requests, want no more after the delivery died", len(bodies))
+	}
+
+	// Redelivering gives the dead delivery a fresh set of attempts.
+	queued, err := app.models.Webhooks.Redeliver(context.Background(), delivery.SubscriptionId, delivery.ID, app.webhookJob())
+	if err != nil || !queued {
+		t.Fatalf("redelivering: %v, %v", queued, err)
+	}
+
+	logged, _ = attemptWebhook(t, app, delivery, 1)
+	if logged.Status != database.WebhookDeliveryPending || logged.Attempts != 1 {
+		t.Errorf("after redelivery: %+v, want it pending after 1 attempt", logged)
+	}
+}
+
+func TestCreateWebhookRejectsPrivateAddresses(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	app := &application{}
+
+	for _, target := range []string{"http://127.0.0.1:6379/", "http://10.0.0.5/hook", "http://169.254.169.254/latest/meta-data/"} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/webhooks", strings.NewReader(`{"url":"`+target+`","event_types":["event.created"]}`))
+		c.Request.Header.Set("Content-Type", "application/json")
+
+		app.createWebhook(c)
+
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), webhooks.ErrPrivateAddress.Error()) {
+			t.Errorf("subscribing %s: got %d %s, want 400", target, w.Code, w.Body)
+		}
+	}
+}
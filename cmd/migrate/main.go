@@ -0,0 +1,257 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"rest-api-event-app/cmd/migrate/migrations"
+	"rest-api-event-app/internal/database"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/joho/godotenv"
+)
+
+const usage = `Usage: migrate [flags] <command> [arguments]
+
+Commands:
+  up [N]         apply all pending migrations, or the next N
+  down N         roll back the last N migrations
+  down -all      roll back every migration
+  goto V         migrate up or down to version V
+  status         show the current version and every migration
+  force V        set the version without running migrations, to clear a dirty state
+  create NAME    create a new pair of up and down migration files
+  promote-admin EMAIL
+                 give the user registered with EMAIL the admin role. Admins
+                 manage roles through the API, so this is how the first one
+                 of a new installation is made.
+
+Flags:
+`
+
+var migrationName = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+func main() {
+	dir := flag.String("dir", "cmd/migrate/migrations", "directory where create writes new migrations")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if args[0] == "create" {
+		if len(args) != 2 {
+			log.Fatal("create requires exactly one NAME")
+		}
+		if err := create(*dir, args[1]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// The environment may be configured without a .env file, for example in
+	// containers, so a missing file is not an error here.
+	_ = godotenv.Load()
+
+	dbConn, err := database.NewDatabase()
+	if err != nil {
+		log.Fatalf("Could not initialize database connection: %s", err)
+	}
+
+	defer dbConn.CloseDB()
+
+	if args[0] == "promote-admin" {
+		if len(args) != 2 {
+			log.Fatal("promote-admin requires exactly one EMAIL")
+		}
+		if err := promoteAdmin(database.NewModels(dbConn.GetDB()).Users, args[1]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	m, err := migrations.New(dbConn.GetDB())
+	if err != nil {
+		log.Fatalf("Could not initialize migrations: %s", err)
+	}
+
+	defer m.Close()
+
+	if err := run(m, args[0], args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(m *migrate.Migrate, command string, args []string) error {
+	switch command {
+	case "up":
+		if len(args) == 0 {
+			return ignoreNoChange(m.Up())
+		}
+		n, err := parseSteps(args)
+		if err != nil {
+			return err
+		}
+		return ignoreNoChange(m.Steps(n))
+
+	case "down":
+		if len(args) == 1 && args[0] == "-all" {
+			return ignoreNoChange(m.Down())
+		}
+		n, err := parseSteps(args)
+		if err != nil {
+			return fmt.Errorf("down requires N or -all: %w", err)
+		}
+		return ignoreNoChange(m.Steps(-n))
+
+	case "goto":
+		version, err := parseVersion(args)
+		if err != nil {
+			return err
+		}
+		return ignoreNoChange(m.Migrate(uint(version)))
+
+	case "force":
+		version, err := parseVersion(args)
+		if err != nil {
+			return err
+		}
+		return m.Force(version)
+
+	case "status":
+		return status(m)
+	}
+
+	return fmt.Errorf("unknown command %q", command)
+}
+
+func status(m *migrate.Migrate) error {
+	current, dirty, err := m.Version()
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		return err
+	}
+
+	list, err := migrations.List()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case current == 0:
+		fmt.Println("Current version: none")
+	case dirty:
+		fmt.Printf("Current version: %d (dirty, fix the database and run force)\n", current)
+	default:
+		fmt.Printf("Current version: %d\n", current)
+	}
+
+	fmt.Println()
+	for _, migration := range list {
+		state := "pending"
+		if migration.Version <= current {
+			state = "applied"
+		}
+		if migration.Version == current && dirty {
+			state = "dirty"
+		}
+		fmt.Printf("%-8s %d_%s\n", state, migration.Version, migration.Identifier)
+	}
+
+	return nil
+}
+
+func create(dir, name string) error {
+	name = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(name), "-", "_"))
+	if !migrationName.MatchString(name) {
+		return fmt.Errorf("invalid migration name %q: use letters, digits and underscores", name)
+	}
+
+	version := time.Now().UTC().Format("20060102150405")
+
+	for _, direction := range []string{"up", "down"} {
+		path := filepath.Join(dir, fmt.Sprintf("%s_%s.%s.sql", version, name, direction))
+
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err != nil {
+			return err
+		}
+		file.Close()
+
+		fmt.Println("Created", path)
+	}
+
+	return nil
+}
+
+// promoteAdmin gives an existing user the admin role.
+func promoteAdmin(users database.UserModel, email string) error {
+	user, err := users.GetUserByEmail(strings.TrimSpace(email))
+	if err != nil {
+		return err
+	}
+
+	if user == nil {
+		return fmt.Errorf("no user is registered with %q", email)
+	}
+
+	if user.Role == database.RoleAdmin {
+		fmt.Printf("%s is already an admin\n", user.Email)
+		return nil
+	}
+
+	if err := users.UpdateRole(context.Background(), user.ID, database.RoleAdmin); err != nil {
+		return err
+	}
+
+	fmt.Printf("%s is now an admin\n", user.Email)
+	return nil
+}
+
+func parseSteps(args []string) (int, error) {
+	if len(args) != 1 {
+		return 0, errors.New("expected a single number of steps")
+	}
+
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid number of steps %q", args[0])
+	}
+
+	return n, nil
+}
+
+func parseVersion(args []string) (int, error) {
+	if len(args) != 1 {
+		return 0, errors.New("expected a single version")
+	}
+
+	version, err := strconv.Atoi(args[0])
+	if err != nil || version < 0 {
+		return 0, fmt.Errorf("invalid version %q", args[0])
+	}
+
+	return version, nil
+}
+
+func ignoreNoChange(err error) error {
+	if errors.Is(err, migrate.ErrNoChange) {
+		fmt.Println("No change")
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,7 @@
+ALTER TABLE attendees
+  DROP INDEX idx_attendees_event_status,
+  DROP COLUMN created_at,
+  DROP COLUMN status;
+
+ALTER TABLE events
+  DROP COLUMN capacity;
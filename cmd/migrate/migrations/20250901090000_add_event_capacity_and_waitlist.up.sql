@@ -0,0 +1,7 @@
+ALTER TABLE events
+  ADD COLUMN capacity INT NOT NULL DEFAULT 0;
+
+ALTER TABLE attendees
+  ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'confirmed',
+  ADD COLUMN created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  ADD INDEX idx_attendees_event_status (event_id, status, created_at);
@@ -0,0 +1,3 @@
+ALTER TABLE attendees
+  ADD INDEX idx_attendees_event_id (event_id),
+  DROP INDEX uq_attendees_event_user;
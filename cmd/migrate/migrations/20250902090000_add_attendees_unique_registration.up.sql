@@ -0,0 +1,8 @@
+DELETE a1 FROM attendees a1
+JOIN attendees a2
+  ON a1.event_id = a2.event_id
+ AND a1.user_id = a2.user_id
+ AND a1.id > a2.id;
+
+ALTER TABLE attendees
+  ADD UNIQUE KEY uq_attendees_event_user (event_id, user_id);
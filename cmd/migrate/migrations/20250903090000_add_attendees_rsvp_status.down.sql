@@ -0,0 +1,4 @@
+DELETE FROM attendees WHERE status = 'declined';
+
+ALTER TABLE attendees
+  DROP COLUMN rsvp_status;
@@ -0,0 +1,2 @@
+ALTER TABLE attendees
+  ADD COLUMN rsvp_status VARCHAR(20) NOT NULL DEFAULT 'going';
@@ -0,0 +1,3 @@
+ALTER TABLE events
+  DROP INDEX idx_events_name_id,
+  DROP INDEX idx_events_date_id;
@@ -0,0 +1,3 @@
+ALTER TABLE events
+  ADD INDEX idx_events_date_id (date, id),
+  ADD INDEX idx_events_name_id (name, id);
@@ -0,0 +1,2 @@
+ALTER TABLE events
+  DROP INDEX ft_events_search;
@@ -0,0 +1,2 @@
+ALTER TABLE events
+  ADD FULLTEXT INDEX ft_events_search (name, description, location);
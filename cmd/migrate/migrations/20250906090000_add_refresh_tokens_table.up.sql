@@ -0,0 +1,19 @@
+CREATE TABLE refresh_tokens (
+  id INT PRIMARY KEY AUTO_INCREMENT,
+  user_id INT NOT NULL,
+  token_hash CHAR(64) NOT NULL UNIQUE,
+  access_token_id VARCHAR(64) NOT NULL,
+  access_expires_at DATETIME NOT NULL,
+  expires_at DATETIME NOT NULL,
+  created_at DATETIME NOT NULL,
+  revoked_at DATETIME NULL,
+  INDEX idx_refresh_tokens_user (user_id, revoked_at),
+  INDEX idx_refresh_tokens_access (access_token_id),
+  FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB;
+
+CREATE TABLE revoked_tokens (
+  jti VARCHAR(64) PRIMARY KEY,
+  expires_at DATETIME NOT NULL,
+  INDEX idx_revoked_tokens_expires (expires_at)
+) ENGINE=InnoDB;
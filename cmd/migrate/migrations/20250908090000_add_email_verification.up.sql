@@ -0,0 +1,14 @@
+ALTER TABLE users
+  ADD COLUMN verified_at DATETIME NULL;
+
+UPDATE users SET verified_at = CURRENT_TIMESTAMP;
+
+CREATE TABLE email_verification_tokens (
+  id INT PRIMARY KEY AUTO_INCREMENT,
+  user_id INT NOT NULL,
+  token_hash CHAR(64) NOT NULL UNIQUE,
+  expires_at DATETIME NOT NULL,
+  created_at DATETIME NOT NULL,
+  used_at DATETIME NULL,
+  FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB;
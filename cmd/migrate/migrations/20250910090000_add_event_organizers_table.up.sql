@@ -0,0 +1,11 @@
+CREATE TABLE event_organizers (
+  event_id INT NOT NULL,
+  user_id INT NOT NULL,
+  can_edit BOOLEAN NOT NULL DEFAULT FALSE,
+  can_manage_attendees BOOLEAN NOT NULL DEFAULT FALSE,
+  can_check_in BOOLEAN NOT NULL DEFAULT FALSE,
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (event_id, user_id),
+  FOREIGN KEY(event_id) REFERENCES events(id) ON DELETE CASCADE,
+  FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB;
@@ -0,0 +1,5 @@
+ALTER TABLE events
+  DROP COLUMN timezone,
+  DROP COLUMN ends_at,
+  RENAME INDEX idx_events_starts_at_id TO idx_events_date_id,
+  RENAME COLUMN starts_at TO date;
@@ -0,0 +1,11 @@
+ALTER TABLE events
+  RENAME COLUMN date TO starts_at,
+  RENAME INDEX idx_events_date_id TO idx_events_starts_at_id,
+  ADD COLUMN ends_at DATETIME NULL AFTER starts_at,
+  ADD COLUMN timezone VARCHAR(64) NOT NULL DEFAULT 'UTC' AFTER ends_at;
+
+-- Existing events only had a date, so they become all-day events.
+UPDATE events SET ends_at = DATE_ADD(starts_at, INTERVAL 1 DAY);
+
+ALTER TABLE events
+  MODIFY COLUMN ends_at DATETIME NOT NULL;
@@ -0,0 +1,12 @@
+DELETE FROM attendees WHERE occurrence_start IS NOT NULL;
+
+ALTER TABLE attendees
+  ADD UNIQUE KEY uq_attendees_event_user (event_id, user_id),
+  DROP INDEX uq_attendees_event_user_occurrence,
+  DROP COLUMN occurrence_key,
+  DROP COLUMN occurrence_start;
+
+ALTER TABLE events
+  DROP COLUMN series_ends_at,
+  DROP COLUMN exception_dates,
+  DROP COLUMN recurrence_rule;
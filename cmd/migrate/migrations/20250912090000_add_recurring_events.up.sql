@@ -0,0 +1,15 @@
+ALTER TABLE events
+  ADD COLUMN recurrence_rule VARCHAR(500) NOT NULL DEFAULT '' AFTER timezone,
+  ADD COLUMN exception_dates TEXT NULL AFTER recurrence_rule,
+  ADD COLUMN series_ends_at DATETIME NULL AFTER exception_dates;
+
+-- Every existing event is a single occurrence, so its series ends with it.
+UPDATE events SET series_ends_at = ends_at;
+
+-- A NULL occurrence_start registers the user for the whole series. NULLs are
+-- distinct in unique keys, so the key uses a generated column instead.
+ALTER TABLE attendees
+  ADD COLUMN occurrence_start DATETIME NULL AFTER user_id,
+  ADD COLUMN occurrence_key DATETIME AS (IFNULL(occurrence_start, '1000-01-01 00:00:00')) STORED,
+  ADD UNIQUE KEY uq_attendees_event_user_occurrence (event_id, user_id, occurrence_key),
+  DROP INDEX uq_attendees_event_user;
@@ -0,0 +1,6 @@
+CREATE TABLE calendar_feeds (
+  user_id INT PRIMARY KEY,
+  token_hash CHAR(64) NOT NULL UNIQUE,
+  created_at DATETIME NOT NULL,
+  FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB;
@@ -0,0 +1,2 @@
+ALTER TABLE attendees
+  ADD COLUMN checked_in_at DATETIME NULL AFTER rsvp_status;
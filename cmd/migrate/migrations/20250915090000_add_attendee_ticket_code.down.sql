@@ -0,0 +1,2 @@
+ALTER TABLE attendees
+  DROP COLUMN ticket_code;
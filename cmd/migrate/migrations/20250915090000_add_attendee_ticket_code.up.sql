@@ -0,0 +1,8 @@
+ALTER TABLE attendees
+  ADD COLUMN ticket_code CHAR(32) NULL AFTER checked_in_at;
+
+-- Registrations made before tickets existed get a ticket as well.
+UPDATE attendees SET ticket_code = REPLACE(UUID(), '-', '');
+
+ALTER TABLE attendees
+  MODIFY COLUMN ticket_code CHAR(32) NOT NULL;
@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS payments;
+
+ALTER TABLE attendees
+  DROP FOREIGN KEY fk_attendees_ticket_type,
+  DROP INDEX idx_attendees_ticket_type,
+  DROP COLUMN ticket_type_id;
+
+DROP TABLE IF EXISTS ticket_types;
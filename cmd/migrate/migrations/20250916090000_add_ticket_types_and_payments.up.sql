@@ -0,0 +1,39 @@
+CREATE TABLE ticket_types (
+  id INT AUTO_INCREMENT PRIMARY KEY,
+  event_id INT NOT NULL,
+  name VARCHAR(100) NOT NULL,
+  price BIGINT NOT NULL DEFAULT 0,
+  currency CHAR(3) NOT NULL,
+  quantity INT NOT NULL DEFAULT 0,
+  sales_start_at DATETIME NULL,
+  sales_end_at DATETIME NULL,
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  UNIQUE KEY uq_ticket_types_event_name (event_id, name),
+  FOREIGN KEY(event_id) REFERENCES events(id) ON DELETE CASCADE
+) ENGINE=InnoDB;
+
+ALTER TABLE attendees
+  ADD COLUMN ticket_type_id INT NULL AFTER occurrence_start,
+  ADD INDEX idx_attendees_ticket_type (ticket_type_id, status),
+  ADD CONSTRAINT fk_attendees_ticket_type FOREIGN KEY (ticket_type_id) REFERENCES ticket_types(id);
+
+CREATE TABLE payments (
+  id INT AUTO_INCREMENT PRIMARY KEY,
+  event_id INT NOT NULL,
+  user_id INT NOT NULL,
+  attendee_id INT NULL,
+  ticket_type_id INT NOT NULL,
+  amount BIGINT NOT NULL,
+  currency CHAR(3) NOT NULL,
+  status VARCHAR(20) NOT NULL,
+  charge_id VARCHAR(255) NULL,
+  refunded_amount BIGINT NOT NULL DEFAULT 0,
+  failure_reason VARCHAR(255) NULL,
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  INDEX idx_payments_event (event_id, created_at),
+  FOREIGN KEY(event_id) REFERENCES events(id) ON DELETE CASCADE,
+  FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+  FOREIGN KEY(attendee_id) REFERENCES attendees(id) ON DELETE SET NULL,
+  FOREIGN KEY(ticket_type_id) REFERENCES ticket_types(id)
+) ENGINE=InnoDB;
@@ -0,0 +1,5 @@
+ALTER TABLE payments
+  DROP COLUMN discount_amount;
+
+DROP TABLE IF EXISTS promo_redemptions;
+DROP TABLE IF EXISTS promo_codes;
@@ -0,0 +1,35 @@
+CREATE TABLE promo_codes (
+  id INT AUTO_INCREMENT PRIMARY KEY,
+  event_id INT NOT NULL,
+  code VARCHAR(50) NOT NULL,
+  discount_type VARCHAR(10) NOT NULL,
+  discount_value BIGINT NOT NULL,
+  currency CHAR(3) NULL,
+  max_uses INT NOT NULL DEFAULT 0,
+  times_redeemed INT NOT NULL DEFAULT 0,
+  expires_at DATETIME NULL,
+  ticket_type_ids TEXT NULL,
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  UNIQUE KEY uq_promo_codes_event_code (event_id, code),
+  FOREIGN KEY(event_id) REFERENCES events(id) ON DELETE CASCADE
+) ENGINE=InnoDB;
+
+CREATE TABLE promo_redemptions (
+  id INT AUTO_INCREMENT PRIMARY KEY,
+  promo_code_id INT NOT NULL,
+  user_id INT NOT NULL,
+  ticket_type_id INT NOT NULL,
+  payment_id INT NULL,
+  original_amount BIGINT NOT NULL,
+  discount_amount BIGINT NOT NULL,
+  currency CHAR(3) NOT NULL,
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  INDEX idx_promo_redemptions_code (promo_code_id, created_at),
+  FOREIGN KEY(promo_code_id) REFERENCES promo_codes(id) ON DELETE CASCADE,
+  FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+  FOREIGN KEY(ticket_type_id) REFERENCES ticket_types(id),
+  FOREIGN KEY(payment_id) REFERENCES payments(id) ON DELETE SET NULL
+) ENGINE=InnoDB;
+
+ALTER TABLE payments
+  ADD COLUMN discount_amount BIGINT NOT NULL DEFAULT 0 AFTER amount;
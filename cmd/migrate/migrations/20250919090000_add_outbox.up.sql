@@ -0,0 +1,13 @@
+CREATE TABLE outbox (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  topic VARCHAR(100) NOT NULL,
+  event_id INT NOT NULL,
+  owner_id INT NOT NULL,
+  payload MEDIUMTEXT NOT NULL,
+  attempts INT NOT NULL DEFAULT 0,
+  next_attempt_at DATETIME NOT NULL,
+  last_error VARCHAR(500) NULL,
+  published_at DATETIME NULL,
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  INDEX idx_outbox_pending (published_at, next_attempt_at, id)
+) ENGINE=InnoDB;
@@ -0,0 +1,18 @@
+CREATE TABLE jobs (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  kind VARCHAR(100) NOT NULL,
+  payload MEDIUMTEXT NOT NULL,
+  unique_key VARCHAR(191) NULL,
+  status VARCHAR(20) NOT NULL,
+  attempts INT NOT NULL DEFAULT 0,
+  max_attempts INT NOT NULL,
+  run_at DATETIME NOT NULL,
+  lease_token CHAR(32) NULL,
+  locked_until DATETIME NULL,
+  last_error VARCHAR(1000) NULL,
+  finished_at DATETIME NULL,
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  UNIQUE KEY uq_jobs_unique_key (unique_key),
+  INDEX idx_jobs_due (status, run_at),
+  INDEX idx_jobs_lease (status, locked_until)
+) ENGINE=InnoDB;
@@ -0,0 +1,34 @@
+CREATE TABLE notifications (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  user_id INT NOT NULL,
+  kind VARCHAR(50) NOT NULL,
+  title VARCHAR(255) NOT NULL,
+  body TEXT NOT NULL,
+  event_id INT NULL,
+  read_at DATETIME NULL,
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  INDEX idx_notifications_user (user_id, id),
+  FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+  FOREIGN KEY(event_id) REFERENCES events(id) ON DELETE SET NULL
+) ENGINE=InnoDB;
+
+CREATE TABLE reminder_opt_outs (
+  user_id INT NOT NULL,
+  event_id INT NOT NULL,
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (user_id, event_id),
+  FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+  FOREIGN KEY(event_id) REFERENCES events(id) ON DELETE CASCADE
+) ENGINE=InnoDB;
+
+CREATE TABLE reminder_deliveries (
+  event_id INT NOT NULL,
+  occurrence_start DATETIME NOT NULL,
+  offset_minutes INT NOT NULL,
+  user_id INT NOT NULL,
+  channel VARCHAR(20) NOT NULL,
+  sent_at DATETIME NOT NULL,
+  PRIMARY KEY (event_id, occurrence_start, offset_minutes, user_id, channel),
+  FOREIGN KEY(event_id) REFERENCES events(id) ON DELETE CASCADE,
+  FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB;
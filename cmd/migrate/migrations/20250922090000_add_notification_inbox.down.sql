@@ -0,0 +1,4 @@
+ALTER TABLE notifications
+  DROP INDEX idx_notifications_unread,
+  DROP INDEX uq_notifications_dedupe,
+  DROP COLUMN dedupe_key;
@@ -0,0 +1,6 @@
+-- dedupe_key lets a notification that may be produced more than once, such
+-- as one published from the outbox, be stored only once per user.
+ALTER TABLE notifications
+  ADD COLUMN dedupe_key VARCHAR(100) NULL AFTER event_id,
+  ADD UNIQUE KEY uq_notifications_dedupe (user_id, dedupe_key),
+  ADD INDEX idx_notifications_unread (user_id, read_at);
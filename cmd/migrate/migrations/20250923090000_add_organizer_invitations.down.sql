@@ -0,0 +1,4 @@
+DELETE FROM event_organizers WHERE accepted_at IS NULL;
+
+ALTER TABLE event_organizers
+  DROP COLUMN accepted_at;
@@ -0,0 +1,6 @@
+-- Co-organizers are invited and only get their permissions once they accept.
+-- Organizers added before invitations existed count as having accepted.
+ALTER TABLE event_organizers
+  ADD COLUMN accepted_at DATETIME NULL AFTER can_check_in;
+
+UPDATE event_organizers SET accepted_at = created_at;
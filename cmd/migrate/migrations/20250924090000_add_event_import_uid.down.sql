@@ -0,0 +1,3 @@
+ALTER TABLE events
+  DROP INDEX uq_events_import_uid,
+  DROP COLUMN import_uid;
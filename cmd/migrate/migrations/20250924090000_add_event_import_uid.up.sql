@@ -0,0 +1,6 @@
+-- import_uid is the UID of the iCalendar event, or the uid column of the CSV
+-- row, an event was imported from. Importing the same UID again updates the
+-- event instead of creating a copy.
+ALTER TABLE events
+  ADD COLUMN import_uid VARCHAR(255) NULL AFTER series_ends_at,
+  ADD UNIQUE KEY uq_events_import_uid (import_uid, owner_id);
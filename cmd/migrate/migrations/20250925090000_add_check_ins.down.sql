@@ -0,0 +1,12 @@
+ALTER TABLE attendees
+  ADD COLUMN checked_in_at DATETIME NULL AFTER rsvp_status;
+
+UPDATE attendees a
+JOIN (
+  SELECT attendee_id, MIN(checked_in_at) AS checked_in_at
+  FROM check_ins
+  GROUP BY attendee_id
+) c ON c.attendee_id = a.id
+SET a.checked_in_at = c.checked_in_at;
+
+DROP TABLE check_ins;
@@ -0,0 +1,19 @@
+-- A registration for a whole series is checked in once per occurrence, so
+-- check-ins move to their own table keyed by the occurrence. Check-ins
+-- recorded before this did not name one; those of series registrations are
+-- attributed to the first occurrence.
+CREATE TABLE check_ins (
+  attendee_id INT NOT NULL,
+  occurrence_start DATETIME NOT NULL,
+  checked_in_at DATETIME NOT NULL,
+  PRIMARY KEY (attendee_id, occurrence_start),
+  FOREIGN KEY(attendee_id) REFERENCES attendees(id) ON DELETE CASCADE
+) ENGINE=InnoDB;
+
+INSERT INTO check_ins (attendee_id, occurrence_start, checked_in_at)
+SELECT a.id, COALESCE(a.occurrence_start, e.starts_at), a.checked_in_at
+FROM attendees a
+JOIN events e ON e.id = a.event_id
+WHERE a.checked_in_at IS NOT NULL;
+
+ALTER TABLE attendees DROP COLUMN checked_in_at;
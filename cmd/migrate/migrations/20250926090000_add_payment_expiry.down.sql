@@ -0,0 +1,3 @@
+ALTER TABLE payments
+  DROP INDEX idx_payments_expiry,
+  DROP COLUMN expires_at;
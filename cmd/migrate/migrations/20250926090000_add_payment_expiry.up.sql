@@ -0,0 +1,9 @@
+-- A pending payment holds its seat until expires_at. Payments still pending
+-- by then are reconciled with the payment provider: recorded as succeeded if
+-- the charge went through, failed otherwise. Payments already pending expire
+-- right away.
+ALTER TABLE payments
+  ADD COLUMN expires_at DATETIME NULL AFTER failure_reason,
+  ADD INDEX idx_payments_expiry (status, expires_at);
+
+UPDATE payments SET expires_at = created_at, updated_at = updated_at WHERE status = 'pending';
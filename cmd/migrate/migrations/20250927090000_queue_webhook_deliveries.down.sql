@@ -0,0 +1 @@
+DELETE FROM jobs WHERE kind = 'webhooks.deliver';
@@ -0,0 +1,4 @@
+INSERT INTO jobs (kind, payload, status, max_attempts, run_at, created_at)
+SELECT 'webhooks.deliver', JSON_OBJECT('delivery_id', id), 'queued', GREATEST(attempts + 2, 9), next_attempt_at, UTC_TIMESTAMP()
+FROM webhook_deliveries
+WHERE status = 'pending';
@@ -0,0 +1,30 @@
+CREATE TABLE imports (
+  id INT AUTO_INCREMENT PRIMARY KEY,
+  user_id INT NOT NULL,
+  event_id INT NULL,
+  kind VARCHAR(20) NOT NULL,
+  dry_run BOOLEAN NOT NULL DEFAULT FALSE,
+  status VARCHAR(20) NOT NULL,
+  request LONGTEXT NOT NULL,
+  report LONGTEXT NULL,
+  error VARCHAR(500) NULL,
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  finished_at DATETIME NULL,
+  INDEX idx_imports_finished (finished_at),
+  FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+  FOREIGN KEY(event_id) REFERENCES events(id) ON DELETE CASCADE
+) ENGINE=InnoDB;
+
+CREATE TABLE exports (
+  id INT AUTO_INCREMENT PRIMARY KEY,
+  user_id INT NOT NULL,
+  event_id INT NOT NULL,
+  format VARCHAR(10) NOT NULL,
+  status VARCHAR(20) NOT NULL,
+  error VARCHAR(500) NULL,
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  finished_at DATETIME NULL,
+  INDEX idx_exports_finished (finished_at),
+  FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+  FOREIGN KEY(event_id) REFERENCES events(id) ON DELETE CASCADE
+) ENGINE=InnoDB;
@@ -0,0 +1,3 @@
+ALTER TABLE webhook_deliveries
+  DROP INDEX uq_webhook_deliveries_outbox,
+  DROP COLUMN outbox_id;
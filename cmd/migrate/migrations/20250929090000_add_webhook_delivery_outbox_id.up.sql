@@ -0,0 +1,6 @@
+-- Each outbox message is delivered to a subscription at most once, however
+-- often the message is handled. Deliveries made before this column existed,
+-- and redeliveries, carry no outbox_id and are not constrained.
+ALTER TABLE webhook_deliveries
+  ADD COLUMN outbox_id BIGINT NULL AFTER subscription_id,
+  ADD UNIQUE INDEX uq_webhook_deliveries_outbox (subscription_id, outbox_id);
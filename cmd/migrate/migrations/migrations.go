@@ -0,0 +1,75 @@
+// Package migrations embeds the SQL migrations of the application so every
+// binary can apply them without the files being present on disk.
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"io/fs"
+	"sort"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/mysql"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+)
+
+//go:embed *.sql
+var FS embed.FS
+
+// New returns a migrator that applies the embedded migrations to db. It runs
+// on a dedicated connection from the pool, so closing the migrator leaves db
+// open for the caller.
+func New(db *sql.DB) (*migrate.Migrate, error) {
+	ctx := context.Background()
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	driver, err := mysql.WithConnection(ctx, conn, &mysql.Config{})
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	src, err := iofs.New(FS, ".")
+	if err != nil {
+		driver.Close()
+		return nil, err
+	}
+
+	return migrate.NewWithInstance("iofs", src, "mysql", driver)
+}
+
+// Migration is a single embedded migration.
+type Migration struct {
+	Version    uint
+	Identifier string
+}
+
+// List returns the embedded migrations ordered by version.
+func List() ([]Migration, error) {
+	entries, err := fs.ReadDir(FS, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[uint]bool)
+	var list []Migration
+	for _, entry := range entries {
+		m, err := source.DefaultParse(entry.Name())
+		if err != nil || seen[m.Version] {
+			continue
+		}
+
+		seen[m.Version] = true
+		list = append(list, Migration{Version: m.Version, Identifier: m.Identifier})
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+
+	return list, nil
+}
@@ -0,0 +1,74 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// ExportTimeoutDuration bounds how long an export may keep its query open
+// while rows are written to the export file.
+var ExportTimeoutDuration = 5 * time.Minute
+
+// AttendeeExportRow is one registration of an event as it appears in an
+// attendee export.
+type AttendeeExportRow struct {
+	UserId       int        `json:"user_id"`
+	Name         string     `json:"name"`
+	Email        string     `json:"email"`
+	Occurrence   *time.Time `json:"occurrence,omitempty"`
+	Status       string     `json:"status"`
+	RSVPStatus   string     `json:"rsvp_status"`
+	RegisteredAt time.Time  `json:"registered_at"`
+	// CheckedInAt is the latest check-in of the registration, which for a
+	// whole series may have been checked in at several occurrences.
+	CheckedInAt *time.Time `json:"checked_in_at"`
+}
+
+// ExportByEvent calls fn for every registration of an event in registration
+// order. Rows are read one at a time from the database, so exports of large
+// events are never held in memory at once. Iteration stops at the first
+// error returned by fn.
+func (m *AtendeeModel) ExportByEvent(ctx context.Context, eventId int, fn func(*AttendeeExportRow) error) error {
+	ctx, cancel := context.WithTimeout(ctx, ExportTimeoutDuration)
+	defer cancel()
+
+	query := `
+		SELECT u.id, u.name, u.email, a.occurrence_start, a.status, a.rsvp_status, a.created_at,
+			(SELECT MAX(c.checked_in_at) FROM check_ins c WHERE c.attendee_id = a.id)
+		FROM attendees a
+		JOIN users u ON u.id = a.user_id
+		WHERE a.event_id = ?
+		ORDER BY a.created_at, a.id
+	`
+
+	rows, err := m.DB.QueryContext(ctx, query, eventId)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var row AttendeeExportRow
+		var occurrence, checkedInAt sql.NullTime
+
+		err := rows.Scan(&row.UserId, &row.Name, &row.Email, &occurrence, &row.Status, &row.RSVPStatus, &row.RegisteredAt, &checkedInAt)
+		if err != nil {
+			return err
+		}
+
+		if occurrence.Valid {
+			row.Occurrence = &occurrence.Time
+		}
+		if checkedInAt.Valid {
+			row.CheckedInAt = &checkedInAt.Time
+		}
+
+		if err := fn(&row); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
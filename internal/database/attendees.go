@@ -2,44 +2,91 @@ package database
 
 import (
 	"context"
+	"crypto/rand"
 	"database/sql"
+	"encoding/hex"
+	"time"
 )
 
 type AtendeeModel struct {
 	DB *sql.DB
 }
 
+const (
+	AttendeeStatusConfirmed  = "confirmed"
+	AttendeeStatusWaitlisted = "waitlisted"
+	AttendeeStatusDeclined   = "declined"
+	// AttendeeStatusPendingPayment holds a seat while the payment for a paid
+	// ticket is taken. The registration is confirmed once it succeeds.
+	AttendeeStatusPendingPayment = "pending_payment"
+)
+
+const (
+	RSVPGoing    = "going"
+	RSVPMaybe    = "maybe"
+	RSVPDeclined = "declined"
+)
+
+// Attendee is a registration of a user for an event. For recurring events
+// Occurrence is the start of the single occurrence the user registered for;
+// it is nil when the registration covers the whole series.
+//
+// TicketTypeId is the ticket type the registration was made with, if any.
+//
+// TicketCode is a random secret issued with the registration. Tickets embed
+// it, so a ticket stops working once its registration is removed.
 type Attendee struct {
-	ID      int `json:"id"`
-	UserId  int `json:"user_id"`
-	EventId int `json:"event_id"`
+	ID           int        `json:"id"`
+	UserId       int        `json:"user_id"`
+	EventId      int        `json:"event_id"`
+	Occurrence   *time.Time `json:"occurrence,omitempty"`
+	TicketTypeId *int       `json:"ticket_type_id,omitempty"`
+	Status       string     `json:"status"`
+	RSVPStatus   string     `json:"rsvp_status"`
+	TicketCode   string     `json:"-"`
 }
 
-func (m *AtendeeModel) Insert(ctx context.Context, attendee *Attendee) (*Attendee, error) {
-	query := "INSERT INTO attendees (event_id, user_id) VALUES (?, ?)"
-	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
-	defer cancel()
+const attendeeColumns = "id, event_id, user_id, occurrence_start, ticket_type_id, status, rsvp_status, ticket_code"
 
-	result, err := m.DB.ExecContext(ctx, query, attendee.EventId, attendee.UserId)
+// insertAttendee creates a registration and issues its ticket code.
+func insertAttendee(ctx context.Context, q dbtx, attendee *Attendee) error {
+	code := make([]byte, 16)
+	if _, err := rand.Read(code); err != nil {
+		return err
+	}
+	attendee.TicketCode = hex.EncodeToString(code)
+
+	query := "INSERT INTO attendees (event_id, user_id, occurrence_start, ticket_type_id, status, rsvp_status, ticket_code) VALUES (?, ?, ?, ?, ?, ?, ?)"
+	result, err := q.ExecContext(ctx, query, attendee.EventId, attendee.UserId, attendee.Occurrence, attendee.TicketTypeId, attendee.Status, attendee.RSVPStatus, attendee.TicketCode)
+	if err != nil {
+		return err
+	}
 
 	id, err := result.LastInsertId()
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	attendee.ID = int(id)
 
-	return attendee, nil
+	return nil
 }
 
-func (m *AtendeeModel) GetByEventAndAttendee(ctx context.Context, eventId, userId int) (*Attendee, error) {
+// GetByEventAndAttendee returns the registration of a user for one occurrence
+// of an event, or for the whole series when occurrence is nil.
+func (m *AtendeeModel) GetByEventAndAttendee(ctx context.Context, eventId, userId int, occurrence *time.Time) (*Attendee, error) {
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	query := "SELECT id, event_id, user_id FROM attendees WHERE event_id = ? AND user_id = ?"
-	var attendee Attendee
-	err := m.DB.QueryRowContext(ctx, query, eventId, userId).Scan(&attendee.ID, &attendee.EventId, &attendee.UserId)
+	return getRegistration(ctx, m.DB, eventId, userId, occurrence)
+}
+
+func getRegistration(ctx context.Context, q dbtx, eventId, userId int, occurrence *time.Time) (*Attendee, error) {
+	return getAttendee(ctx, q, "SELECT "+attendeeColumns+" FROM attendees WHERE event_id = ? AND user_id = ? AND occurrence_start <=> ?", eventId, userId, occurrence)
+}
 
+func getAttendee(ctx context.Context, q dbtx, query string, args ...any) (*Attendee, error) {
+	attendee, err := scanAttendee(q.QueryRowContext(ctx, query, args...))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -47,10 +94,44 @@ func (m *AtendeeModel) GetByEventAndAttendee(ctx context.Context, eventId, userI
 		return nil, err
 	}
 
+	return attendee, nil
+}
+
+func scanAttendee(row rowScanner) (*Attendee, error) {
+	var attendee Attendee
+	var occurrence sql.NullTime
+	var ticketTypeId sql.NullInt64
+
+	err := row.Scan(&attendee.ID, &attendee.EventId, &attendee.UserId, &occurrence, &ticketTypeId, &attendee.Status, &attendee.RSVPStatus, &attendee.TicketCode)
+	if err != nil {
+		return nil, err
+	}
+
+	if occurrence.Valid {
+		attendee.Occurrence = &occurrence.Time
+	}
+	if ticketTypeId.Valid {
+		id := int(ticketTypeId.Int64)
+		attendee.TicketTypeId = &id
+	}
+
 	return &attendee, nil
 }
 
-func (m *AtendeeModel) GetAttendeesByEvent(ctx context.Context, eventid int) ([]*User, error) {
+// GetAttendeesByEvent returns the confirmed users of an event. Given an
+// occurrence, it returns those attending that occurrence, including users
+// registered for the whole series.
+func (m *AtendeeModel) GetAttendeesByEvent(ctx context.Context, eventid int, occurrence *time.Time) ([]*User, error) {
+	return m.getUsersByStatus(ctx, eventid, AttendeeStatusConfirmed, occurrence)
+}
+
+// GetWaitlistByEvent returns the waitlisted users of an event in the order
+// they will be promoted.
+func (m *AtendeeModel) GetWaitlistByEvent(ctx context.Context, eventId int, occurrence *time.Time) ([]*User, error) {
+	return m.getUsersByStatus(ctx, eventId, AttendeeStatusWaitlisted, occurrence)
+}
+
+func (m *AtendeeModel) getUsersByStatus(ctx context.Context, eventId int, status string, occurrence *time.Time) ([]*User, error) {
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
@@ -58,9 +139,16 @@ func (m *AtendeeModel) GetAttendeesByEvent(ctx context.Context, eventid int) ([]
 		SELECT u.id, u.name, u.email
 		FROM users u
 		JOIN attendees a ON u.id = a.user_id
-		WHERE a.event_id = ?
+		WHERE a.event_id = ? AND a.status = ?
 	`
-	rows, err := m.DB.QueryContext(ctx, query, eventid)
+	args := []any{eventId, status}
+	if occurrence != nil {
+		query += " AND (a.occurrence_start IS NULL OR a.occurrence_start = ?)"
+		args = append(args, occurrence)
+	}
+	query += " ORDER BY a.created_at, a.id"
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -79,49 +167,91 @@ func (m *AtendeeModel) GetAttendeesByEvent(ctx context.Context, eventid int) ([]
 		users = append(users, &user)
 	}
 
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
 	return users, nil
 }
 
-func (m *AtendeeModel) Delete(ctx context.Context, userId, eventId int) error {
-	query := "DELETE FROM attendees WHERE user_id = ? AND event_id = ?"
+func (m *AtendeeModel) GetEventByAttendee(ctx context.Context, attenddeeId int) ([]*Event, error) {
+	query := `
+		SELECT e.id, e.owner_id, e.name, e.description, e.starts_at, e.ends_at, e.timezone, e.location, e.capacity, e.recurrence_rule, e.exception_dates
+		FROM events e
+		JOIN attendees a ON e.id = a.event_id
+		WHERE a.user_id = ?
+	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	_, err := m.DB.ExecContext(ctx, query, eventId, userId)
+	rows, err := m.DB.QueryContext(ctx, query, attenddeeId)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	defer rows.Close()
+
+	var events []*Event
+	for rows.Next() {
+		event, err := scanEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		events = append(events, event)
+	}
+
+	return events, nil
 }
 
-func (m *AtendeeModel) GetEventByAttendee(ctx context.Context, attenddeeId int) ([]*Event, error) {
+// Attendance is a confirmed registration of a user together with its event.
+// Occurrence is nil when the user attends the whole series.
+type Attendance struct {
+	Event      *Event
+	Occurrence *time.Time
+}
+
+// GetConfirmedByUser returns the registrations a user holds a confirmed seat
+// for, leaving out declined, waitlisted and unpaid ones.
+func (m *AtendeeModel) GetConfirmedByUser(ctx context.Context, userId int) ([]*Attendance, error) {
 	query := `
-		SELECT e.id, e.owner_id, e.name, e.description, e.date, e.location
+		SELECT e.id, e.owner_id, e.name, e.description, e.starts_at, e.ends_at, e.timezone, e.location, e.capacity, e.recurrence_rule, e.exception_dates, a.occurrence_start
 		FROM events e
 		JOIN attendees a ON e.id = a.event_id
-		WHERE a.user_id = ?
+		WHERE a.user_id = ? AND a.status = ?
+		ORDER BY e.starts_at, e.id, a.occurrence_start
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	rows, err := m.DB.QueryContext(ctx, query, attenddeeId)
+	rows, err := m.DB.QueryContext(ctx, query, userId, AttendeeStatusConfirmed)
 	if err != nil {
 		return nil, err
 	}
 
-	var events []*Event
+	defer rows.Close()
+
+	var attendances []*Attendance
 	for rows.Next() {
-		var event Event
-		err := rows.Scan(&event.ID, &event.OwnerId, &event.Name, &event.Description, &event.Date, &event.Location)
+		var occurrence sql.NullTime
+		event, err := scanEvent(rows, &occurrence)
 		if err != nil {
 			return nil, err
 		}
 
-		events = append(events, &event)
+		attendance := &Attendance{Event: event}
+		if occurrence.Valid {
+			attendance.Occurrence = &occurrence.Time
+		}
+
+		attendances = append(attendances, attendance)
 	}
 
-	return events, nil
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return attendances, nil
 }
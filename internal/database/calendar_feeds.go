@@ -0,0 +1,67 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// CalendarFeedModel stores the secret tokens of the personal calendar feeds
+// users subscribe to from their calendar apps. Each user has at most one
+// feed, and only the hash of its token is stored.
+type CalendarFeedModel struct {
+	DB *sql.DB
+}
+
+// Rotate sets the token of a user's feed, replacing any previous token so
+// that old subscription URLs stop working.
+func (m *CalendarFeedModel) Rotate(ctx context.Context, userId int, tokenHash string, now time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `
+		INSERT INTO calendar_feeds (user_id, token_hash, created_at)
+		VALUES (?, ?, ?)
+		ON DUPLICATE KEY UPDATE
+			token_hash = VALUES(token_hash),
+			created_at = VALUES(created_at)
+	`
+
+	_, err := m.DB.ExecContext(ctx, query, userId, tokenHash, now)
+	return err
+}
+
+// GetUserIdByToken returns the user a feed token belongs to, or 0 when the
+// token is unknown.
+func (m *CalendarFeedModel) GetUserIdByToken(ctx context.Context, tokenHash string) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var userId int
+	err := m.DB.QueryRowContext(ctx, "SELECT user_id FROM calendar_feeds WHERE token_hash = ?", tokenHash).Scan(&userId)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	return userId, nil
+}
+
+func (m *CalendarFeedModel) Delete(ctx context.Context, userId int) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, "DELETE FROM calendar_feeds WHERE user_id = ?", userId)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
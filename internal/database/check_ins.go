@@ -0,0 +1,300 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+	"time"
+)
+
+var (
+	ErrTicketInvalid     = errors.New("ticket is not valid for this event")
+	ErrAlreadyCheckedIn  = errors.New("ticket has already been used")
+	ErrTicketUnconfirmed = errors.New("registration is not confirmed")
+	ErrWrongOccurrence   = errors.New("ticket is for another occurrence")
+)
+
+// CheckIn is the admission of a registration to one occurrence of its
+// event. Events that do not recur have a single occurrence, their start.
+type CheckIn struct {
+	AttendeeId  int       `json:"attendee_id"`
+	Occurrence  time.Time `json:"occurrence"`
+	CheckedInAt time.Time `json:"checked_in_at"`
+}
+
+// CheckIn checks the registration a ticket was issued for in to the
+// occurrence of its event starting at occurrence. A registration for a single
+// occurrence is only admitted to that one, a registration for the whole
+// series once to each of them. The registration row is locked while the
+// check-in is recorded, so a ticket scanned twice, even at two doors at once,
+// is accepted only once per occurrence; the second scan fails with
+// ErrAlreadyCheckedIn and the check-in that stands.
+func (m *AtendeeModel) CheckIn(ctx context.Context, eventId, attendeeId int, ticketCode string, occurrence, now time.Time) (*Attendee, *CheckIn, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	occurrence = occurrence.UTC().Truncate(time.Second)
+
+	var attendee *Attendee
+	var checkIn *CheckIn
+	err := withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		var err error
+		attendee, err = getAttendee(ctx, tx, "SELECT "+attendeeColumns+" FROM attendees WHERE id = ? AND event_id = ? AND ticket_code = ? FOR UPDATE", attendeeId, eventId, ticketCode)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case attendee == nil:
+			return ErrTicketInvalid
+		case attendee.Status != AttendeeStatusConfirmed:
+			return ErrTicketUnconfirmed
+		case attendee.Occurrence != nil && !attendee.Occurrence.Equal(occurrence):
+			return ErrWrongOccurrence
+		}
+
+		checkIn, err = getCheckIn(ctx, tx, attendeeId, occurrence)
+		if err != nil {
+			return err
+		}
+
+		if checkIn != nil {
+			return ErrAlreadyCheckedIn
+		}
+
+		checkIn = &CheckIn{AttendeeId: attendeeId, Occurrence: occurrence, CheckedInAt: now.UTC().Truncate(time.Second)}
+		_, err = tx.ExecContext(ctx, "INSERT INTO check_ins (attendee_id, occurrence_start, checked_in_at) VALUES (?, ?, ?)", checkIn.AttendeeId, checkIn.Occurrence, checkIn.CheckedInAt)
+		return err
+	})
+	if err != nil {
+		return attendee, checkIn, err
+	}
+
+	return attendee, checkIn, nil
+}
+
+func getCheckIn(ctx context.Context, q dbtx, attendeeId int, occurrence time.Time) (*CheckIn, error) {
+	checkIn := CheckIn{AttendeeId: attendeeId, Occurrence: occurrence}
+
+	err := q.QueryRowContext(ctx, "SELECT checked_in_at FROM check_ins WHERE attendee_id = ? AND occurrence_start = ?", attendeeId, occurrence).Scan(&checkIn.CheckedInAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &checkIn, nil
+}
+
+// Outcomes of an offline scan uploaded with SyncCheckIns.
+const (
+	ScanCheckedIn       = "checked_in"
+	ScanDuplicate       = "duplicate"
+	ScanInvalid         = "invalid"
+	ScanUnconfirmed     = "unconfirmed"
+	ScanWrongOccurrence = "wrong_occurrence"
+)
+
+// OfflineScan is a ticket scanned by a device while it was offline, at the
+// occurrence starting at Occurrence.
+type OfflineScan struct {
+	AttendeeId int
+	TicketCode string
+	Occurrence time.Time
+	ScannedAt  time.Time
+}
+
+// ScanResult is the outcome of one OfflineScan. CheckedInAt is the check-in
+// time that stands for the occurrence after the sync.
+type ScanResult struct {
+	AttendeeId  int        `json:"attendee_id"`
+	Status      string     `json:"status"`
+	Occurrence  *time.Time `json:"occurrence,omitempty"`
+	CheckedInAt *time.Time `json:"checked_in_at,omitempty"`
+}
+
+// ManifestTicket is a confirmed registration as listed in the check-in
+// manifest of an event, with the occurrences it was checked in to.
+type ManifestTicket struct {
+	AttendeeId int
+	UserId     int
+	Name       string
+	Occurrence *time.Time
+	TicketCode string
+	CheckIns   []CheckIn
+}
+
+// GetManifestTickets returns every confirmed registration of an event, which
+// is what a scanning device needs to admit guests while offline.
+func (m *AtendeeModel) GetManifestTickets(ctx context.Context, eventId int) ([]*ManifestTicket, error) {
+	ctx, cancel := context.WithTimeout(ctx, ExportTimeoutDuration)
+	defer cancel()
+
+	query := `
+		SELECT a.id, a.user_id, u.name, a.occurrence_start, a.ticket_code
+		FROM attendees a
+		JOIN users u ON u.id = a.user_id
+		WHERE a.event_id = ? AND a.status = ?
+		ORDER BY a.id
+	`
+
+	rows, err := m.DB.QueryContext(ctx, query, eventId, AttendeeStatusConfirmed)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	tickets := []*ManifestTicket{}
+	byAttendee := map[int]*ManifestTicket{}
+	for rows.Next() {
+		ticket := ManifestTicket{CheckIns: []CheckIn{}}
+		var occurrence sql.NullTime
+
+		if err := rows.Scan(&ticket.AttendeeId, &ticket.UserId, &ticket.Name, &occurrence, &ticket.TicketCode); err != nil {
+			return nil, err
+		}
+
+		if occurrence.Valid {
+			ticket.Occurrence = &occurrence.Time
+		}
+
+		tickets = append(tickets, &ticket)
+		byAttendee[ticket.AttendeeId] = &ticket
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	query = `
+		SELECT c.attendee_id, c.occurrence_start, c.checked_in_at
+		FROM check_ins c
+		JOIN attendees a ON a.id = c.attendee_id
+		WHERE a.event_id = ? AND a.status = ?
+		ORDER BY c.attendee_id, c.occurrence_start
+	`
+
+	rows, err = m.DB.QueryContext(ctx, query, eventId, AttendeeStatusConfirmed)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var checkIn CheckIn
+		if err := rows.Scan(&checkIn.AttendeeId, &checkIn.Occurrence, &checkIn.CheckedInAt); err != nil {
+			return nil, err
+		}
+
+		// A registration confirmed after the first query is not listed.
+		if ticket, ok := byAttendee[checkIn.AttendeeId]; ok {
+			ticket.CheckIns = append(ticket.CheckIns, checkIn)
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tickets, nil
+}
+
+// SyncCheckIns applies check-ins recorded offline, returning one result per
+// scan in the order given. Conflicts resolve the same way whatever order
+// devices upload in: the earliest scan of a ticket at an occurrence wins,
+// across the batch and any check-in already recorded, and every other scan
+// of it there is reported as a duplicate. Scans at the same instant are
+// ordered by their position in the batch.
+func (m *AtendeeModel) SyncCheckIns(ctx context.Context, eventId int, scans []OfflineScan) ([]ScanResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, ImportTimeoutDuration)
+	defer cancel()
+
+	byAttendee := map[int][]int{}
+	for i, scan := range scans {
+		byAttendee[scan.AttendeeId] = append(byAttendee[scan.AttendeeId], i)
+	}
+
+	// Rows are locked in ascending id order so concurrent syncs cannot
+	// deadlock on each other.
+	attendeeIds := make([]int, 0, len(byAttendee))
+	for id := range byAttendee {
+		attendeeIds = append(attendeeIds, id)
+	}
+	sort.Ints(attendeeIds)
+
+	results := make([]ScanResult, len(scans))
+	err := withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		for _, attendeeId := range attendeeIds {
+			indexes := byAttendee[attendeeId]
+			sort.SliceStable(indexes, func(i, j int) bool {
+				return scans[indexes[i]].ScannedAt.Before(scans[indexes[j]].ScannedAt)
+			})
+
+			attendee, err := getAttendee(ctx, tx, "SELECT "+attendeeColumns+" FROM attendees WHERE id = ? AND event_id = ? FOR UPDATE", attendeeId, eventId)
+			if err != nil {
+				return err
+			}
+
+			// The check-in that stands at each occurrence, by its start.
+			checkedIn := map[int64]*time.Time{}
+
+			for _, i := range indexes {
+				scan := scans[i]
+				// Times are stored to the second.
+				occurrence := scan.Occurrence.UTC().Truncate(time.Second)
+				scannedAt := scan.ScannedAt.UTC().Truncate(time.Second)
+				result := ScanResult{AttendeeId: attendeeId, Occurrence: &occurrence}
+
+				checkedInAt, loaded := checkedIn[occurrence.Unix()]
+				if !loaded && attendee != nil {
+					checkIn, err := getCheckIn(ctx, tx, attendeeId, occurrence)
+					if err != nil {
+						return err
+					}
+					if checkIn != nil {
+						checkedInAt = &checkIn.CheckedInAt
+					}
+					checkedIn[occurrence.Unix()] = checkedInAt
+				}
+
+				switch {
+				case attendee == nil || attendee.TicketCode != scan.TicketCode:
+					result.Status = ScanInvalid
+				case attendee.Status != AttendeeStatusConfirmed:
+					result.Status = ScanUnconfirmed
+				case attendee.Occurrence != nil && !attendee.Occurrence.Equal(occurrence):
+					result.Status = ScanWrongOccurrence
+				case checkedInAt == nil || scannedAt.Before(*checkedInAt):
+					query := "INSERT INTO check_ins (attendee_id, occurrence_start, checked_in_at) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE checked_in_at = VALUES(checked_in_at)"
+					if _, err := tx.ExecContext(ctx, query, attendeeId, occurrence, scannedAt); err != nil {
+						return err
+					}
+					checkedIn[occurrence.Unix()] = &scannedAt
+					result.Status = ScanCheckedIn
+				default:
+					result.Status = ScanDuplicate
+				}
+
+				results[i] = result
+			}
+
+			// Every scan reports the check-in time that stands in the end.
+			for _, i := range indexes {
+				if results[i].Status == ScanCheckedIn || results[i].Status == ScanDuplicate {
+					results[i].CheckedInAt = checkedIn[results[i].Occurrence.Unix()]
+				}
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
@@ -0,0 +1,130 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"rest-api-event-app/internal/testdb"
+	"testing"
+	"time"
+)
+
+func createTestSeries(t *testing.T, ownerId int) (*Event, []time.Time) {
+	t.Helper()
+
+	db := testdb.Open(t)
+	start := time.Now().UTC().Add(24 * time.Hour).Truncate(time.Second)
+	events := EventModel{DB: db}
+
+	event, err := events.InsertEvent(context.Background(), &Event{
+		OwnerId:        ownerId,
+		Name:           testdb.UniqueName("series"),
+		Description:    "A series created by a test",
+		StartsAt:       start,
+		EndsAt:         start.Add(time.Hour),
+		TimeZone:       "UTC",
+		Location:       "Test hall",
+		Capacity:       10,
+		RecurrenceRule: "FREQ=DAILY;COUNT=3",
+	})
+	if err != nil {
+		t.Fatalf("creating series: %s", err)
+	}
+
+	return event, []time.Time{start, start.AddDate(0, 0, 1), start.AddDate(0, 0, 2)}
+}
+
+func TestCheckInPerOccurrence(t *testing.T) {
+	db := testdb.Open(t)
+	attendees := AtendeeModel{DB: db}
+	ctx := context.Background()
+
+	owner := createTestUser(t, db)
+	event, occurrences := createTestSeries(t, owner.ID)
+
+	series, err := attendees.Register(ctx, event.ID, createTestUser(t, db).ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	single, err := attendees.Register(ctx, event.ID, createTestUser(t, db).ID, &occurrences[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := occurrences[0]
+
+	if _, _, err := attendees.CheckIn(ctx, event.ID, series.ID, series.TicketCode, occurrences[0], now); err != nil {
+		t.Fatalf("first check-in of a series ticket: %s", err)
+	}
+
+	_, checkIn, err := attendees.CheckIn(ctx, event.ID, series.ID, series.TicketCode, occurrences[0], now.Add(time.Minute))
+	if !errors.Is(err, ErrAlreadyCheckedIn) {
+		t.Fatalf("second scan at the same occurrence: got %v, want ErrAlreadyCheckedIn", err)
+	}
+	if !checkIn.CheckedInAt.Equal(now) {
+		t.Errorf("standing check-in = %s, want %s", checkIn.CheckedInAt, now)
+	}
+
+	if _, _, err := attendees.CheckIn(ctx, event.ID, series.ID, series.TicketCode, occurrences[1], occurrences[1]); err != nil {
+		t.Errorf("series ticket at a later occurrence: %s", err)
+	}
+
+	if _, _, err := attendees.CheckIn(ctx, event.ID, single.ID, single.TicketCode, occurrences[0], now); !errors.Is(err, ErrWrongOccurrence) {
+		t.Errorf("single occurrence ticket at another occurrence: got %v, want ErrWrongOccurrence", err)
+	}
+
+	if _, _, err := attendees.CheckIn(ctx, event.ID, single.ID, single.TicketCode, occurrences[1], occurrences[1]); err != nil {
+		t.Errorf("single occurrence ticket at its occurrence: %s", err)
+	}
+
+	if _, _, err := attendees.CheckIn(ctx, event.ID, series.ID, "not-the-code", occurrences[2], occurrences[2]); !errors.Is(err, ErrTicketInvalid) {
+		t.Errorf("forged ticket code: got %v, want ErrTicketInvalid", err)
+	}
+}
+
+func TestSyncCheckInsPerOccurrence(t *testing.T) {
+	db := testdb.Open(t)
+	attendees := AtendeeModel{DB: db}
+	ctx := context.Background()
+
+	owner := createTestUser(t, db)
+	event, occurrences := createTestSeries(t, owner.ID)
+
+	series, err := attendees.Register(ctx, event.ID, createTestUser(t, db).ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scan := func(occurrence time.Time, after time.Duration) OfflineScan {
+		return OfflineScan{AttendeeId: series.ID, TicketCode: series.TicketCode, Occurrence: occurrence, ScannedAt: occurrence.Add(after)}
+	}
+
+	// The later scan of the first occurrence comes first in the batch.
+	results, err := attendees.SyncCheckIns(ctx, event.ID, []OfflineScan{
+		scan(occurrences[0], 5*time.Minute),
+		scan(occurrences[0], time.Minute),
+		scan(occurrences[1], time.Minute),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{ScanDuplicate, ScanCheckedIn, ScanCheckedIn}
+	for i, result := range results {
+		if result.Status != want[i] {
+			t.Errorf("scan %d: status %s, want %s", i, result.Status, want[i])
+		}
+	}
+
+	if first := occurrences[0].Add(time.Minute); !results[0].CheckedInAt.Equal(first) {
+		t.Errorf("duplicate reports check-in at %s, want %s", results[0].CheckedInAt, first)
+	}
+
+	tickets, err := attendees.GetManifestTickets(ctx, event.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tickets) != 1 || len(tickets[0].CheckIns) != 2 {
+		t.Fatalf("manifest lists %+v, want one ticket with two check-ins", tickets)
+	}
+}
@@ -0,0 +1,58 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var ErrVerificationTokenInvalid = errors.New("verification token is invalid, expired or already used")
+
+type EmailVerificationModel struct {
+	DB *sql.DB
+}
+
+func (m *EmailVerificationModel) Create(ctx context.Context, userId int, tokenHash string, now, expiresAt time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := "INSERT INTO email_verification_tokens (user_id, token_hash, expires_at, created_at) VALUES (?, ?, ?, ?)"
+
+	_, err := m.DB.ExecContext(ctx, query, userId, tokenHash, expiresAt, now)
+	return err
+}
+
+// Verify consumes the verification token with the given hash and marks the
+// email address of its user as verified.
+func (m *EmailVerificationModel) Verify(ctx context.Context, tokenHash string, now time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var userId int
+	err := withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		query := `
+			SELECT user_id FROM email_verification_tokens
+			WHERE token_hash = ? AND used_at IS NULL AND expires_at > ?
+			FOR UPDATE
+		`
+		if err := tx.QueryRowContext(ctx, query, tokenHash, now).Scan(&userId); err != nil {
+			if err == sql.ErrNoRows {
+				return ErrVerificationTokenInvalid
+			}
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, "UPDATE email_verification_tokens SET used_at = ? WHERE user_id = ? AND used_at IS NULL", now, userId); err != nil {
+			return err
+		}
+
+		_, err := tx.ExecContext(ctx, "UPDATE users SET verified_at = ? WHERE id = ? AND verified_at IS NULL", now, userId)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return userId, nil
+}
@@ -0,0 +1,309 @@
+package database
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+const (
+	DefaultEventPageSize = 20
+	MaxEventPageSize     = 100
+)
+
+// eventSortColumns maps the sort keys accepted by ListEvents to their columns.
+// A leading "-" on the key sorts in descending order.
+var eventSortColumns = map[string]string{
+	"date": "starts_at",
+	"name": "name",
+	"id":   "id",
+}
+
+// EventFilter narrows down and orders the events returned by ListEvents.
+// Zero values leave the corresponding filter out.
+type EventFilter struct {
+	Search   string
+	From     string
+	To       string
+	Location string
+	OwnerId  int
+	Sort     string
+	Limit    int
+	Cursor   string
+}
+
+type EventPage struct {
+	Events     []*Event `json:"events"`
+	NextCursor string   `json:"next_cursor,omitempty"`
+	Total      int      `json:"total"`
+}
+
+// eventCursor is the position after the last event of a page. It carries the
+// sort key so a cursor cannot be replayed against a different ordering.
+type eventCursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    int    `json:"id"`
+}
+
+func (c eventCursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeEventCursor(s, sort string) (*eventCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var cursor eventCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.Sort != sort {
+		return nil, ErrInvalidCursor
+	}
+
+	return &cursor, nil
+}
+
+// ListEvents returns one page of events matching the filter using keyset
+// pagination, together with the total number of matching events. Recurring
+// events without an occurrence in the date range are not counted, just as
+// they are left out of the page.
+func (m *EventModel) ListEvents(ctx context.Context, filter EventFilter) (*EventPage, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	sort := filter.Sort
+	if sort == "" {
+		sort = "date"
+	}
+
+	column, ok := eventSortColumns[strings.TrimPrefix(sort, "-")]
+	if !ok {
+		return nil, errors.New("invalid sort " + sort)
+	}
+
+	direction, comparison := "ASC", ">"
+	if strings.HasPrefix(sort, "-") {
+		direction, comparison = "DESC", "<"
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = DefaultEventPageSize
+	}
+	if limit > MaxEventPageSize {
+		limit = MaxEventPageSize
+	}
+
+	var conditions []string
+	var args []any
+
+	if filter.Search != "" {
+		pattern := "%" + escapeLike(filter.Search) + "%"
+		conditions = append(conditions, "(name LIKE ? OR description LIKE ?)")
+		args = append(args, pattern, pattern)
+	}
+	// The date range matches every event that overlaps it, so multi-day
+	// events show up on each of their days. Recurring events overlap it
+	// from their first occurrence until the end of the series.
+	if filter.From != "" {
+		conditions = append(conditions, "(series_ends_at IS NULL OR series_ends_at > ?)")
+		args = append(args, filter.From)
+	}
+	if filter.To != "" {
+		conditions = append(conditions, "starts_at < DATE_ADD(?, INTERVAL 1 DAY)")
+		args = append(args, filter.To)
+	}
+	if filter.Location != "" {
+		conditions = append(conditions, "location LIKE ?")
+		args = append(args, "%"+escapeLike(filter.Location)+"%")
+	}
+	if filter.OwnerId != 0 {
+		conditions = append(conditions, "owner_id = ?")
+		args = append(args, filter.OwnerId)
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	page := &EventPage{Events: []*Event{}}
+
+	if err := m.DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM events"+where, args...).Scan(&page.Total); err != nil {
+		return nil, err
+	}
+
+	if filter.From != "" && filter.To != "" {
+		empty, err := m.countEmptySeries(ctx, where, args, filter.From, filter.To)
+		if err != nil {
+			return nil, err
+		}
+		page.Total -= empty
+	}
+
+	if filter.Cursor != "" {
+		cursor, err := decodeEventCursor(filter.Cursor, sort)
+		if err != nil {
+			return nil, err
+		}
+
+		keyset := "(" + column + " " + comparison + " ? OR (" + column + " = ? AND id " + comparison + " ?))"
+		if column == "id" {
+			keyset = "id " + comparison + " ?"
+			args = append(args, cursor.ID)
+		} else {
+			args = append(args, cursor.Value, cursor.Value, cursor.ID)
+		}
+
+		if where == "" {
+			where = " WHERE " + keyset
+		} else {
+			where += " AND " + keyset
+		}
+	}
+
+	query := "SELECT " + eventColumns + " FROM events" + where +
+		" ORDER BY " + column + " " + direction + ", id " + direction + " LIMIT ?"
+	args = append(args, limit+1)
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		event, err := scanEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		page.Events = append(page.Events, event)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(page.Events) > limit {
+		page.Events = page.Events[:limit]
+		last := page.Events[limit-1]
+
+		cursor := eventCursor{Sort: sort, ID: last.ID}
+		switch column {
+		case "starts_at":
+			cursor.Value = last.StartsAt.UTC().Format("2006-01-02 15:04:05")
+		case "name":
+			cursor.Value = last.Name
+		}
+		page.NextCursor = cursor.encode()
+	}
+
+	if filter.From != "" && filter.To != "" {
+		if err := expandOccurrences(page, filter.From, filter.To); err != nil {
+			return nil, err
+		}
+	}
+
+	return page, nil
+}
+
+// countEmptySeries counts the recurring events matching the conditions that
+// have no occurrence between from and to. The conditions select every series
+// overlapping the range, so the occurrences decide whether one is listed.
+func (m *EventModel) countEmptySeries(ctx context.Context, where string, args []any, from, to string) (int, error) {
+	start, end, err := parseDateRange(from, to)
+	if err != nil {
+		return 0, err
+	}
+
+	recurring := "recurrence_rule <> ''"
+	if where == "" {
+		where = " WHERE " + recurring
+	} else {
+		where += " AND " + recurring
+	}
+
+	rows, err := m.DB.QueryContext(ctx, "SELECT "+eventColumns+" FROM events"+where, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	defer rows.Close()
+
+	empty := 0
+	for rows.Next() {
+		event, err := scanEvent(rows)
+		if err != nil {
+			return 0, err
+		}
+
+		occurrences, err := event.Expand(start, end)
+		if err != nil {
+			return 0, err
+		}
+
+		if len(occurrences) == 0 {
+			empty++
+		}
+	}
+
+	return empty, rows.Err()
+}
+
+// parseDateRange returns the bounds of the days from through to, the end
+// being exclusive.
+func parseDateRange(from, to string) (time.Time, time.Time, error) {
+	start, err := time.Parse("2006-01-02", from)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	end, err := time.Parse("2006-01-02", to)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	return start, end.AddDate(0, 0, 1), nil
+}
+
+// expandOccurrences fills in the occurrences of the recurring events of a page
+// that fall within the date range, and drops series that have none there. The
+// cursor is not affected, so a page may hold fewer events than its limit.
+func expandOccurrences(page *EventPage, from, to string) error {
+	start, end, err := parseDateRange(from, to)
+	if err != nil {
+		return err
+	}
+
+	events := page.Events[:0]
+	for _, event := range page.Events {
+		if event.IsRecurring() {
+			event.Occurrences, err = event.Expand(start, end)
+			if err != nil {
+				return err
+			}
+
+			if len(event.Occurrences) == 0 {
+				continue
+			}
+		}
+
+		events = append(events, event)
+	}
+	page.Events = events
+
+	return nil
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
@@ -0,0 +1,71 @@
+package database
+
+import (
+	"context"
+	"rest-api-event-app/internal/testdb"
+	"testing"
+	"time"
+)
+
+// TestListEventsSeriesOutsideRange lists a week in which a weekly series has
+// no occurrence: the series is left out of the page and of the total.
+func TestListEventsSeriesOutsideRange(t *testing.T) {
+	db := testdb.Open(t)
+	events := EventModel{DB: db}
+	ctx := context.Background()
+
+	owner := createTestUser(t, db)
+	start := time.Date(2030, 6, 3, 18, 0, 0, 0, time.UTC)
+
+	create := func(startsAt time.Time, rule string) *Event {
+		event, err := events.InsertEvent(ctx, &Event{
+			OwnerId:        owner.ID,
+			Name:           testdb.UniqueName("event"),
+			StartsAt:       startsAt,
+			EndsAt:         startsAt.Add(time.Hour),
+			TimeZone:       "UTC",
+			Location:       "Test hall",
+			Capacity:       10,
+			RecurrenceRule: rule,
+		})
+		if err != nil {
+			t.Fatalf("creating event: %s", err)
+		}
+		return event
+	}
+
+	create(start, "FREQ=WEEKLY;COUNT=3")
+	single := create(start.AddDate(0, 0, 2), "")
+
+	filter := EventFilter{
+		OwnerId: owner.ID,
+		From:    start.AddDate(0, 0, 1).Format("2006-01-02"),
+		To:      start.AddDate(0, 0, 5).Format("2006-01-02"),
+	}
+
+	page, err := events.ListEvents(ctx, filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(page.Events) != 1 || page.Events[0].ID != single.ID {
+		t.Errorf("page holds %d events, want only the single event %d", len(page.Events), single.ID)
+	}
+
+	if page.Total != 1 {
+		t.Errorf("total = %d, want 1", page.Total)
+	}
+
+	// A week holding an occurrence lists the series again.
+	filter.From = start.AddDate(0, 0, 7).Format("2006-01-02")
+	filter.To = start.AddDate(0, 0, 8).Format("2006-01-02")
+
+	page, err = events.ListEvents(ctx, filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(page.Events) != 1 || page.Total != 1 || len(page.Events[0].Occurrences) != 1 {
+		t.Errorf("second week: %d events, total %d; want the series with one occurrence", len(page.Events), page.Total)
+	}
+}
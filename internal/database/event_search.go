@@ -0,0 +1,279 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"html"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+const (
+	mysqlErrFullTextKeyNotFound  = 1191
+	mysqlErrTableCantHandleFText = 1214
+
+	snippetRadius = 60
+
+	// likeCandidates is how many rows per result the LIKE fallback reads to
+	// rank by term frequency.
+	likeCandidates = 10
+)
+
+type EventSearchResult struct {
+	Event      *Event            `json:"event"`
+	Score      float64           `json:"score"`
+	Highlights map[string]string `json:"highlights"`
+}
+
+// Search ranks events by relevance to the query using the FULLTEXT index on
+// name, description and location. When the index or the storage engine does
+// not support MATCH ... AGAINST it falls back to LIKE matching and ranks the
+// rows by term frequency instead.
+func (m *EventModel) Search(ctx context.Context, q string, limit int) ([]*EventSearchResult, error) {
+	terms := searchTerms(q)
+	if len(terms) == 0 {
+		return []*EventSearchResult{}, nil
+	}
+
+	if limit <= 0 || limit > MaxEventPageSize {
+		limit = DefaultEventPageSize
+	}
+
+	results, err := m.searchFullText(ctx, q, limit)
+	if err != nil {
+		if !isFullTextUnavailable(err) {
+			return nil, err
+		}
+
+		results, err = m.searchLike(ctx, terms, limit)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, result := range results {
+		result.Highlights = highlightEvent(result.Event, terms)
+	}
+
+	return results, nil
+}
+
+func (m *EventModel) searchFullText(ctx context.Context, q string, limit int) ([]*EventSearchResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `
+		SELECT id, owner_id, name, description, starts_at, ends_at, timezone, location, capacity,
+			MATCH(name, description, location) AGAINST (? IN NATURAL LANGUAGE MODE) AS score
+		FROM events
+		WHERE MATCH(name, description, location) AGAINST (? IN NATURAL LANGUAGE MODE)
+		ORDER BY score DESC, id
+		LIMIT ?
+	`
+
+	rows, err := m.DB.QueryContext(ctx, query, q, q, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	results := []*EventSearchResult{}
+	for rows.Next() {
+		var score float64
+
+		event, err := scanEvent(rows, &score)
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, &EventSearchResult{Event: event, Score: score})
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+func (m *EventModel) searchLike(ctx context.Context, terms []string, limit int) ([]*EventSearchResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	// The database orders the matches by which fields contain which terms,
+	// weighted like likeScore, so only the best candidates are read and
+	// ranked by how often the terms occur.
+	var conditions, weights []string
+	var weightArgs, conditionArgs []any
+	for _, term := range terms {
+		pattern := "%" + escapeLike(term) + "%"
+		conditions = append(conditions, "name LIKE ? OR description LIKE ? OR location LIKE ?")
+		conditionArgs = append(conditionArgs, pattern, pattern, pattern)
+		weights = append(weights, "3 * (name LIKE ?) + 2 * (location LIKE ?) + (description LIKE ?)")
+		weightArgs = append(weightArgs, pattern, pattern, pattern)
+	}
+
+	query := "SELECT " + eventColumns + " FROM events WHERE " + strings.Join(conditions, " OR ") +
+		" ORDER BY " + strings.Join(weights, " + ") + " DESC, id LIMIT ?"
+
+	args := append(conditionArgs, weightArgs...)
+	args = append(args, limit*likeCandidates)
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	results := []*EventSearchResult{}
+	for rows.Next() {
+		event, err := scanEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, &EventSearchResult{Event: event, Score: likeScore(event, terms)})
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Event.ID < results[j].Event.ID
+	})
+
+	if len(results) > limit {
+		results = results[:limit]
+	}
+
+	return results, nil
+}
+
+// likeScore weights matches in the name above the location and description,
+// roughly mirroring how short fields dominate FULLTEXT relevance.
+func likeScore(event *Event, terms []string) float64 {
+	var score float64
+	for _, term := range terms {
+		score += 3 * float64(strings.Count(strings.ToLower(event.Name), term))
+		score += 2 * float64(strings.Count(strings.ToLower(event.Location), term))
+		score += float64(strings.Count(strings.ToLower(event.Description), term))
+	}
+
+	return score
+}
+
+func isFullTextUnavailable(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if !errors.As(err, &mysqlErr) {
+		return false
+	}
+
+	return mysqlErr.Number == mysqlErrFullTextKeyNotFound || mysqlErr.Number == mysqlErrTableCantHandleFText
+}
+
+func searchTerms(q string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(q), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+
+	seen := make(map[string]bool)
+	var terms []string
+	for _, field := range fields {
+		if !seen[field] {
+			seen[field] = true
+			terms = append(terms, field)
+		}
+	}
+
+	return terms
+}
+
+func highlightEvent(event *Event, terms []string) map[string]string {
+	highlights := make(map[string]string)
+	for field, text := range map[string]string{
+		"name":        event.Name,
+		"description": event.Description,
+		"location":    event.Location,
+	} {
+		if snippet, ok := highlight(text, terms); ok {
+			highlights[field] = snippet
+		}
+	}
+
+	return highlights
+}
+
+// highlight returns an HTML-escaped excerpt of text around the first matching
+// term, with every match wrapped in <mark> tags.
+func highlight(text string, terms []string) (string, bool) {
+	lower := strings.ToLower(text)
+	if len(lower) != len(text) {
+		// Case folding changed byte offsets; match case-sensitively instead.
+		lower = text
+	}
+
+	type match struct{ start, end int }
+	var matches []match
+	for _, term := range terms {
+		for offset := 0; ; {
+			i := strings.Index(lower[offset:], term)
+			if i < 0 {
+				break
+			}
+			matches = append(matches, match{offset + i, offset + i + len(term)})
+			offset += i + len(term)
+		}
+	}
+
+	if len(matches) == 0 {
+		return "", false
+	}
+
+	sort.Slice(matches, func(i, j int) bool { return matches[i].start < matches[j].start })
+
+	start := max(0, matches[0].start-snippetRadius)
+	end := min(len(text), matches[0].end+snippetRadius)
+	for start > 0 && !isRuneStart(text, start) {
+		start--
+	}
+	for end < len(text) && !isRuneStart(text, end) {
+		end++
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+
+	pos := start
+	for _, m := range matches {
+		if m.start < pos || m.end > end {
+			continue
+		}
+		b.WriteString(html.EscapeString(text[pos:m.start]))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(text[m.start:m.end]))
+		b.WriteString("</mark>")
+		pos = m.end
+	}
+	b.WriteString(html.EscapeString(text[pos:end]))
+
+	if end < len(text) {
+		b.WriteString("…")
+	}
+
+	return b.String(), true
+}
+
+func isRuneStart(s string, i int) bool {
+	return s[i]&0xC0 != 0x80
+}
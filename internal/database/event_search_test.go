@@ -0,0 +1,91 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"rest-api-event-app/internal/testdb"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSearchTerms(t *testing.T) {
+	got := searchTerms("Jazz, jazz & BLUES night-2025")
+	if want := []string{"jazz", "blues", "night", "2025"}; !slices.Equal(got, want) {
+		t.Errorf("searchTerms() = %q, want %q", got, want)
+	}
+}
+
+func TestLikeScore(t *testing.T) {
+	event := &Event{Name: "Jazz Night", Location: "Jazz Club", Description: "Jazz, jazz and more jazz"}
+
+	if got := likeScore(event, []string{"jazz"}); got != 3+2+3 {
+		t.Errorf("likeScore(jazz) = %v, want 8", got)
+	}
+	if got := likeScore(event, []string{"jazz", "club"}); got != 3+2+3+2 {
+		t.Errorf("likeScore(jazz club) = %v, want 10", got)
+	}
+}
+
+// TestSearchLike calls the LIKE fallback directly, since the test database
+// has the FULLTEXT index and Search never falls back there.
+func TestSearchLike(t *testing.T) {
+	db := testdb.Open(t)
+	events := EventModel{DB: db}
+	ctx := context.Background()
+
+	owner := createTestUser(t, db)
+	term := fmt.Sprintf("zq%d", time.Now().UnixNano())
+
+	create := func(name, location, description string) *Event {
+		start := time.Now().UTC().Add(24 * time.Hour).Truncate(time.Second)
+		event, err := events.InsertEvent(ctx, &Event{
+			OwnerId:     owner.ID,
+			Name:        name,
+			Description: description,
+			StartsAt:    start,
+			EndsAt:      start.Add(time.Hour),
+			TimeZone:    "UTC",
+			Location:    location,
+			Capacity:    10,
+		})
+		if err != nil {
+			t.Fatalf("creating event: %s", err)
+		}
+		return event
+	}
+
+	var inDescription []*Event
+	for range 25 {
+		inDescription = append(inDescription, create("Unrelated", "Somewhere", "Mentions "+term+" once"))
+	}
+	inLocation := create("Unrelated", "The "+term+" hall", "Nothing to see")
+	inName := create("The "+term+" festival", "Somewhere", "Nothing to see")
+	create("Unrelated", "Somewhere", "Nothing to see")
+
+	results, err := events.searchLike(ctx, []string{term}, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ids []int
+	for _, result := range results {
+		ids = append(ids, result.Event.ID)
+	}
+
+	// Name matches rank above location matches, which rank above
+	// description matches, ties going to the oldest event.
+	want := []int{inName.ID, inLocation.ID, inDescription[0].ID}
+	if !slices.Equal(ids, want) {
+		t.Errorf("searchLike() = events %v, want %v", ids, want)
+	}
+
+	if results[0].Score != 3 || results[1].Score != 2 || results[2].Score != 1 {
+		t.Errorf("scores = %v, %v, %v; want 3, 2, 1", results[0].Score, results[1].Score, results[2].Score)
+	}
+
+	results, err = events.searchLike(ctx, []string{term}, 1)
+	if err != nil || len(results) != 1 || results[0].Event.ID != inName.ID {
+		t.Errorf("searchLike() with limit 1 = %v, %v; want the name match", results, err)
+	}
+}
@@ -3,6 +3,7 @@ package database
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"time"
 )
 
@@ -10,43 +11,194 @@ type EventModel struct {
 	DB *sql.DB
 }
 
+// Event times are stored in UTC and presented in the event's own IANA time
+// zone, so clients always see the local time of the venue. Recurring events
+// carry an RFC 5545 RRULE whose first occurrence is StartsAt; every occurrence
+// lasts as long as the first one.
 type Event struct {
-	ID          int    `json:"id"`
-	OwnerId     int    `json:"ownerid"`
-	Name        string `json:"name" binding:"required,min=3"`
-	Description string `json:"description" binding:"required,min=10"`
-	Date        string `json:"date" binding:"required,datetime=2006-01-02"`
-	Location    string `json:"location" binding:"required,min=3"`
+	ID          int       `json:"id"`
+	OwnerId     int       `json:"ownerid"`
+	Name        string    `json:"name" binding:"required,min=3"`
+	Description string    `json:"description" binding:"required,min=10"`
+	StartsAt    time.Time `json:"starts_at" binding:"required"`
+	EndsAt      time.Time `json:"ends_at" binding:"required,gtfield=StartsAt"`
+	TimeZone    string    `json:"timezone" binding:"required,timezone"`
+	Location    string    `json:"location" binding:"required,min=3"`
+	Capacity    int       `json:"capacity" binding:"gte=0"`
+
+	RecurrenceRule string       `json:"recurrence_rule,omitempty" binding:"max=500" example:"FREQ=WEEKLY;BYDAY=MO;COUNT=10"`
+	ExceptionDates []time.Time  `json:"exception_dates,omitempty"`
+	Occurrences    []Occurrence `json:"occurrences,omitempty"`
+
+	// ImportUID is the UID of the iCalendar event, or CSV row, the event was
+	// imported from. Importing it again updates the event.
+	ImportUID string `json:"-"`
+}
+
+const eventColumns = "id, owner_id, name, description, starts_at, ends_at, timezone, location, capacity, recurrence_rule, exception_dates"
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanEvent scans a row selected with eventColumns, followed by any extra
+// columns, and converts its times to the event's time zone.
+func scanEvent(row rowScanner, extra ...any) (*Event, error) {
+	var event Event
+	var exceptionDates sql.NullString
+
+	dest := append([]any{&event.ID, &event.OwnerId, &event.Name, &event.Description, &event.StartsAt, &event.EndsAt, &event.TimeZone, &event.Location, &event.Capacity, &event.RecurrenceRule, &exceptionDates}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+
+	if exceptionDates.Valid {
+		if err := json.Unmarshal([]byte(exceptionDates.String), &event.ExceptionDates); err != nil {
+			return nil, err
+		}
+	}
+
+	event.Localize()
+
+	return &event, nil
+}
+
+// Localize converts the start and end time to the event's time zone. Unknown
+// zones leave the times in UTC.
+func (e *Event) Localize() {
+	loc, err := time.LoadLocation(e.TimeZone)
+	if err != nil {
+		loc = time.UTC
+	}
+
+	e.StartsAt = e.StartsAt.In(loc)
+	e.EndsAt = e.EndsAt.In(loc)
+	for i := range e.ExceptionDates {
+		e.ExceptionDates[i] = e.ExceptionDates[i].In(loc)
+	}
+}
+
+// exceptionDatesValue encodes the exception dates for the exception_dates
+// column, which is NULL when there are none.
+func (e *Event) exceptionDatesValue() (any, error) {
+	if len(e.ExceptionDates) == 0 {
+		return nil, nil
+	}
+
+	utc := make([]time.Time, len(e.ExceptionDates))
+	for i, exdate := range e.ExceptionDates {
+		utc[i] = exdate.UTC()
+	}
+
+	data, err := json.Marshal(utc)
+	if err != nil {
+		return nil, err
+	}
+
+	return string(data), nil
 }
 
 func (m *EventModel) InsertEvent(ctx context.Context, event *Event) (*Event, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	query := "INSERT INTO events (owner_id, name, description, date, location) VALUES (?, ?, ?, ?, ?)"
-
-	result, err := m.DB.ExecContext(ctx, query, event.OwnerId, event.Name, event.Description, event.Date, event.Location)
+	err := withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		return insertEvent(ctx, tx, event)
+	})
 	if err != nil {
 		return nil, err
 	}
 
+	return event, nil
+}
+
+// ImportEvents saves the events of import id in a single transaction, so
+// either all of them are saved or none. An event with an ImportUID replaces
+// the event its owner imported with the same UID before, if there is one,
+// and is inserted otherwise. Once every row went through, the import is
+// finished with the report made by report from how many events were
+// replaced. A dry run rolls the transaction back after every row succeeded.
+// A row failure is returned as a *RowError, and an import that already
+// finished fails with ErrImportFinished.
+func (m *EventModel) ImportEvents(ctx context.Context, id int, events []*Event, dryRun bool, report func(updated int) ([]byte, error)) error {
+	ctx, cancel := context.WithTimeout(ctx, ImportTimeoutDuration)
+	defer cancel()
+
+	var updated int
+	return runImport(ctx, m.DB, id, dryRun, func(tx *sql.Tx) error {
+		for i, event := range events {
+			replaced, err := importEvent(ctx, tx, event)
+			if err != nil {
+				return &RowError{Index: i, Err: err}
+			}
+
+			if replaced {
+				updated++
+			}
+		}
+
+		return nil
+	}, func() ([]byte, error) {
+		return report(updated)
+	})
+}
+
+// importEvent inserts an imported event, or updates the event previously
+// imported with the same UID, and reports whether it did the latter.
+func importEvent(ctx context.Context, tx *sql.Tx, event *Event) (bool, error) {
+	if event.ImportUID == "" {
+		return false, insertEvent(ctx, tx, event)
+	}
+
+	previous, err := scanEvent(tx.QueryRowContext(ctx, "SELECT "+eventColumns+" FROM events WHERE owner_id = ? AND import_uid = ? FOR UPDATE", event.OwnerId, event.ImportUID))
+	if err == sql.ErrNoRows {
+		return false, insertEvent(ctx, tx, event)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	event.ID = previous.ID
+	if err := updateEvent(ctx, tx, previous, event); err != nil {
+		return false, err
+	}
+
+	_, err = promoteFromWaitlist(ctx, tx, event.ID, event.Capacity)
+	return true, err
+}
+
+func insertEvent(ctx context.Context, q dbtx, event *Event) error {
+	exceptionDates, err := event.exceptionDatesValue()
+	if err != nil {
+		return err
+	}
+
+	query := "INSERT INTO events (owner_id, name, description, starts_at, ends_at, timezone, location, capacity, recurrence_rule, exception_dates, series_ends_at, import_uid) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+
+	result, err := q.ExecContext(ctx, query, event.OwnerId, event.Name, event.Description, event.StartsAt, event.EndsAt, event.TimeZone, event.Location, event.Capacity, event.RecurrenceRule, exceptionDates, event.SeriesEndsAt(), nullString(event.ImportUID))
+	if err != nil {
+		return err
+	}
+
 	id, err := result.LastInsertId()
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	event.ID = int(id)
+	event.Localize()
 
-	return event, nil
+	return writeOutbox(ctx, q, TopicEventCreated, event.ID, EventChange{Event: event})
 }
 
-func (m *EventModel) GetAllEvent() ([]*Event, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+// GetEventsByOwner returns every event a user owns, ordered by start time.
+func (m *EventModel) GetEventsByOwner(ctx context.Context, ownerId int) ([]*Event, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	query := "SELECT id, owner_id, name, description, date, location FROM events"
+	query := "SELECT " + eventColumns + " FROM events WHERE owner_id = ? ORDER BY starts_at, id"
 
-	rows, err := m.DB.QueryContext(ctx, query)
+	rows, err := m.DB.QueryContext(ctx, query, ownerId)
 	if err != nil {
 		return nil, err
 	}
@@ -54,16 +206,54 @@ func (m *EventModel) GetAllEvent() ([]*Event, error) {
 	defer rows.Close()
 
 	events := []*Event{}
-
 	for rows.Next() {
-		var event Event
+		event, err := scanEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		events = append(events, event)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// GetEventsStartingBetween returns the events that may have an occurrence
+// starting in [from, to]. Recurring events are returned whenever their
+// series overlaps the range; expand them to find their occurrences.
+func (m *EventModel) GetEventsStartingBetween(ctx context.Context, from, to time.Time) ([]*Event, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `
+		SELECT ` + eventColumns + `
+		FROM events
+		WHERE starts_at <= ? AND (
+			(recurrence_rule = '' AND starts_at >= ?)
+			OR (recurrence_rule <> '' AND (series_ends_at IS NULL OR series_ends_at >= ?))
+		)
+		ORDER BY starts_at, id
+	`
+
+	rows, err := m.DB.QueryContext(ctx, query, to, from, from)
+	if err != nil {
+		return nil, err
+	}
 
-		err := rows.Scan(&event.ID, &event.OwnerId, &event.Name, &event.Description, &event.Date, &event.Location)
+	defer rows.Close()
+
+	events := []*Event{}
+	for rows.Next() {
+		event, err := scanEvent(rows)
 		if err != nil {
 			return nil, err
 		}
 
-		events = append(events, &event)
+		events = append(events, event)
 	}
 
 	if err = rows.Err(); err != nil {
@@ -77,11 +267,9 @@ func (m *EventModel) GetEventById(id int) (*Event, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	query := "SELECT id, owner_id, name, description, date, location FROM events WHERE id = ?"
+	query := "SELECT " + eventColumns + " FROM events WHERE id = ?"
 
-	var event Event
-
-	err := m.DB.QueryRowContext(ctx, query, id).Scan(&event.ID, &event.OwnerId, &event.Name, &event.Description, &event.Date, &event.Location)
+	event, err := scanEvent(m.DB.QueryRowContext(ctx, query, id))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -89,33 +277,86 @@ func (m *EventModel) GetEventById(id int) (*Event, error) {
 		return nil, err
 	}
 
-	return &event, nil
+	return event, nil
 }
 
+// UpdateEvent saves the changes to an event. It fails with
+// ErrEventNotFound when the event no longer exists.
 func (m *EventModel) UpdateEvent(event *Event) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	query := "UPDATE events SET name = ?, description = ?, date = ?, location = ? WHERE id = ?"
+	return withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		previous, err := lockEventRow(ctx, tx, event.ID)
+		if err != nil {
+			return err
+		}
+
+		return updateEvent(ctx, tx, previous, event)
+	})
+}
+
+// updateEvent overwrites the locked event previous with event and tells its
+// audience about the change.
+func updateEvent(ctx context.Context, tx *sql.Tx, previous, event *Event) error {
+	exceptionDates, err := event.exceptionDatesValue()
+	if err != nil {
+		return err
+	}
+
+	query := "UPDATE events SET name = ?, description = ?, starts_at = ?, ends_at = ?, timezone = ?, location = ?, capacity = ?, recurrence_rule = ?, exception_dates = ?, series_ends_at = ? WHERE id = ?"
+
+	_, err = tx.ExecContext(ctx, query, event.Name, event.Description, event.StartsAt, event.EndsAt, event.TimeZone, event.Location, event.Capacity, event.RecurrenceRule, exceptionDates, event.SeriesEndsAt(), event.ID)
+	if err != nil {
+		return err
+	}
+
+	event.Localize()
 
-	_, err := m.DB.ExecContext(ctx, query, event.Name, event.Description, event.Date, event.Location, event.ID)
+	userIds, err := eventAudience(ctx, tx, event.ID)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return writeOutbox(ctx, tx, TopicEventUpdated, event.ID, EventChange{Event: event, Previous: previous, UserIds: userIds})
 }
 
+// DeleteEvent deletes an event. It fails with ErrEventNotFound when the
+// event no longer exists.
 func (m *EventModel) DeleteEvent(id int) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	query := "DELETE FROM events WHERE id = ?"
+	return withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		event, err := lockEventRow(ctx, tx, id)
+		if err != nil {
+			return err
+		}
 
-	_, err := m.DB.ExecContext(ctx, query, id)
-	if err != nil {
+		userIds, err := eventAudience(ctx, tx, event.ID)
+		if err != nil {
+			return err
+		}
+
+		if err := writeOutbox(ctx, tx, TopicEventDeleted, event.ID, EventChange{Event: event, UserIds: userIds}); err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, "DELETE FROM events WHERE id = ?", id)
 		return err
+	})
+}
+
+// lockEventRow loads an event and locks its row for the rest of the
+// transaction, failing with ErrEventNotFound when there is none.
+func lockEventRow(ctx context.Context, tx *sql.Tx, id int) (*Event, error) {
+	event, err := scanEvent(tx.QueryRowContext(ctx, "SELECT "+eventColumns+" FROM events WHERE id = ? FOR UPDATE", id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrEventNotFound
+		}
+		return nil, err
 	}
 
-	return nil
+	return event, nil
 }
@@ -0,0 +1,179 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+const (
+	ExportStatusQueued    = "queued"
+	ExportStatusSucceeded = "succeeded"
+	ExportStatusFailed    = "failed"
+)
+
+type ExportModel struct {
+	DB *sql.DB
+}
+
+// Export is an attendee export written to a file by a background job. The
+// file can be downloaded once the export succeeded, until it is removed
+// with the export.
+type Export struct {
+	ID         int        `json:"id"`
+	UserId     int        `json:"user_id"`
+	EventId    int        `json:"event_id"`
+	Format     string     `json:"format"`
+	Status     string     `json:"status"`
+	Error      string     `json:"error,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// ExportJob is the payload of the job that writes an export.
+type ExportJob struct {
+	ExportId int `json:"export_id"`
+}
+
+const exportColumns = "id, user_id, event_id, format, status, error, created_at, finished_at"
+
+// Insert saves a queued export together with a job, made from job with an
+// ExportJob payload, that writes it.
+func (m *ExportModel) Insert(ctx context.Context, export *Export, job Job) (*Export, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	export.Status = ExportStatusQueued
+	export.CreatedAt = time.Now().UTC().Truncate(time.Second)
+
+	err := withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		query := "INSERT INTO exports (user_id, event_id, format, status, created_at) VALUES (?, ?, ?, ?, ?)"
+		result, err := tx.ExecContext(ctx, query, export.UserId, export.EventId, export.Format, export.Status, export.CreatedAt)
+		if err != nil {
+			return err
+		}
+
+		id, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		export.ID = int(id)
+
+		return enqueueJobWith(ctx, tx, job, ExportJob{ExportId: export.ID})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return export, nil
+}
+
+// Get returns an export, or nil when there is none.
+func (m *ExportModel) Get(ctx context.Context, id int) (*Export, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return getExport(ctx, m.DB, "SELECT "+exportColumns+" FROM exports WHERE id = ?", id)
+}
+
+// GetByUser returns an export a user requested, or nil when the user
+// requested no such export.
+func (m *ExportModel) GetByUser(ctx context.Context, userId, id int) (*Export, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return getExport(ctx, m.DB, "SELECT "+exportColumns+" FROM exports WHERE id = ? AND user_id = ?", id, userId)
+}
+
+// Finish records the outcome of a queued export, with the reason it failed
+// unless it succeeded. It reports whether the export was still queued.
+func (m *ExportModel) Finish(ctx context.Context, id int, status, reason string, now time.Time) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	if len(reason) > 500 {
+		reason = reason[:500]
+	}
+
+	query := "UPDATE exports SET status = ?, error = ?, finished_at = ? WHERE id = ? AND status = ?"
+	result, err := m.DB.ExecContext(ctx, query, status, nullString(reason), now, id, ExportStatusQueued)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+// GetFinishedBefore returns up to limit exports that finished before the
+// given time, oldest first.
+func (m *ExportModel) GetFinishedBefore(ctx context.Context, before time.Time, limit int) ([]*Export, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, "SELECT "+exportColumns+" FROM exports WHERE finished_at < ? ORDER BY finished_at, id LIMIT ?", before, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var exports []*Export
+	for rows.Next() {
+		export, err := scanExport(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		exports = append(exports, export)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return exports, nil
+}
+
+func (m *ExportModel) Delete(ctx context.Context, id int) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, "DELETE FROM exports WHERE id = ?", id)
+	return err
+}
+
+func getExport(ctx context.Context, q dbtx, query string, args ...any) (*Export, error) {
+	export, err := scanExport(q.QueryRowContext(ctx, query, args...))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return export, nil
+}
+
+func scanExport(row rowScanner) (*Export, error) {
+	var export Export
+	var reason sql.NullString
+	var finishedAt sql.NullTime
+
+	err := row.Scan(&export.ID, &export.UserId, &export.EventId, &export.Format, &export.Status, &reason, &export.CreatedAt, &finishedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	export.Error = reason.String
+	if finishedAt.Valid {
+		export.FinishedAt = &finishedAt.Time
+	}
+
+	return &export, nil
+}
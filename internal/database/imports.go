@@ -0,0 +1,276 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ImportTimeoutDuration bounds a whole bulk import, which runs many queries
+// in one transaction.
+var ImportTimeoutDuration = 30 * time.Second
+
+const (
+	ImportKindEvents    = "events"
+	ImportKindAttendees = "attendees"
+)
+
+const (
+	ImportStatusQueued    = "queued"
+	ImportStatusSucceeded = "succeeded"
+	ImportStatusFailed    = "failed"
+)
+
+// ErrImportFinished is returned when an import that already finished is run
+// or finished again, as happens when its job runs twice.
+var ErrImportFinished = errors.New("import has already finished")
+
+// errDryRun rolls back the transaction of a dry run once every row went
+// through. It never leaves this package.
+var errDryRun = errors.New("dry run")
+
+// RowError reports which row of a bulk import failed. Index is the position
+// of the row in the slice passed to the import.
+type RowError struct {
+	Index int
+	Err   error
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("row %d: %s", e.Index, e.Err)
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// ignoreDryRun turns the rollback of a successful dry run into success.
+func ignoreDryRun(err error) error {
+	if errors.Is(err, errDryRun) {
+		return nil
+	}
+
+	return err
+}
+
+type ImportModel struct {
+	DB *sql.DB
+}
+
+// Import is a bulk import saved by a background job. Request holds the
+// validated rows to save. Once the import finished, Report describes the
+// outcome; Error says what went wrong when it failed without saving a row.
+type Import struct {
+	ID         int             `json:"id"`
+	UserId     int             `json:"user_id"`
+	EventId    *int            `json:"event_id,omitempty"`
+	Kind       string          `json:"kind"`
+	DryRun     bool            `json:"dry_run"`
+	Status     string          `json:"status"`
+	Request    json.RawMessage `json:"-"`
+	Report     json.RawMessage `json:"report,omitempty" swaggertype:"object"`
+	Error      string          `json:"error,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+	FinishedAt *time.Time      `json:"finished_at,omitempty"`
+}
+
+// ImportJob is the payload of the job that saves an import.
+type ImportJob struct {
+	ImportId int `json:"import_id"`
+}
+
+const importColumns = "id, user_id, event_id, kind, dry_run, status, request, report, error, created_at, finished_at"
+
+// Insert saves a queued import together with a job, made from job with an
+// ImportJob payload, that runs it.
+func (m *ImportModel) Insert(ctx context.Context, imp *Import, job Job) (*Import, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	imp.Status = ImportStatusQueued
+	imp.CreatedAt = time.Now().UTC().Truncate(time.Second)
+
+	err := withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		query := "INSERT INTO imports (user_id, event_id, kind, dry_run, status, request, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)"
+		result, err := tx.ExecContext(ctx, query, imp.UserId, imp.EventId, imp.Kind, imp.DryRun, imp.Status, string(imp.Request), imp.CreatedAt)
+		if err != nil {
+			return err
+		}
+
+		id, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		imp.ID = int(id)
+
+		return enqueueJobWith(ctx, tx, job, ImportJob{ImportId: imp.ID})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return imp, nil
+}
+
+// Get returns an import, or nil when there is none.
+func (m *ImportModel) Get(ctx context.Context, id int) (*Import, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return getImport(ctx, m.DB, "SELECT "+importColumns+" FROM imports WHERE id = ?", id)
+}
+
+// GetByUser returns an import a user started, or nil when the user started
+// no such import.
+func (m *ImportModel) GetByUser(ctx context.Context, userId, id int) (*Import, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return getImport(ctx, m.DB, "SELECT "+importColumns+" FROM imports WHERE id = ? AND user_id = ?", id, userId)
+}
+
+// Fail finishes a queued import that saved nothing, with a report of the
+// rows that failed or else the reason it failed.
+func (m *ImportModel) Fail(ctx context.Context, id int, report []byte, reason string, now time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	if len(reason) > 500 {
+		reason = reason[:500]
+	}
+
+	return finishImport(ctx, m.DB, id, ImportStatusFailed, report, nullString(reason), now)
+}
+
+// DeleteFinished removes the imports that finished before the given time
+// and returns how many were removed.
+func (m *ImportModel) DeleteFinished(ctx context.Context, before time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, "DELETE FROM imports WHERE finished_at < ?", before)
+	if err != nil {
+		return 0, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(affected), nil
+}
+
+// runImport runs the rows of import id in a transaction with fn, and
+// finishes the import with the report made by report once every row went
+// through. The import is finished in the same transaction, so one that was
+// saved is never saved again, even when its job runs twice; a dry run saves
+// nothing and is finished once it was rolled back.
+func runImport(ctx context.Context, db *sql.DB, id int, dryRun bool, fn func(tx *sql.Tx) error, report func() ([]byte, error)) error {
+	finish := func(q dbtx) error {
+		data, err := report()
+		if err != nil {
+			return err
+		}
+
+		return finishImport(ctx, q, id, ImportStatusSucceeded, data, nil, time.Now().UTC())
+	}
+
+	err := withTx(ctx, db, func(tx *sql.Tx) error {
+		if err := checkImportQueued(ctx, tx, id); err != nil {
+			return err
+		}
+
+		if err := fn(tx); err != nil {
+			return err
+		}
+
+		if dryRun {
+			return errDryRun
+		}
+		return finish(tx)
+	})
+	if err = ignoreDryRun(err); err != nil {
+		return err
+	}
+
+	if dryRun {
+		return finish(db)
+	}
+	return nil
+}
+
+// checkImportQueued locks a queued import, and fails with ErrImportFinished
+// when it is not queued.
+func checkImportQueued(ctx context.Context, tx *sql.Tx, id int) error {
+	var status string
+	err := tx.QueryRowContext(ctx, "SELECT status FROM imports WHERE id = ? FOR UPDATE", id).Scan(&status)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	if status != ImportStatusQueued {
+		return ErrImportFinished
+	}
+
+	return nil
+}
+
+func finishImport(ctx context.Context, q dbtx, id int, status string, report []byte, reason any, now time.Time) error {
+	var reportValue any
+	if report != nil {
+		reportValue = string(report)
+	}
+
+	query := "UPDATE imports SET status = ?, report = ?, error = ?, finished_at = ? WHERE id = ? AND status = ?"
+	result, err := q.ExecContext(ctx, query, status, reportValue, reason, now, id, ImportStatusQueued)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrImportFinished
+	}
+
+	return nil
+}
+
+func getImport(ctx context.Context, q dbtx, query string, args ...any) (*Import, error) {
+	var imp Import
+	var eventId sql.NullInt64
+	var request string
+	var report, reason sql.NullString
+	var finishedAt sql.NullTime
+
+	err := q.QueryRowContext(ctx, query, args...).Scan(&imp.ID, &imp.UserId, &eventId, &imp.Kind, &imp.DryRun, &imp.Status, &request, &report, &reason, &imp.CreatedAt, &finishedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if eventId.Valid {
+		id := int(eventId.Int64)
+		imp.EventId = &id
+	}
+	imp.Request = json.RawMessage(request)
+	if report.Valid {
+		imp.Report = json.RawMessage(report.String)
+	}
+	imp.Error = reason.String
+	if finishedAt.Valid {
+		imp.FinishedAt = &finishedAt.Time
+	}
+
+	return &imp, nil
+}
@@ -0,0 +1,419 @@
+package database
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+const (
+	JobStatusQueued    = "queued"
+	JobStatusRunning   = "running"
+	JobStatusSucceeded = "succeeded"
+	JobStatusDead      = "dead"
+)
+
+var (
+	ErrDuplicateJob = errors.New("a job with this unique key already exists")
+	ErrJobNotFound  = errors.New("job not found")
+	ErrJobNotDead   = errors.New("only dead jobs can be requeued")
+	// ErrJobLeaseLost is returned when a job is updated by a worker whose
+	// lease ran out and was taken over by another worker.
+	ErrJobLeaseLost = errors.New("job lease was lost")
+)
+
+type JobModel struct {
+	DB *sql.DB
+}
+
+// Job is a unit of background work. It runs no earlier than RunAt and is
+// retried until it succeeds or has run MaxAttempts times, after which it is
+// dead. A UniqueKey can only be used by one job at a time: until that job is
+// deleted, enqueueing another with the same key fails with ErrDuplicateJob.
+type Job struct {
+	ID          int64           `json:"id"`
+	Kind        string          `json:"kind"`
+	Payload     json.RawMessage `json:"payload" swaggertype:"object"`
+	UniqueKey   *string         `json:"unique_key,omitempty"`
+	Status      string          `json:"status"`
+	Attempts    int             `json:"attempts"`
+	MaxAttempts int             `json:"max_attempts"`
+	RunAt       time.Time       `json:"run_at"`
+	LockedUntil *time.Time      `json:"locked_until,omitempty"`
+	LastError   string          `json:"last_error,omitempty"`
+	FinishedAt  *time.Time      `json:"finished_at,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	LeaseToken  string          `json:"-"`
+}
+
+const jobColumns = "id, kind, payload, unique_key, status, attempts, max_attempts, run_at, locked_until, last_error, finished_at, created_at, lease_token"
+
+// Enqueue queues a job.
+func (m *JobModel) Enqueue(ctx context.Context, job *Job) (*Job, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	if err := enqueueJob(ctx, m.DB, job); err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// enqueueJob queues a job, inside a transaction when q is one, so the job
+// only exists if the change it follows up on is committed.
+func enqueueJob(ctx context.Context, q dbtx, job *Job) error {
+	job.Status = JobStatusQueued
+	job.Attempts = 0
+	job.RunAt = job.RunAt.UTC().Truncate(time.Second)
+	job.CreatedAt = time.Now().UTC().Truncate(time.Second)
+
+	query := "INSERT INTO jobs (kind, payload, unique_key, status, max_attempts, run_at, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)"
+
+	result, err := q.ExecContext(ctx, query, job.Kind, string(job.Payload), job.UniqueKey, job.Status, job.MaxAttempts, job.RunAt, job.CreatedAt)
+	if err != nil {
+		if isDuplicateEntry(err) {
+			return ErrDuplicateJob
+		}
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	job.ID = id
+
+	return nil
+}
+
+// enqueueJobWith queues job with payload encoded as JSON, inside tx.
+func enqueueJobWith(ctx context.Context, tx *sql.Tx, job Job, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	job.Payload = data
+	return enqueueJob(ctx, tx, &job)
+}
+
+// Lease hands out up to limit jobs of the given kinds for a worker to run:
+// queued jobs that are due, oldest first, and running jobs whose lease ran
+// out because their worker stopped. Each leased job counts an attempt and is
+// held until now plus lease, under a token the worker has to present to
+// record its outcome. Jobs whose lease ran out on their last attempt are
+// marked dead instead of being run again.
+func (m *JobModel) Lease(ctx context.Context, kinds []string, now time.Time, limit int, lease time.Duration) ([]*Job, error) {
+	if len(kinds) == 0 || limit <= 0 {
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	token, err := newLeaseToken()
+	if err != nil {
+		return nil, err
+	}
+
+	var jobs []*Job
+	err = withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		query := `
+			SELECT ` + jobColumns + `
+			FROM jobs
+			WHERE kind IN (?` + strings.Repeat(", ?", len(kinds)-1) + `)
+				AND ((status = ? AND run_at <= ?) OR (status = ? AND locked_until <= ?))
+			ORDER BY run_at, id
+			LIMIT ?
+			FOR UPDATE
+		`
+
+		args := make([]any, 0, len(kinds)+5)
+		for _, kind := range kinds {
+			args = append(args, kind)
+		}
+		args = append(args, JobStatusQueued, now, JobStatusRunning, now, limit)
+
+		rows, err := tx.QueryContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+
+		defer rows.Close()
+
+		var abandoned []*Job
+		for rows.Next() {
+			job, err := scanJob(rows)
+			if err != nil {
+				return err
+			}
+
+			if job.Status == JobStatusRunning && job.Attempts >= job.MaxAttempts {
+				abandoned = append(abandoned, job)
+				continue
+			}
+
+			jobs = append(jobs, job)
+		}
+
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, job := range abandoned {
+			query := "UPDATE jobs SET status = ?, lease_token = NULL, locked_until = NULL, last_error = ?, finished_at = ? WHERE id = ?"
+			if _, err := tx.ExecContext(ctx, query, JobStatusDead, "lease expired on the last attempt", now, job.ID); err != nil {
+				return err
+			}
+		}
+
+		if len(jobs) == 0 {
+			return nil
+		}
+
+		lockedUntil := now.Add(lease)
+		args = []any{JobStatusRunning, token, lockedUntil}
+		for _, job := range jobs {
+			args = append(args, job.ID)
+
+			job.Status = JobStatusRunning
+			job.Attempts++
+			job.LeaseToken = token
+			job.LockedUntil = &lockedUntil
+		}
+
+		query = "UPDATE jobs SET status = ?, attempts = attempts + 1, lease_token = ?, locked_until = ? WHERE id IN (?" + strings.Repeat(", ?", len(jobs)-1) + ")"
+		_, err = tx.ExecContext(ctx, query, args...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return jobs, nil
+}
+
+// Extend keeps holding a leased job until lockedUntil.
+func (m *JobModel) Extend(ctx context.Context, job *Job, lockedUntil time.Time) error {
+	err := m.updateLeased(ctx, job, "locked_until = ?", lockedUntil)
+	if err == nil {
+		job.LockedUntil = &lockedUntil
+	}
+
+	return err
+}
+
+// Complete records that a leased job succeeded.
+func (m *JobModel) Complete(ctx context.Context, job *Job, now time.Time) error {
+	return m.updateLeased(ctx, job, "status = ?, lease_token = NULL, locked_until = NULL, last_error = NULL, finished_at = ?", JobStatusSucceeded, now)
+}
+
+// Retry records that a leased job failed and queues it to run again at
+// runAt.
+func (m *JobModel) Retry(ctx context.Context, job *Job, reason string, runAt time.Time) error {
+	return m.updateLeased(ctx, job, "status = ?, lease_token = NULL, locked_until = NULL, last_error = ?, run_at = ?", JobStatusQueued, truncateJobError(reason), runAt)
+}
+
+// Kill records that a leased job failed for good.
+func (m *JobModel) Kill(ctx context.Context, job *Job, reason string, now time.Time) error {
+	return m.updateLeased(ctx, job, "status = ?, lease_token = NULL, locked_until = NULL, last_error = ?, finished_at = ?", JobStatusDead, truncateJobError(reason), now)
+}
+
+// Release gives a leased job back without counting the attempt, for a
+// worker that is shutting down before the job finished.
+func (m *JobModel) Release(ctx context.Context, job *Job) error {
+	return m.updateLeased(ctx, job, "status = ?, attempts = attempts - 1, lease_token = NULL, locked_until = NULL", JobStatusQueued)
+}
+
+// updateLeased updates a job as long as the worker still holds its lease,
+// and fails with ErrJobLeaseLost otherwise.
+func (m *JobModel) updateLeased(ctx context.Context, job *Job, set string, args ...any) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, "UPDATE jobs SET "+set+" WHERE id = ? AND lease_token = ?", append(args, job.ID, job.LeaseToken)...)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrJobLeaseLost
+	}
+
+	return nil
+}
+
+// Requeue runs a dead job again right away, with a fresh set of attempts.
+func (m *JobModel) Requeue(ctx context.Context, id int64, now time.Time) (*Job, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var job *Job
+	err := withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		var err error
+		job, err = getJob(ctx, tx, "SELECT "+jobColumns+" FROM jobs WHERE id = ? FOR UPDATE", id)
+		if err != nil {
+			return err
+		}
+
+		if job == nil {
+			return ErrJobNotFound
+		}
+
+		if job.Status != JobStatusDead {
+			return ErrJobNotDead
+		}
+
+		job.Status = JobStatusQueued
+		job.Attempts = 0
+		job.RunAt = now.UTC().Truncate(time.Second)
+		job.FinishedAt = nil
+
+		_, err = tx.ExecContext(ctx, "UPDATE jobs SET status = ?, attempts = 0, run_at = ?, finished_at = NULL WHERE id = ?", job.Status, job.RunAt, job.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// Get returns a job, or nil when there is none.
+func (m *JobModel) Get(ctx context.Context, id int64) (*Job, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return getJob(ctx, m.DB, "SELECT "+jobColumns+" FROM jobs WHERE id = ?", id)
+}
+
+// List returns jobs, newest first, optionally only those of one kind or in
+// one status.
+func (m *JobModel) List(ctx context.Context, kind, status string, limit int) ([]*Job, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := "SELECT " + jobColumns + " FROM jobs WHERE 1 = 1"
+	var args []any
+	if kind != "" {
+		query += " AND kind = ?"
+		args = append(args, kind)
+	}
+	if status != "" {
+		query += " AND status = ?"
+		args = append(args, status)
+	}
+	query += " ORDER BY id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	jobs := []*Job{}
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		jobs = append(jobs, job)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return jobs, nil
+}
+
+// DeleteFinished removes the jobs that succeeded before the given time,
+// freeing their unique keys, and returns how many were removed. Dead jobs
+// are kept until they are requeued or deleted by hand.
+func (m *JobModel) DeleteFinished(ctx context.Context, before time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, "DELETE FROM jobs WHERE status = ? AND finished_at < ?", JobStatusSucceeded, before)
+	if err != nil {
+		return 0, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(affected), nil
+}
+
+func getJob(ctx context.Context, q dbtx, query string, args ...any) (*Job, error) {
+	job, err := scanJob(q.QueryRowContext(ctx, query, args...))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return job, nil
+}
+
+func scanJob(row rowScanner) (*Job, error) {
+	var job Job
+	var payload string
+	var uniqueKey, lastError, leaseToken sql.NullString
+	var lockedUntil, finishedAt sql.NullTime
+
+	err := row.Scan(&job.ID, &job.Kind, &payload, &uniqueKey, &job.Status, &job.Attempts, &job.MaxAttempts, &job.RunAt, &lockedUntil, &lastError, &finishedAt, &job.CreatedAt, &leaseToken)
+	if err != nil {
+		return nil, err
+	}
+
+	job.Payload = json.RawMessage(payload)
+	if uniqueKey.Valid {
+		job.UniqueKey = &uniqueKey.String
+	}
+	if lockedUntil.Valid {
+		job.LockedUntil = &lockedUntil.Time
+	}
+	job.LastError = lastError.String
+	if finishedAt.Valid {
+		job.FinishedAt = &finishedAt.Time
+	}
+	job.LeaseToken = leaseToken.String
+
+	return &job, nil
+}
+
+func newLeaseToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+func truncateJobError(reason string) string {
+	if len(reason) > 1000 {
+		return reason[:1000]
+	}
+
+	return reason
+}
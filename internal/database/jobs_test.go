@@ -0,0 +1,176 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"rest-api-event-app/internal/testdb"
+	"testing"
+	"time"
+)
+
+// createTestJob queues a job of a kind no other test uses, so leasing it
+// does not pick up jobs of other tests.
+func createTestJob(t *testing.T, jobs *JobModel, maxAttempts int, now time.Time) *Job {
+	t.Helper()
+
+	job, err := jobs.Enqueue(context.Background(), &Job{
+		Kind:        testdb.UniqueName("job"),
+		Payload:     []byte("{}"),
+		MaxAttempts: maxAttempts,
+		RunAt:       now,
+	})
+	if err != nil {
+		t.Fatalf("queueing job: %s", err)
+	}
+
+	return job
+}
+
+func leaseTestJob(t *testing.T, jobs *JobModel, kind string, now time.Time) *Job {
+	t.Helper()
+
+	leased, err := jobs.Lease(context.Background(), []string{kind}, now, 10, time.Minute)
+	if err != nil {
+		t.Fatalf("leasing jobs: %s", err)
+	}
+
+	if len(leased) > 1 {
+		t.Fatalf("leased %d jobs of kind %s, want at most 1", len(leased), kind)
+	}
+
+	if len(leased) == 0 {
+		return nil
+	}
+
+	return leased[0]
+}
+
+func TestJobLeaseExpiry(t *testing.T) {
+	db := testdb.Open(t)
+	ctx := context.Background()
+	jobs := &JobModel{DB: db}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	job := createTestJob(t, jobs, 3, now)
+
+	first := leaseTestJob(t, jobs, job.Kind, now)
+	if first == nil || first.Attempts != 1 {
+		t.Fatalf("first lease: got %+v, want the job on its first attempt", first)
+	}
+
+	if again := leaseTestJob(t, jobs, job.Kind, now.Add(30*time.Second)); again != nil {
+		t.Fatalf("job was leased again while its lease was held")
+	}
+
+	// The first worker stopped without an outcome; once the lease runs out
+	// another takes the job over.
+	second := leaseTestJob(t, jobs, job.Kind, now.Add(time.Minute))
+	if second == nil || second.Attempts != 2 {
+		t.Fatalf("lease after expiry: got %+v, want the job on its second attempt", second)
+	}
+
+	if second.LeaseToken == first.LeaseToken {
+		t.Fatal("takeover reused the expired lease token")
+	}
+
+	if err := jobs.Complete(ctx, first, now.Add(time.Minute)); !errors.Is(err, ErrJobLeaseLost) {
+		t.Fatalf("completing with the expired lease: got %v, want ErrJobLeaseLost", err)
+	}
+
+	if err := jobs.Complete(ctx, second, now.Add(time.Minute)); err != nil {
+		t.Fatalf("completing with the current lease: %s", err)
+	}
+
+	stored, err := jobs.Get(ctx, job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stored.Status != JobStatusSucceeded || stored.Attempts != 2 {
+		t.Errorf("stored job: %+v, want it succeeded after 2 attempts", stored)
+	}
+}
+
+func TestJobLeaseExpiredOnLastAttempt(t *testing.T) {
+	db := testdb.Open(t)
+	ctx := context.Background()
+	jobs := &JobModel{DB: db}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	job := createTestJob(t, jobs, 1, now)
+
+	if leased := leaseTestJob(t, jobs, job.Kind, now); leased == nil {
+		t.Fatal("job was not leased")
+	}
+
+	if leased := leaseTestJob(t, jobs, job.Kind, now.Add(time.Minute)); leased != nil {
+		t.Fatalf("job was leased again after its last attempt: %+v", leased)
+	}
+
+	stored, err := jobs.Get(ctx, job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stored.Status != JobStatusDead || stored.Attempts != 1 {
+		t.Errorf("stored job: %+v, want it dead after 1 attempt", stored)
+	}
+}
+
+func TestJobRetryAndRelease(t *testing.T) {
+	db := testdb.Open(t)
+	ctx := context.Background()
+	jobs := &JobModel{DB: db}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	job := createTestJob(t, jobs, 3, now)
+
+	leased := leaseTestJob(t, jobs, job.Kind, now)
+	if err := jobs.Retry(ctx, leased, "receiver unavailable", now.Add(time.Hour)); err != nil {
+		t.Fatalf("retrying job: %s", err)
+	}
+
+	if early := leaseTestJob(t, jobs, job.Kind, now.Add(59*time.Minute)); early != nil {
+		t.Fatal("job was leased before its retry was due")
+	}
+
+	leased = leaseTestJob(t, jobs, job.Kind, now.Add(time.Hour))
+	if leased == nil || leased.Attempts != 2 || leased.LastError != "receiver unavailable" {
+		t.Fatalf("lease of the retry: got %+v, want the second attempt", leased)
+	}
+
+	// A worker shutting down gives the job back without using up the
+	// attempt.
+	if err := jobs.Release(ctx, leased); err != nil {
+		t.Fatalf("releasing job: %s", err)
+	}
+
+	leased = leaseTestJob(t, jobs, job.Kind, now.Add(time.Hour))
+	if leased == nil || leased.Attempts != 2 {
+		t.Fatalf("lease after release: got %+v, want the second attempt again", leased)
+	}
+}
+
+func TestJobUniqueKey(t *testing.T) {
+	db := testdb.Open(t)
+	ctx := context.Background()
+	jobs := &JobModel{DB: db}
+
+	key := testdb.UniqueName("key")
+	kind := testdb.UniqueName("job")
+	now := time.Now().UTC()
+
+	if _, err := jobs.Enqueue(ctx, &Job{Kind: kind, Payload: []byte("{}"), UniqueKey: &key, MaxAttempts: 1, RunAt: now}); err != nil {
+		t.Fatalf("queueing the first job: %s", err)
+	}
+
+	_, err := jobs.Enqueue(ctx, &Job{Kind: kind, Payload: []byte("{}"), UniqueKey: &key, MaxAttempts: 1, RunAt: now})
+	if !errors.Is(err, ErrDuplicateJob) {
+		t.Fatalf("queueing a second job with the key: got %v, want ErrDuplicateJob", err)
+	}
+
+	other := testdb.UniqueName("key")
+	if _, err := jobs.Enqueue(ctx, &Job{Kind: kind, Payload: []byte("{}"), UniqueKey: &other, MaxAttempts: 1, RunAt: now}); err != nil {
+		t.Errorf("queueing a job with another key: %s", err)
+	}
+}
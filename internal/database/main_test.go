@@ -0,0 +1,48 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"rest-api-event-app/internal/testdb"
+	"testing"
+	"time"
+)
+
+// Tests that need MySQL get it from testdb.Open; see package testdb.
+
+func createTestUser(t *testing.T, db *sql.DB) *User {
+	t.Helper()
+
+	name := testdb.UniqueName("user")
+	users := UserModel{DB: db}
+
+	user, err := users.InsertUser(context.Background(), &User{Email: name + "@example.com", Name: name, Password: "x"})
+	if err != nil {
+		t.Fatalf("creating user: %s", err)
+	}
+
+	return user
+}
+
+func createTestEvent(t *testing.T, db *sql.DB, ownerId, capacity int) *Event {
+	t.Helper()
+
+	start := time.Now().UTC().Add(24 * time.Hour).Truncate(time.Second)
+	events := EventModel{DB: db}
+
+	event, err := events.InsertEvent(context.Background(), &Event{
+		OwnerId:     ownerId,
+		Name:        testdb.UniqueName("event"),
+		Description: "An event created by a test",
+		StartsAt:    start,
+		EndsAt:      start.Add(2 * time.Hour),
+		TimeZone:    "UTC",
+		Location:    "Test hall",
+		Capacity:    capacity,
+	})
+	if err != nil {
+		t.Fatalf("creating event: %s", err)
+	}
+
+	return event
+}
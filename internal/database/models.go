@@ -1,22 +1,77 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"time"
 )
 
 type Models struct {
-	Users     UserModel
-	Events    EventModel
-	Attendees AtendeeModel
+	Users          UserModel
+	Events         EventModel
+	Attendees      AtendeeModel
+	RefreshTokens  RefreshTokenModel
+	RevokedTokens  RevokedTokenModel
+	PasswordResets PasswordResetModel
+	Verifications  EmailVerificationModel
+	Organizers     OrganizerModel
+	CalendarFeeds  CalendarFeedModel
+	TicketTypes    TicketTypeModel
+	Payments       PaymentModel
+	PromoCodes     PromoCodeModel
+	Webhooks       WebhookModel
+	Imports        ImportModel
+	Exports        ExportModel
+	Outbox         OutboxModel
+	Jobs           JobModel
+	Notifications  NotificationModel
+	Reminders      ReminderModel
 }
 
 func NewModels(db *sql.DB) Models {
 	return Models{
-		Users:     UserModel{DB: db},
-		Events:    EventModel{DB: db},
-		Attendees: AtendeeModel{DB: db},
+		Users:          UserModel{DB: db},
+		Events:         EventModel{DB: db},
+		Attendees:      AtendeeModel{DB: db},
+		RefreshTokens:  RefreshTokenModel{DB: db},
+		RevokedTokens:  RevokedTokenModel{DB: db},
+		PasswordResets: PasswordResetModel{DB: db},
+		Verifications:  EmailVerificationModel{DB: db},
+		Organizers:     OrganizerModel{DB: db},
+		CalendarFeeds:  CalendarFeedModel{DB: db},
+		TicketTypes:    TicketTypeModel{DB: db},
+		Payments:       PaymentModel{DB: db},
+		PromoCodes:     PromoCodeModel{DB: db},
+		Webhooks:       WebhookModel{DB: db},
+		Imports:        ImportModel{DB: db},
+		Exports:        ExportModel{DB: db},
+		Outbox:         OutboxModel{DB: db},
+		Jobs:           JobModel{DB: db},
+		Notifications:  NotificationModel{DB: db},
+		Reminders:      ReminderModel{DB: db},
 	}
 }
 
 var QueryTimeoutDuration = time.Second * 3
+
+// dbtx is satisfied by both *sql.DB and *sql.Tx so query helpers can run
+// inside or outside of a transaction.
+type dbtx interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func withTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
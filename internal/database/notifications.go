@@ -0,0 +1,197 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+)
+
+type NotificationModel struct {
+	DB *sql.DB
+}
+
+// Notification is a message shown to a user inside the app. A notification
+// with a DedupeKey is stored at most once per user, so it can be inserted
+// again safely when the work producing it is retried.
+type Notification struct {
+	ID        int64      `json:"id"`
+	UserId    int        `json:"user_id"`
+	Kind      string     `json:"kind"`
+	Title     string     `json:"title"`
+	Body      string     `json:"body"`
+	EventId   *int       `json:"event_id,omitempty"`
+	DedupeKey string     `json:"-"`
+	ReadAt    *time.Time `json:"read_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+const notificationColumns = "id, user_id, kind, title, body, event_id, read_at, created_at"
+
+func (m *NotificationModel) Insert(ctx context.Context, notification *Notification) (*Notification, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	notification.CreatedAt = time.Now().UTC().Truncate(time.Second)
+
+	query := "INSERT IGNORE INTO notifications (user_id, kind, title, body, event_id, dedupe_key, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)"
+
+	result, err := m.DB.ExecContext(ctx, query, notification.UserId, notification.Kind, notification.Title, notification.Body, notification.EventId, nullString(notification.DedupeKey), notification.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	notification.ID = id
+
+	return notification, nil
+}
+
+// InsertForUsers sends the same notification to several users at once.
+// UserId of the notification is ignored.
+func (m *NotificationModel) InsertForUsers(ctx context.Context, userIds []int, notification Notification) error {
+	if len(userIds) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	createdAt := time.Now().UTC().Truncate(time.Second)
+	dedupeKey := nullString(notification.DedupeKey)
+
+	args := make([]any, 0, len(userIds)*7)
+	for _, userId := range userIds {
+		args = append(args, userId, notification.Kind, notification.Title, notification.Body, notification.EventId, dedupeKey, createdAt)
+	}
+
+	query := "INSERT IGNORE INTO notifications (user_id, kind, title, body, event_id, dedupe_key, created_at) VALUES " +
+		strings.TrimSuffix(strings.Repeat("(?, ?, ?, ?, ?, ?, ?), ", len(userIds)), ", ")
+
+	_, err := m.DB.ExecContext(ctx, query, args...)
+	return err
+}
+
+// GetByUser returns a page of a user's notifications, newest first. before
+// is the id of the last notification of the previous page, or 0 for the
+// first page.
+func (m *NotificationModel) GetByUser(ctx context.Context, userId int, unreadOnly bool, before int64, limit int) ([]*Notification, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := "SELECT " + notificationColumns + " FROM notifications WHERE user_id = ?"
+	args := []any{userId}
+	if unreadOnly {
+		query += " AND read_at IS NULL"
+	}
+	if before > 0 {
+		query += " AND id < ?"
+		args = append(args, before)
+	}
+	query += " ORDER BY id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	notifications := []*Notification{}
+	for rows.Next() {
+		notification, err := scanNotification(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		notifications = append(notifications, notification)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return notifications, nil
+}
+
+// CountUnread returns how many notifications a user has not read yet.
+func (m *NotificationModel) CountUnread(ctx context.Context, userId int) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var count int
+	err := m.DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM notifications WHERE user_id = ? AND read_at IS NULL", userId).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// MarkRead marks a notification of a user as read and returns it, or nil
+// when the user has no such notification. Marking it again keeps the time
+// it was first read.
+func (m *NotificationModel) MarkRead(ctx context.Context, userId int, id int64, now time.Time) (*Notification, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, "UPDATE notifications SET read_at = ? WHERE id = ? AND user_id = ? AND read_at IS NULL", now, id, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	notification, err := scanNotification(m.DB.QueryRowContext(ctx, "SELECT "+notificationColumns+" FROM notifications WHERE id = ? AND user_id = ?", id, userId))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return notification, nil
+}
+
+// MarkAllRead marks every unread notification of a user as read and returns
+// how many there were.
+func (m *NotificationModel) MarkAllRead(ctx context.Context, userId int, now time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, "UPDATE notifications SET read_at = ? WHERE user_id = ? AND read_at IS NULL", now, userId)
+	if err != nil {
+		return 0, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(affected), nil
+}
+
+func scanNotification(row rowScanner) (*Notification, error) {
+	var notification Notification
+	var eventId sql.NullInt64
+	var readAt sql.NullTime
+
+	err := row.Scan(&notification.ID, &notification.UserId, &notification.Kind, &notification.Title, &notification.Body, &eventId, &readAt, &notification.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if eventId.Valid {
+		id := int(eventId.Int64)
+		notification.EventId = &id
+	}
+	if readAt.Valid {
+		notification.ReadAt = &readAt.Time
+	}
+
+	return &notification, nil
+}
@@ -0,0 +1,227 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type OrganizerModel struct {
+	DB *sql.DB
+}
+
+// Organizer is a user who helps the owner run an event. Each permission is
+// granted separately. Co-organizers are invited first and only hold their
+// permissions once they accept, which sets AcceptedAt.
+type Organizer struct {
+	EventId            int        `json:"event_id"`
+	EventName          string     `json:"event_name,omitempty"`
+	UserId             int        `json:"user_id"`
+	Name               string     `json:"name,omitempty"`
+	Email              string     `json:"email,omitempty"`
+	CanEdit            bool       `json:"can_edit"`
+	CanManageAttendees bool       `json:"can_manage_attendees"`
+	CanCheckIn         bool       `json:"can_check_in"`
+	AcceptedAt         *time.Time `json:"accepted_at,omitempty"`
+	InvitedAt          time.Time  `json:"invited_at"`
+}
+
+// Accepted reports whether the co-organizer accepted their invitation.
+func (o *Organizer) Accepted() bool {
+	return o.AcceptedAt != nil
+}
+
+const organizerColumns = "o.event_id, o.user_id, o.can_edit, o.can_manage_attendees, o.can_check_in, o.accepted_at, o.created_at"
+
+// Invite invites a user to co-organize an event, or replaces the
+// permissions of an existing co-organizer, who keeps their accepted or
+// pending state. It returns the co-organizer and whether they were newly
+// invited.
+func (m *OrganizerModel) Invite(ctx context.Context, organizer *Organizer) (*Organizer, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var invited bool
+	err := withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		existing, err := getOrganizer(ctx, tx, organizer.EventId, organizer.UserId, true)
+		if err != nil {
+			return err
+		}
+
+		if existing == nil {
+			invited = true
+			query := "INSERT INTO event_organizers (event_id, user_id, can_edit, can_manage_attendees, can_check_in, created_at) VALUES (?, ?, ?, ?, ?, ?)"
+			_, err = tx.ExecContext(ctx, query, organizer.EventId, organizer.UserId, organizer.CanEdit, organizer.CanManageAttendees, organizer.CanCheckIn, time.Now().UTC().Truncate(time.Second))
+		} else {
+			query := "UPDATE event_organizers SET can_edit = ?, can_manage_attendees = ?, can_check_in = ? WHERE event_id = ? AND user_id = ?"
+			_, err = tx.ExecContext(ctx, query, organizer.CanEdit, organizer.CanManageAttendees, organizer.CanCheckIn, organizer.EventId, organizer.UserId)
+		}
+		if err != nil {
+			return err
+		}
+
+		stored, err := getOrganizer(ctx, tx, organizer.EventId, organizer.UserId, false)
+		if err != nil {
+			return err
+		}
+
+		organizer.AcceptedAt = stored.AcceptedAt
+		organizer.InvitedAt = stored.InvitedAt
+
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	return organizer, invited, nil
+}
+
+// Accept accepts the invitation of a user to co-organize an event. It
+// returns the co-organizer, or nil when the user was not invited. Accepting
+// twice keeps the time of the first acceptance.
+func (m *OrganizerModel) Accept(ctx context.Context, eventId, userId int, now time.Time) (*Organizer, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, "UPDATE event_organizers SET accepted_at = ? WHERE event_id = ? AND user_id = ? AND accepted_at IS NULL", now, eventId, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	return getOrganizer(ctx, m.DB, eventId, userId, false)
+}
+
+// Get returns a co-organizer of an event, whether or not they accepted.
+func (m *OrganizerModel) Get(ctx context.Context, eventId, userId int) (*Organizer, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return getOrganizer(ctx, m.DB, eventId, userId, false)
+}
+
+func getOrganizer(ctx context.Context, q dbtx, eventId, userId int, lock bool) (*Organizer, error) {
+	query := "SELECT " + organizerColumns + " FROM event_organizers o WHERE o.event_id = ? AND o.user_id = ?"
+	if lock {
+		query += " FOR UPDATE"
+	}
+
+	organizer, err := scanOrganizer(q.QueryRowContext(ctx, query, eventId, userId))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return organizer, nil
+}
+
+// GetByEvent returns the co-organizers of an event, including those who
+// have not accepted yet.
+func (m *OrganizerModel) GetByEvent(ctx context.Context, eventId int) ([]*Organizer, error) {
+	query := `
+		SELECT ` + organizerColumns + `, u.name, u.email
+		FROM event_organizers o
+		JOIN users u ON u.id = o.user_id
+		WHERE o.event_id = ?
+		ORDER BY o.created_at, o.user_id
+	`
+
+	return m.getOrganizers(ctx, query, eventId, func(row rowScanner) (*Organizer, error) {
+		var name, email string
+		organizer, err := scanOrganizer(row, &name, &email)
+		if err != nil {
+			return nil, err
+		}
+
+		organizer.Name, organizer.Email = name, email
+		return organizer, nil
+	})
+}
+
+// GetInvitationsByUser returns the invitations a user has not accepted yet,
+// newest first.
+func (m *OrganizerModel) GetInvitationsByUser(ctx context.Context, userId int) ([]*Organizer, error) {
+	query := `
+		SELECT ` + organizerColumns + `, e.name
+		FROM event_organizers o
+		JOIN events e ON e.id = o.event_id
+		WHERE o.user_id = ? AND o.accepted_at IS NULL
+		ORDER BY o.created_at DESC, o.event_id DESC
+	`
+
+	return m.getOrganizers(ctx, query, userId, func(row rowScanner) (*Organizer, error) {
+		var eventName string
+		organizer, err := scanOrganizer(row, &eventName)
+		if err != nil {
+			return nil, err
+		}
+
+		organizer.EventName = eventName
+		return organizer, nil
+	})
+}
+
+func (m *OrganizerModel) getOrganizers(ctx context.Context, query string, id int, scan func(rowScanner) (*Organizer, error)) ([]*Organizer, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	organizers := []*Organizer{}
+	for rows.Next() {
+		organizer, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		organizers = append(organizers, organizer)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return organizers, nil
+}
+
+// scanOrganizer scans a row selected with organizerColumns, followed by any
+// extra columns.
+func scanOrganizer(row rowScanner, extra ...any) (*Organizer, error) {
+	var organizer Organizer
+	var acceptedAt sql.NullTime
+
+	dest := append([]any{&organizer.EventId, &organizer.UserId, &organizer.CanEdit, &organizer.CanManageAttendees, &organizer.CanCheckIn, &acceptedAt, &organizer.InvitedAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+
+	if acceptedAt.Valid {
+		organizer.AcceptedAt = &acceptedAt.Time
+	}
+
+	return &organizer, nil
+}
+
+func (m *OrganizerModel) Delete(ctx context.Context, eventId, userId int) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, "DELETE FROM event_organizers WHERE event_id = ? AND user_id = ?", eventId, userId)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
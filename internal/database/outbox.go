@@ -0,0 +1,224 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// Outbox topics. Models write a message to the outbox in the same
+// transaction as the change it reports, so it is published if and only if
+// the change is committed.
+const (
+	TopicEventCreated    = "event.created"
+	TopicEventUpdated    = "event.updated"
+	TopicEventDeleted    = "event.deleted"
+	TopicAttendeeAdded   = "attendee.added"
+	TopicAttendeeRemoved = "attendee.removed"
+)
+
+type OutboxModel struct {
+	DB *sql.DB
+}
+
+// OutboxMessage reports a change to an event, or to its attendees. OwnerId
+// is the owner of the event at the time of the change, so it is still known
+// after the event is deleted.
+type OutboxMessage struct {
+	ID        int64
+	Topic     string
+	EventId   int
+	OwnerId   int
+	Payload   json.RawMessage
+	Attempts  int
+	CreatedAt time.Time
+}
+
+// EventChange is the payload of the event topics. Previous is the event
+// before an update, and UserIds are the users attending or waitlisted at
+// the time of the change, since they can no longer be looked up once the
+// event is deleted.
+type EventChange struct {
+	Event    *Event `json:"event"`
+	Previous *Event `json:"previous,omitempty"`
+	UserIds  []int  `json:"user_ids,omitempty"`
+}
+
+// AttendeeChange is the payload of the attendee topics. ByOrganizer tells
+// whether the change was made by an organizer on the user's behalf rather
+// than by the user.
+type AttendeeChange struct {
+	Attendee    *Attendee `json:"attendee"`
+	ByOrganizer bool      `json:"by_organizer"`
+}
+
+// eventAudience returns the users holding a seat at an event, or waiting for
+// one.
+func eventAudience(ctx context.Context, q dbtx, eventId int) ([]int, error) {
+	query := "SELECT DISTINCT user_id FROM attendees WHERE event_id = ? AND status IN (?, ?, ?) ORDER BY user_id"
+
+	rows, err := q.QueryContext(ctx, query, eventId, AttendeeStatusConfirmed, AttendeeStatusPendingPayment, AttendeeStatusWaitlisted)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var userIds []int
+	for rows.Next() {
+		var userId int
+		if err := rows.Scan(&userId); err != nil {
+			return nil, err
+		}
+
+		userIds = append(userIds, userId)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return userIds, nil
+}
+
+// writeOutbox queues a message about an event from inside the transaction
+// making the change. It must be written before the event row is deleted.
+func writeOutbox(ctx context.Context, q dbtx, topic string, eventId int, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO outbox (topic, event_id, owner_id, payload, next_attempt_at, created_at)
+		SELECT ?, id, owner_id, ?, UTC_TIMESTAMP(), UTC_TIMESTAMP()
+		FROM events
+		WHERE id = ?
+	`
+
+	result, err := q.ExecContext(ctx, query, topic, string(data), eventId)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrEventNotFound
+	}
+
+	return nil
+}
+
+// ClaimPending returns up to limit unpublished messages that are due, in the
+// order they were written, and pushes their next attempt lease into the
+// future so no other dispatcher picks them up meanwhile. A message whose
+// outcome is never recorded is published again once the lease runs out.
+func (m *OutboxModel) ClaimPending(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]*OutboxMessage, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var messages []*OutboxMessage
+	err := withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		query := `
+			SELECT id, topic, event_id, owner_id, payload, attempts, created_at
+			FROM outbox
+			WHERE published_at IS NULL AND next_attempt_at <= ?
+			ORDER BY id
+			LIMIT ?
+			FOR UPDATE
+		`
+
+		rows, err := tx.QueryContext(ctx, query, now, limit)
+		if err != nil {
+			return err
+		}
+
+		defer rows.Close()
+
+		for rows.Next() {
+			var message OutboxMessage
+			var payload string
+
+			err := rows.Scan(&message.ID, &message.Topic, &message.EventId, &message.OwnerId, &payload, &message.Attempts, &message.CreatedAt)
+			if err != nil {
+				return err
+			}
+
+			message.Payload = json.RawMessage(payload)
+			messages = append(messages, &message)
+		}
+
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		if len(messages) == 0 {
+			return nil
+		}
+
+		args := []any{now.Add(lease)}
+		for _, message := range messages {
+			args = append(args, message.ID)
+		}
+
+		_, err = tx.ExecContext(ctx, "UPDATE outbox SET next_attempt_at = ? WHERE id IN (?"+strings.Repeat(", ?", len(messages)-1)+")", args...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+// MarkPublished records that a message was published.
+func (m *OutboxModel) MarkPublished(ctx context.Context, id int64, now time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := "UPDATE outbox SET attempts = attempts + 1, last_error = NULL, published_at = ? WHERE id = ?"
+
+	_, err := m.DB.ExecContext(ctx, query, now, id)
+	return err
+}
+
+// MarkFailed records a failed attempt to publish a message, which is tried
+// again at nextAttemptAt.
+func (m *OutboxModel) MarkFailed(ctx context.Context, id int64, reason string, nextAttemptAt time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	if len(reason) > 500 {
+		reason = reason[:500]
+	}
+
+	query := "UPDATE outbox SET attempts = attempts + 1, last_error = ?, next_attempt_at = ? WHERE id = ?"
+
+	_, err := m.DB.ExecContext(ctx, query, reason, nextAttemptAt, id)
+	return err
+}
+
+// DeletePublished removes the messages published before the given time and
+// returns how many were removed.
+func (m *OutboxModel) DeletePublished(ctx context.Context, before time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, "DELETE FROM outbox WHERE published_at < ?", before)
+	if err != nil {
+		return 0, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(affected), nil
+}
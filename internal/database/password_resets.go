@@ -0,0 +1,65 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var ErrResetTokenInvalid = errors.New("password reset token is invalid, expired or already used")
+
+type PasswordResetModel struct {
+	DB *sql.DB
+}
+
+// Create stores the hash of a new reset token for a user. Only the hash is
+// kept so a leaked database cannot be used to take over accounts.
+func (m *PasswordResetModel) Create(ctx context.Context, userId int, tokenHash string, now, expiresAt time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := "INSERT INTO password_reset_tokens (user_id, token_hash, expires_at, created_at) VALUES (?, ?, ?, ?)"
+
+	_, err := m.DB.ExecContext(ctx, query, userId, tokenHash, expiresAt, now)
+	return err
+}
+
+// ResetPassword consumes the reset token with the given hash and replaces the
+// password of its user in a single transaction. The token and every other
+// outstanding reset token of the user are marked used, and all of the user's
+// sessions are revoked.
+func (m *PasswordResetModel) ResetPassword(ctx context.Context, tokenHash, passwordHash string, now time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var userId int
+	err := withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		query := `
+			SELECT user_id FROM password_reset_tokens
+			WHERE token_hash = ? AND used_at IS NULL AND expires_at > ?
+			FOR UPDATE
+		`
+		if err := tx.QueryRowContext(ctx, query, tokenHash, now).Scan(&userId); err != nil {
+			if err == sql.ErrNoRows {
+				return ErrResetTokenInvalid
+			}
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, "UPDATE password_reset_tokens SET used_at = ? WHERE user_id = ? AND used_at IS NULL", now, userId); err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, "UPDATE users SET password = ? WHERE id = ?", passwordHash, userId); err != nil {
+			return err
+		}
+
+		return revokeAllForUser(ctx, tx, userId, now)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return userId, nil
+}
@@ -0,0 +1,556 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+const (
+	PaymentStatusPending   = "pending"
+	PaymentStatusSucceeded = "succeeded"
+	PaymentStatusFailed    = "failed"
+	PaymentStatusRefunded  = "refunded"
+)
+
+var (
+	ErrPaymentNotFound       = errors.New("payment not found")
+	ErrPaymentSettled        = errors.New("payment has already been settled")
+	ErrPaymentNotRefundable  = errors.New("payment has not succeeded")
+	ErrRefundConflict        = errors.New("payment was refunded concurrently")
+	ErrRegistrationCancelled = errors.New("registration was cancelled before the payment went through")
+)
+
+type PaymentModel struct {
+	DB *sql.DB
+}
+
+// Payment is the money taken for a paid registration. Amount is what was
+// charged after DiscountAmount was taken off the ticket price by a promo
+// code; all amounts are in the minor unit of Currency. A payment keeps its
+// record after the registration it paid for is removed, with AttendeeId
+// cleared. A pending payment holds its seat until ExpiresAt, after which it
+// is reconciled with the payment provider.
+type Payment struct {
+	ID             int        `json:"id"`
+	EventId        int        `json:"event_id"`
+	UserId         int        `json:"user_id"`
+	AttendeeId     *int       `json:"attendee_id,omitempty"`
+	TicketTypeId   int        `json:"ticket_type_id"`
+	Amount         int64      `json:"amount"`
+	DiscountAmount int64      `json:"discount_amount"`
+	Currency       string     `json:"currency"`
+	Status         string     `json:"status"`
+	ChargeId       string     `json:"charge_id,omitempty"`
+	RefundedAmount int64      `json:"refunded_amount"`
+	FailureReason  string     `json:"failure_reason,omitempty"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+const paymentColumns = "id, event_id, user_id, attendee_id, ticket_type_id, amount, discount_amount, currency, status, charge_id, refunded_amount, failure_reason, expires_at, created_at, updated_at"
+
+// Purchase asks for a ticket of a given type, optionally discounted by a
+// promo code. Complimentary tickets are issued by organizers: they are never
+// charged, ignore promo codes and can be issued outside the sales window.
+// HoldFor is how long a paid ticket holds its seat waiting for the payment.
+type Purchase struct {
+	EventId       int
+	UserId        int
+	TicketTypeId  int
+	Occurrence    *time.Time
+	PromoCode     string
+	Complimentary bool
+	HoldFor       time.Duration
+}
+
+// Reserve registers a user with a ticket type and redeems the promo code of
+// the purchase, if any. Free and complimentary tickets, and tickets a promo
+// code made free, are confirmed straight away. Paid tickets hold a seat in the
+// pending_payment state and come with a pending payment for the caller to
+// charge and then settle with Complete or Fail.
+//
+// Unlike Register, a full event or ticket type never puts the user on the
+// waitlist, since a seat given away later could not be paid for.
+func (m *PaymentModel) Reserve(ctx context.Context, purchase Purchase, now time.Time) (*Attendee, *Payment, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var attendee *Attendee
+	var payment *Payment
+	err := withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		capacity, err := lockEvent(ctx, tx, purchase.EventId)
+		if err != nil {
+			return err
+		}
+
+		ticketType, err := lockTicketType(ctx, tx, purchase.EventId, purchase.TicketTypeId)
+		if err != nil {
+			return err
+		}
+
+		if !purchase.Complimentary && !ticketType.OnSale(now) {
+			return ErrTicketTypeNotOnSale
+		}
+
+		if ticketType.SoldOut() {
+			return ErrTicketTypeSoldOut
+		}
+
+		existing, err := checkNotRegistered(ctx, tx, purchase.EventId, purchase.UserId, purchase.Occurrence)
+		if err != nil {
+			return err
+		}
+
+		if capacity > 0 {
+			taken, err := seatsTaken(ctx, tx, purchase.EventId, purchase.Occurrence)
+			if err != nil {
+				return err
+			}
+
+			if taken >= capacity {
+				return ErrEventFull
+			}
+		}
+
+		var promoCode *PromoCode
+		var discount int64
+		if purchase.PromoCode != "" && !purchase.Complimentary {
+			promoCode, discount, err = redeemPromoCode(ctx, tx, purchase.EventId, purchase.PromoCode, ticketType, now)
+			if err != nil {
+				return err
+			}
+		}
+
+		amount := ticketType.Price - discount
+		paid := amount > 0 && !purchase.Complimentary
+
+		attendee = existing
+		if attendee == nil {
+			attendee = &Attendee{EventId: purchase.EventId, UserId: purchase.UserId, Occurrence: purchase.Occurrence}
+		}
+		attendee.TicketTypeId = &ticketType.ID
+		attendee.RSVPStatus = RSVPGoing
+		attendee.Status = AttendeeStatusConfirmed
+		if paid {
+			attendee.Status = AttendeeStatusPendingPayment
+		}
+
+		if attendee.ID == 0 {
+			if err := insertAttendee(ctx, tx, attendee); err != nil {
+				if isDuplicateEntry(err) {
+					return ErrAlreadyRegistered
+				}
+				return err
+			}
+		} else {
+			query := "UPDATE attendees SET ticket_type_id = ?, status = ?, rsvp_status = ?, created_at = CURRENT_TIMESTAMP WHERE id = ?"
+			if _, err := tx.ExecContext(ctx, query, attendee.TicketTypeId, attendee.Status, attendee.RSVPStatus, attendee.ID); err != nil {
+				return err
+			}
+		}
+
+		if paid {
+			expiresAt := now.Add(purchase.HoldFor).UTC().Truncate(time.Second)
+			payment = &Payment{
+				EventId:        purchase.EventId,
+				UserId:         purchase.UserId,
+				AttendeeId:     &attendee.ID,
+				TicketTypeId:   ticketType.ID,
+				Amount:         amount,
+				DiscountAmount: discount,
+				Currency:       ticketType.Currency,
+				Status:         PaymentStatusPending,
+				ExpiresAt:      &expiresAt,
+				CreatedAt:      now,
+				UpdatedAt:      now,
+			}
+
+			query := `
+				INSERT INTO payments (event_id, user_id, attendee_id, ticket_type_id, amount, discount_amount, currency, status, expires_at, created_at, updated_at)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			`
+
+			result, err := tx.ExecContext(ctx, query, payment.EventId, payment.UserId, payment.AttendeeId, payment.TicketTypeId, payment.Amount, payment.DiscountAmount, payment.Currency, payment.Status, payment.ExpiresAt, now, now)
+			if err != nil {
+				return err
+			}
+
+			id, err := result.LastInsertId()
+			if err != nil {
+				return err
+			}
+
+			payment.ID = int(id)
+		}
+
+		if !paid {
+			if err := writeOutbox(ctx, tx, TopicAttendeeAdded, purchase.EventId, AttendeeChange{Attendee: attendee, ByOrganizer: purchase.Complimentary}); err != nil {
+				return err
+			}
+		}
+
+		if promoCode == nil {
+			return nil
+		}
+
+		var paymentId *int
+		if payment != nil {
+			paymentId = &payment.ID
+		}
+
+		query := `
+			INSERT INTO promo_redemptions (promo_code_id, user_id, ticket_type_id, payment_id, original_amount, discount_amount, currency, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		`
+
+		_, err = tx.ExecContext(ctx, query, promoCode.ID, purchase.UserId, ticketType.ID, paymentId, ticketType.Price, discount, ticketType.Currency, now)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return attendee, payment, nil
+}
+
+// Complete records that a pending payment was charged and confirms the
+// registration it holds a seat for. If the registration was cancelled in
+// the meantime, the payment is still recorded as succeeded and
+// ErrRegistrationCancelled is returned so the caller can refund it.
+func (m *PaymentModel) Complete(ctx context.Context, paymentId int, chargeId string) (*Attendee, *Payment, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var attendee *Attendee
+	var payment *Payment
+	err := withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		eventId, err := paymentEventId(ctx, tx, paymentId)
+		if err != nil {
+			return err
+		}
+
+		if _, err := lockEvent(ctx, tx, eventId); err != nil {
+			return err
+		}
+
+		payment, err = lockPayment(ctx, tx, paymentId)
+		if err != nil {
+			return err
+		}
+
+		if payment.Status != PaymentStatusPending {
+			return ErrPaymentSettled
+		}
+
+		payment.Status = PaymentStatusSucceeded
+		payment.ChargeId = chargeId
+		_, err = tx.ExecContext(ctx, "UPDATE payments SET status = ?, charge_id = ? WHERE id = ?", payment.Status, payment.ChargeId, payment.ID)
+		if err != nil {
+			return err
+		}
+
+		if payment.AttendeeId != nil {
+			attendee, err = getAttendee(ctx, tx, "SELECT "+attendeeColumns+" FROM attendees WHERE id = ?", *payment.AttendeeId)
+			if err != nil {
+				return err
+			}
+		}
+
+		if attendee == nil || attendee.Status != AttendeeStatusPendingPayment {
+			attendee = nil
+			return nil
+		}
+
+		attendee.Status = AttendeeStatusConfirmed
+		_, err = tx.ExecContext(ctx, "UPDATE attendees SET status = ? WHERE id = ?", attendee.Status, attendee.ID)
+		if err != nil {
+			return err
+		}
+
+		return writeOutbox(ctx, tx, TopicAttendeeAdded, eventId, AttendeeChange{Attendee: attendee})
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if attendee == nil {
+		return nil, payment, ErrRegistrationCancelled
+	}
+
+	return attendee, payment, nil
+}
+
+// Fail records that a pending payment could not be charged and releases the
+// seat it held, promoting waitlisted users into it. A promo code redeemed
+// for it can be used again.
+func (m *PaymentModel) Fail(ctx context.Context, paymentId int, reason string) (*Payment, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var payment *Payment
+	err := withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		eventId, err := paymentEventId(ctx, tx, paymentId)
+		if err != nil {
+			return err
+		}
+
+		capacity, err := lockEvent(ctx, tx, eventId)
+		if err != nil {
+			return err
+		}
+
+		payment, err = lockPayment(ctx, tx, paymentId)
+		if err != nil {
+			return err
+		}
+
+		if payment.Status != PaymentStatusPending {
+			return ErrPaymentSettled
+		}
+
+		payment.Status = PaymentStatusFailed
+		payment.FailureReason = reason
+		_, err = tx.ExecContext(ctx, "UPDATE payments SET status = ?, failure_reason = ? WHERE id = ?", payment.Status, payment.FailureReason, payment.ID)
+		if err != nil {
+			return err
+		}
+
+		if err := releaseRedemption(ctx, tx, payment.ID); err != nil {
+			return err
+		}
+
+		if payment.AttendeeId == nil {
+			return nil
+		}
+
+		result, err := tx.ExecContext(ctx, "DELETE FROM attendees WHERE id = ? AND status = ?", *payment.AttendeeId, AttendeeStatusPendingPayment)
+		if err != nil {
+			return err
+		}
+
+		if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+			return err
+		}
+
+		payment.AttendeeId = nil
+		_, err = promoteFromWaitlist(ctx, tx, eventId, capacity)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return payment, nil
+}
+
+// RecordRefund adds a refund to a payment. refundedBefore is the refunded
+// amount the refund was made against; if another refund was recorded since,
+// ErrRefundConflict is returned. A payment refunded in full cancels the
+// registration it paid for and promotes waitlisted users into the seat.
+func (m *PaymentModel) RecordRefund(ctx context.Context, paymentId int, amount, refundedBefore int64) (*Payment, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var payment *Payment
+	err := withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		eventId, err := paymentEventId(ctx, tx, paymentId)
+		if err != nil {
+			return err
+		}
+
+		capacity, err := lockEvent(ctx, tx, eventId)
+		if err != nil {
+			return err
+		}
+
+		payment, err = lockPayment(ctx, tx, paymentId)
+		if err != nil {
+			return err
+		}
+
+		if payment.Status != PaymentStatusSucceeded {
+			return ErrPaymentNotRefundable
+		}
+
+		if payment.RefundedAmount != refundedBefore {
+			return ErrRefundConflict
+		}
+
+		payment.RefundedAmount += amount
+		if payment.RefundedAmount >= payment.Amount {
+			payment.Status = PaymentStatusRefunded
+		}
+
+		_, err = tx.ExecContext(ctx, "UPDATE payments SET status = ?, refunded_amount = ? WHERE id = ?", payment.Status, payment.RefundedAmount, payment.ID)
+		if err != nil {
+			return err
+		}
+
+		if payment.Status != PaymentStatusRefunded || payment.AttendeeId == nil {
+			return nil
+		}
+
+		attendee, err := getAttendee(ctx, tx, "SELECT "+attendeeColumns+" FROM attendees WHERE id = ?", *payment.AttendeeId)
+		if err != nil || attendee == nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, "DELETE FROM attendees WHERE id = ?", attendee.ID); err != nil {
+			return err
+		}
+
+		if err := writeOutbox(ctx, tx, TopicAttendeeRemoved, eventId, AttendeeChange{Attendee: attendee}); err != nil {
+			return err
+		}
+
+		payment.AttendeeId = nil
+		if !holdsSeat(attendee.Status) {
+			return nil
+		}
+
+		_, err = promoteFromWaitlist(ctx, tx, eventId, capacity)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return payment, nil
+}
+
+// Get returns a payment made for an event, or nil when there is none.
+func (m *PaymentModel) Get(ctx context.Context, eventId, id int) (*Payment, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return getPayment(ctx, m.DB, "SELECT "+paymentColumns+" FROM payments WHERE id = ? AND event_id = ?", id, eventId)
+}
+
+// GetByEvent returns every payment made for an event, newest first.
+func (m *PaymentModel) GetByEvent(ctx context.Context, eventId int) ([]*Payment, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, "SELECT "+paymentColumns+" FROM payments WHERE event_id = ? ORDER BY created_at DESC, id DESC", eventId)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	payments := []*Payment{}
+	for rows.Next() {
+		payment, err := scanPayment(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		payments = append(payments, payment)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return payments, nil
+}
+
+// GetExpired returns up to limit pending payments whose seat hold ran out
+// by now, longest expired first.
+func (m *PaymentModel) GetExpired(ctx context.Context, now time.Time, limit int) ([]*Payment, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := "SELECT " + paymentColumns + " FROM payments WHERE status = ? AND expires_at <= ? ORDER BY expires_at, id LIMIT ?"
+
+	rows, err := m.DB.QueryContext(ctx, query, PaymentStatusPending, now, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	payments := []*Payment{}
+	for rows.Next() {
+		payment, err := scanPayment(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		payments = append(payments, payment)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return payments, nil
+}
+
+// paymentEventId looks up the event of a payment so its row can be locked
+// before the payment's, in the same order as every other registration
+// change.
+func paymentEventId(ctx context.Context, tx *sql.Tx, paymentId int) (int, error) {
+	var eventId int
+	err := tx.QueryRowContext(ctx, "SELECT event_id FROM payments WHERE id = ?", paymentId).Scan(&eventId)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, ErrPaymentNotFound
+		}
+		return 0, err
+	}
+
+	return eventId, nil
+}
+
+func lockPayment(ctx context.Context, tx *sql.Tx, id int) (*Payment, error) {
+	payment, err := getPayment(ctx, tx, "SELECT "+paymentColumns+" FROM payments WHERE id = ? FOR UPDATE", id)
+	if err != nil {
+		return nil, err
+	}
+
+	if payment == nil {
+		return nil, ErrPaymentNotFound
+	}
+
+	return payment, nil
+}
+
+func getPayment(ctx context.Context, q dbtx, query string, args ...any) (*Payment, error) {
+	payment, err := scanPayment(q.QueryRowContext(ctx, query, args...))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return payment, nil
+}
+
+func scanPayment(row rowScanner) (*Payment, error) {
+	var payment Payment
+	var attendeeId sql.NullInt64
+	var chargeId, failureReason sql.NullString
+	var expiresAt sql.NullTime
+
+	err := row.Scan(&payment.ID, &payment.EventId, &payment.UserId, &attendeeId, &payment.TicketTypeId, &payment.Amount, &payment.DiscountAmount, &payment.Currency, &payment.Status, &chargeId, &payment.RefundedAmount, &failureReason, &expiresAt, &payment.CreatedAt, &payment.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if attendeeId.Valid {
+		id := int(attendeeId.Int64)
+		payment.AttendeeId = &id
+	}
+	payment.ChargeId = chargeId.String
+	payment.FailureReason = failureReason.String
+	if expiresAt.Valid {
+		payment.ExpiresAt = &expiresAt.Time
+	}
+
+	return &payment, nil
+}
@@ -0,0 +1,88 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"rest-api-event-app/internal/testdb"
+	"testing"
+	"time"
+)
+
+func createTestTicketType(t *testing.T, db *sql.DB, eventId int, price int64) *TicketType {
+	t.Helper()
+
+	ticketTypes := TicketTypeModel{DB: db}
+
+	ticketType, err := ticketTypes.Insert(context.Background(), &TicketType{EventId: eventId, Name: testdb.UniqueName("ticket"), Price: price, Currency: "EUR"})
+	if err != nil {
+		t.Fatalf("creating ticket type: %s", err)
+	}
+
+	return ticketType
+}
+
+func containsPayment(payments []*Payment, id int) bool {
+	for _, payment := range payments {
+		if payment.ID == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+// TestExpiredPaymentReleasesSeat holds the only seat of an event with a
+// payment that never settles, as after a crash between reserving and
+// charging, and checks that reconciliation can find and release it.
+func TestExpiredPaymentReleasesSeat(t *testing.T) {
+	db := testdb.Open(t)
+	payments := PaymentModel{DB: db}
+	ctx := context.Background()
+
+	owner := createTestUser(t, db)
+	event := createTestEvent(t, db, owner.ID, 1)
+	ticketType := createTestTicketType(t, db, event.ID, 2500)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	purchase := func(userId int) (*Attendee, *Payment, error) {
+		return payments.Reserve(ctx, Purchase{EventId: event.ID, UserId: userId, TicketTypeId: ticketType.ID, HoldFor: 10 * time.Minute}, now)
+	}
+
+	_, payment, err := purchase(createTestUser(t, db).ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payment == nil || payment.ExpiresAt == nil || !payment.ExpiresAt.Equal(now.Add(10*time.Minute)) {
+		t.Fatalf("pending payment = %+v, want one expiring in 10 minutes", payment)
+	}
+
+	second := createTestUser(t, db)
+	if _, _, err := purchase(second.ID); !errors.Is(err, ErrEventFull) {
+		t.Fatalf("second purchase while the seat is held: got %v, want ErrEventFull", err)
+	}
+
+	expired, err := payments.GetExpired(ctx, now.Add(5*time.Minute), 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if containsPayment(expired, payment.ID) {
+		t.Error("payment expired before its hold ran out")
+	}
+
+	expired, err = payments.GetExpired(ctx, now.Add(10*time.Minute), 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !containsPayment(expired, payment.ID) {
+		t.Fatal("payment did not expire once its hold ran out")
+	}
+
+	if _, err := payments.Fail(ctx, payment.ID, "seat hold expired"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := purchase(second.ID); err != nil {
+		t.Errorf("purchase after the hold was released: %s", err)
+	}
+}
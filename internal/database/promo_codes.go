@@ -0,0 +1,479 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	DiscountPercent = "percent"
+	DiscountFixed   = "fixed"
+)
+
+var (
+	ErrPromoCodeNotFound      = errors.New("promo code not found")
+	ErrPromoCodeExists        = errors.New("event already has this promo code")
+	ErrPromoCodeInUse         = errors.New("promo code has already been redeemed")
+	ErrPromoCodeExpired       = errors.New("promo code has expired")
+	ErrPromoCodeExhausted     = errors.New("promo code has been used up")
+	ErrPromoCodeNotApplicable = errors.New("promo code does not apply to this ticket type")
+	ErrMaxUsesBelowRedeemed   = errors.New("max_uses is below the number of redemptions")
+	ErrInvalidPromoCode       = errors.New("code may only contain letters, digits, dashes and underscores")
+	ErrInvalidDiscount        = errors.New("percent discounts must be at most 100 and have no currency; fixed discounts need a currency")
+)
+
+type PromoCodeModel struct {
+	DB *sql.DB
+}
+
+// PromoCode gives a discount on the tickets of an event. Codes are matched
+// case-insensitively and stored in upper case. A percent discount takes
+// DiscountValue percent off the price; a fixed discount takes DiscountValue,
+// in the minor unit of Currency, off tickets priced in that currency. A
+// MaxUses of 0 means the code can be redeemed any number of times, and an
+// empty TicketTypeIds means it applies to every ticket type of the event.
+type PromoCode struct {
+	ID            int        `json:"id"`
+	EventId       int        `json:"event_id"`
+	Code          string     `json:"code" binding:"required,min=3,max=50"`
+	DiscountType  string     `json:"discount_type" binding:"required,oneof=percent fixed"`
+	DiscountValue int64      `json:"discount_value" binding:"required,min=1"`
+	Currency      string     `json:"currency,omitempty" binding:"omitempty,iso4217"`
+	MaxUses       int        `json:"max_uses" binding:"min=0"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"`
+	TicketTypeIds []int      `json:"ticket_type_ids,omitempty" binding:"max=100"`
+	TimesRedeemed int        `json:"times_redeemed"`
+}
+
+const promoCodeColumns = "id, event_id, code, discount_type, discount_value, currency, max_uses, times_redeemed, expires_at, ticket_type_ids"
+
+// PromoRedemption is one use of a promo code, as listed in its redemption
+// report. PaymentStatus is empty when the discount made the ticket free.
+type PromoRedemption struct {
+	ID             int       `json:"id"`
+	UserId         int       `json:"user_id"`
+	Name           string    `json:"name"`
+	Email          string    `json:"email"`
+	TicketTypeId   int       `json:"ticket_type_id"`
+	PaymentId      *int      `json:"payment_id,omitempty"`
+	PaymentStatus  string    `json:"payment_status,omitempty"`
+	OriginalAmount int64     `json:"original_amount"`
+	DiscountAmount int64     `json:"discount_amount"`
+	Currency       string    `json:"currency"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// Normalize upper-cases the code and validates what binding tags cannot.
+func (p *PromoCode) Normalize() error {
+	p.Code = strings.ToUpper(strings.TrimSpace(p.Code))
+	for _, r := range p.Code {
+		if !(r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return ErrInvalidPromoCode
+		}
+	}
+
+	switch p.DiscountType {
+	case DiscountPercent:
+		if p.DiscountValue > 100 || p.Currency != "" {
+			return ErrInvalidDiscount
+		}
+	case DiscountFixed:
+		if p.Currency == "" {
+			return ErrInvalidDiscount
+		}
+	}
+
+	if p.ExpiresAt != nil {
+		expiresAt := p.ExpiresAt.UTC()
+		p.ExpiresAt = &expiresAt
+	}
+
+	slices.Sort(p.TicketTypeIds)
+	p.TicketTypeIds = slices.Compact(p.TicketTypeIds)
+
+	return nil
+}
+
+// Discount returns how much the code takes off a ticket, or
+// ErrPromoCodeNotApplicable when it cannot be used for the ticket type.
+// Percent discounts are rounded down to the minor unit, and no discount
+// exceeds the price.
+func (p *PromoCode) Discount(ticketType *TicketType) (int64, error) {
+	if len(p.TicketTypeIds) > 0 && !slices.Contains(p.TicketTypeIds, ticketType.ID) {
+		return 0, ErrPromoCodeNotApplicable
+	}
+
+	switch p.DiscountType {
+	case DiscountPercent:
+		return ticketType.Price * p.DiscountValue / 100, nil
+	case DiscountFixed:
+		if p.Currency != ticketType.Currency {
+			return 0, ErrPromoCodeNotApplicable
+		}
+		return min(p.DiscountValue, ticketType.Price), nil
+	}
+
+	return 0, ErrPromoCodeNotApplicable
+}
+
+func (p *PromoCode) ticketTypeIdsValue() (any, error) {
+	if len(p.TicketTypeIds) == 0 {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(p.TicketTypeIds)
+	if err != nil {
+		return nil, err
+	}
+
+	return string(data), nil
+}
+
+func nullString(s string) any {
+	if s == "" {
+		return nil
+	}
+
+	return s
+}
+
+// Insert creates a promo code. Every ticket type it is restricted to must
+// belong to its event.
+func (m *PromoCodeModel) Insert(ctx context.Context, promoCode *PromoCode) (*PromoCode, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	ticketTypeIds, err := promoCode.ticketTypeIdsValue()
+	if err != nil {
+		return nil, err
+	}
+
+	err = withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		if err := checkTicketTypes(ctx, tx, promoCode.EventId, promoCode.TicketTypeIds); err != nil {
+			return err
+		}
+
+		query := `
+			INSERT INTO promo_codes (event_id, code, discount_type, discount_value, currency, max_uses, expires_at, ticket_type_ids)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		`
+
+		result, err := tx.ExecContext(ctx, query, promoCode.EventId, promoCode.Code, promoCode.DiscountType, promoCode.DiscountValue, nullString(promoCode.Currency), promoCode.MaxUses, promoCode.ExpiresAt, ticketTypeIds)
+		if err != nil {
+			if isDuplicateEntry(err) {
+				return ErrPromoCodeExists
+			}
+			return err
+		}
+
+		id, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		promoCode.ID = int(id)
+		promoCode.TimesRedeemed = 0
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return promoCode, nil
+}
+
+// Update replaces the settings of a promo code. Redemptions already made
+// keep their discount, but max_uses cannot drop below their number.
+func (m *PromoCodeModel) Update(ctx context.Context, promoCode *PromoCode) (*PromoCode, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	ticketTypeIds, err := promoCode.ticketTypeIdsValue()
+	if err != nil {
+		return nil, err
+	}
+
+	err = withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		existing, err := getPromoCode(ctx, tx, "SELECT "+promoCodeColumns+" FROM promo_codes WHERE id = ? AND event_id = ? FOR UPDATE", promoCode.ID, promoCode.EventId)
+		if err != nil {
+			return err
+		}
+
+		if existing == nil {
+			return ErrPromoCodeNotFound
+		}
+
+		if promoCode.MaxUses > 0 && promoCode.MaxUses < existing.TimesRedeemed {
+			return ErrMaxUsesBelowRedeemed
+		}
+
+		if err := checkTicketTypes(ctx, tx, promoCode.EventId, promoCode.TicketTypeIds); err != nil {
+			return err
+		}
+
+		query := `
+			UPDATE promo_codes
+			SET code = ?, discount_type = ?, discount_value = ?, currency = ?, max_uses = ?, expires_at = ?, ticket_type_ids = ?
+			WHERE id = ?
+		`
+
+		_, err = tx.ExecContext(ctx, query, promoCode.Code, promoCode.DiscountType, promoCode.DiscountValue, nullString(promoCode.Currency), promoCode.MaxUses, promoCode.ExpiresAt, ticketTypeIds, promoCode.ID)
+		if err != nil {
+			if isDuplicateEntry(err) {
+				return ErrPromoCodeExists
+			}
+			return err
+		}
+
+		promoCode.TimesRedeemed = existing.TimesRedeemed
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return promoCode, nil
+}
+
+// GetByEvent returns the promo codes of an event in the order they were
+// created.
+func (m *PromoCodeModel) GetByEvent(ctx context.Context, eventId int) ([]*PromoCode, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, "SELECT "+promoCodeColumns+" FROM promo_codes WHERE event_id = ? ORDER BY id", eventId)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	promoCodes := []*PromoCode{}
+	for rows.Next() {
+		promoCode, err := scanPromoCode(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		promoCodes = append(promoCodes, promoCode)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return promoCodes, nil
+}
+
+// Get returns a promo code of an event, or nil when there is none.
+func (m *PromoCodeModel) Get(ctx context.Context, eventId, id int) (*PromoCode, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return getPromoCode(ctx, m.DB, "SELECT "+promoCodeColumns+" FROM promo_codes WHERE id = ? AND event_id = ?", id, eventId)
+}
+
+// Delete removes a promo code that has never been redeemed. Codes that have
+// been fail with ErrPromoCodeInUse so their redemption report is kept; set
+// their expiry instead.
+func (m *PromoCodeModel) Delete(ctx context.Context, eventId, id int) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var deleted bool
+	err := withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		promoCode, err := getPromoCode(ctx, tx, "SELECT "+promoCodeColumns+" FROM promo_codes WHERE id = ? AND event_id = ? FOR UPDATE", id, eventId)
+		if err != nil || promoCode == nil {
+			return err
+		}
+
+		if promoCode.TimesRedeemed > 0 {
+			return ErrPromoCodeInUse
+		}
+
+		if _, err := tx.ExecContext(ctx, "DELETE FROM promo_codes WHERE id = ?", id); err != nil {
+			return err
+		}
+
+		deleted = true
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return deleted, nil
+}
+
+// GetRedemptions returns the redemptions of a promo code, newest first.
+func (m *PromoCodeModel) GetRedemptions(ctx context.Context, promoCodeId int) ([]*PromoRedemption, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `
+		SELECT r.id, r.user_id, u.name, u.email, r.ticket_type_id, r.payment_id, p.status, r.original_amount, r.discount_amount, r.currency, r.created_at
+		FROM promo_redemptions r
+		JOIN users u ON u.id = r.user_id
+		LEFT JOIN payments p ON p.id = r.payment_id
+		WHERE r.promo_code_id = ?
+		ORDER BY r.created_at DESC, r.id DESC
+	`
+
+	rows, err := m.DB.QueryContext(ctx, query, promoCodeId)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	redemptions := []*PromoRedemption{}
+	for rows.Next() {
+		var redemption PromoRedemption
+		var paymentId sql.NullInt64
+		var paymentStatus sql.NullString
+
+		err := rows.Scan(&redemption.ID, &redemption.UserId, &redemption.Name, &redemption.Email, &redemption.TicketTypeId, &paymentId, &paymentStatus, &redemption.OriginalAmount, &redemption.DiscountAmount, &redemption.Currency, &redemption.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		if paymentId.Valid {
+			id := int(paymentId.Int64)
+			redemption.PaymentId = &id
+		}
+		redemption.PaymentStatus = paymentStatus.String
+
+		redemptions = append(redemptions, &redemption)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return redemptions, nil
+}
+
+// redeemPromoCode applies a promo code to a ticket and counts the use. The
+// use is counted with a conditional update, so a capped code is never
+// redeemed more often than allowed, however many purchases race for it.
+func redeemPromoCode(ctx context.Context, tx *sql.Tx, eventId int, code string, ticketType *TicketType, now time.Time) (*PromoCode, int64, error) {
+	promoCode, err := getPromoCode(ctx, tx, "SELECT "+promoCodeColumns+" FROM promo_codes WHERE event_id = ? AND code = ? FOR UPDATE", eventId, strings.ToUpper(strings.TrimSpace(code)))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if promoCode == nil {
+		return nil, 0, ErrPromoCodeNotFound
+	}
+
+	if promoCode.ExpiresAt != nil && !now.Before(*promoCode.ExpiresAt) {
+		return nil, 0, ErrPromoCodeExpired
+	}
+
+	discount, err := promoCode.Discount(ticketType)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	result, err := tx.ExecContext(ctx, "UPDATE promo_codes SET times_redeemed = times_redeemed + 1 WHERE id = ? AND (max_uses = 0 OR times_redeemed < max_uses)", promoCode.ID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if affected == 0 {
+		return nil, 0, ErrPromoCodeExhausted
+	}
+
+	promoCode.TimesRedeemed++
+	return promoCode, discount, nil
+}
+
+// releaseRedemption gives back the use of a promo code made for a payment
+// that failed.
+func releaseRedemption(ctx context.Context, tx *sql.Tx, paymentId int) error {
+	var id, promoCodeId int
+	err := tx.QueryRowContext(ctx, "SELECT id, promo_code_id FROM promo_redemptions WHERE payment_id = ?", paymentId).Scan(&id, &promoCodeId)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM promo_redemptions WHERE id = ?", id); err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE promo_codes SET times_redeemed = times_redeemed - 1 WHERE id = ? AND times_redeemed > 0", promoCodeId)
+	return err
+}
+
+// checkTicketTypes fails with ErrTicketTypeNotFound unless every ticket type
+// belongs to the event.
+func checkTicketTypes(ctx context.Context, tx *sql.Tx, eventId int, ids []int) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	query := "SELECT COUNT(*) FROM ticket_types WHERE event_id = ? AND id IN (?" + strings.Repeat(", ?", len(ids)-1) + ")"
+	args := []any{eventId}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+
+	var count int
+	if err := tx.QueryRowContext(ctx, query, args...).Scan(&count); err != nil {
+		return err
+	}
+
+	if count != len(ids) {
+		return ErrTicketTypeNotFound
+	}
+
+	return nil
+}
+
+func getPromoCode(ctx context.Context, q dbtx, query string, args ...any) (*PromoCode, error) {
+	promoCode, err := scanPromoCode(q.QueryRowContext(ctx, query, args...))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return promoCode, nil
+}
+
+func scanPromoCode(row rowScanner) (*PromoCode, error) {
+	var promoCode PromoCode
+	var currency, ticketTypeIds sql.NullString
+	var expiresAt sql.NullTime
+
+	err := row.Scan(&promoCode.ID, &promoCode.EventId, &promoCode.Code, &promoCode.DiscountType, &promoCode.DiscountValue, &currency, &promoCode.MaxUses, &promoCode.TimesRedeemed, &expiresAt, &ticketTypeIds)
+	if err != nil {
+		return nil, err
+	}
+
+	promoCode.Currency = currency.String
+	if expiresAt.Valid {
+		promoCode.ExpiresAt = &expiresAt.Time
+	}
+	if ticketTypeIds.Valid {
+		if err := json.Unmarshal([]byte(ticketTypeIds.String), &promoCode.TicketTypeIds); err != nil {
+			return nil, err
+		}
+	}
+
+	return &promoCode, nil
+}
@@ -0,0 +1,99 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"rest-api-event-app/internal/testdb"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestRedeemPromoCodeConcurrent races purchases for a code that can be used
+// once. Only one of them may redeem it; once its payment fails the use is
+// given back and the code can be redeemed again.
+func TestRedeemPromoCodeConcurrent(t *testing.T) {
+	db := testdb.Open(t)
+	db.SetMaxOpenConns(50)
+
+	const purchases = 20
+
+	owner := createTestUser(t, db)
+	event := createTestEvent(t, db, owner.ID, purchases+1)
+	ticketType := createTestTicketType(t, db, event.ID, 2500)
+
+	promoCodes := PromoCodeModel{DB: db}
+	promoCode, err := promoCodes.Insert(context.Background(), &PromoCode{EventId: event.ID, Code: "ONCE", DiscountType: DiscountPercent, DiscountValue: 50, MaxUses: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	users := make([]*User, purchases)
+	for i := range users {
+		users[i] = createTestUser(t, db)
+	}
+
+	payments := PaymentModel{DB: db}
+	now := time.Now().UTC()
+
+	var wg sync.WaitGroup
+	results := make([]*Payment, purchases)
+	errs := make([]error, purchases)
+	start := make(chan struct{})
+	for i, user := range users {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			_, results[i], errs[i] = payments.Reserve(context.Background(), Purchase{EventId: event.ID, UserId: user.ID, TicketTypeId: ticketType.ID, PromoCode: "once", HoldFor: time.Minute}, now)
+		}()
+	}
+
+	close(start)
+	wg.Wait()
+
+	var redeemed *Payment
+	for i, err := range errs {
+		switch {
+		case err == nil:
+			if redeemed != nil {
+				t.Fatalf("purchases %d and %d both redeemed the code", redeemed.ID, results[i].ID)
+			}
+			redeemed = results[i]
+		case !errors.Is(err, ErrPromoCodeExhausted):
+			t.Fatalf("purchase %d failed: %s", i, err)
+		}
+	}
+
+	if redeemed == nil {
+		t.Fatal("no purchase redeemed the code")
+	}
+	if redeemed.Amount != 1250 {
+		t.Errorf("discounted amount = %d, want 1250", redeemed.Amount)
+	}
+
+	stored, err := promoCodes.Get(context.Background(), event.ID, promoCode.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.TimesRedeemed != 1 {
+		t.Errorf("times redeemed = %d, want 1", stored.TimesRedeemed)
+	}
+
+	if _, err := payments.Fail(context.Background(), redeemed.ID, "card declined"); err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err = promoCodes.Get(context.Background(), event.ID, promoCode.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.TimesRedeemed != 0 {
+		t.Errorf("times redeemed after the payment failed = %d, want 0", stored.TimesRedeemed)
+	}
+
+	_, _, err = payments.Reserve(context.Background(), Purchase{EventId: event.ID, UserId: createTestUser(t, db).ID, TicketTypeId: ticketType.ID, PromoCode: "ONCE", HoldFor: time.Minute}, now)
+	if err != nil {
+		t.Errorf("redeeming the code after its use was given back: %s", err)
+	}
+}
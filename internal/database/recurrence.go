@@ -0,0 +1,198 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/teambition/rrule-go"
+)
+
+// MaxOccurrences bounds how many occurrences a single expansion returns, and
+// how many a bounded series may have at all.
+const MaxOccurrences = 1000
+
+var ErrInvalidOccurrence = errors.New("time is not an occurrence of this event")
+
+// Occurrence is a single instance of an event. Non-recurring events have
+// exactly one occurrence.
+type Occurrence struct {
+	StartsAt time.Time `json:"starts_at"`
+	EndsAt   time.Time `json:"ends_at"`
+}
+
+// IsRecurring reports whether the event has a recurrence rule.
+func (e *Event) IsRecurring() bool {
+	return e.RecurrenceRule != ""
+}
+
+// rule builds the RFC 5545 recurrence rule of the event. DTSTART is the
+// event's start in its own time zone, so BYDAY and friends follow local time
+// across daylight saving changes.
+func (e *Event) rule() (*rrule.RRule, error) {
+	loc, err := time.LoadLocation(e.TimeZone)
+	if err != nil {
+		loc = time.UTC
+	}
+
+	text := strings.TrimPrefix(strings.TrimSpace(e.RecurrenceRule), "RRULE:")
+	if strings.ContainsAny(text, "\r\n") {
+		return nil, errors.New("recurrence rule must be a single RRULE value")
+	}
+
+	option, err := rrule.StrToROptionInLocation(text, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	option.Dtstart = e.StartsAt.In(loc)
+
+	return rrule.NewRRule(*option)
+}
+
+// ValidateRecurrence checks that the recurrence rule parses, repeats at most
+// daily, that a bounded series is not unreasonably long, and that every
+// exception date is an occurrence of the series.
+func (e *Event) ValidateRecurrence() error {
+	if !e.IsRecurring() {
+		if len(e.ExceptionDates) > 0 {
+			return errors.New("exception dates require a recurrence rule")
+		}
+		return nil
+	}
+
+	r, err := e.rule()
+	if err != nil {
+		return fmt.Errorf("invalid recurrence rule: %w", err)
+	}
+
+	// Expanding walks the series from its start, so sub-daily frequencies
+	// would make listing an unbounded series years later far too slow.
+	if r.OrigOptions.Freq > rrule.DAILY {
+		return errors.New("recurrence rule must repeat daily or less often")
+	}
+
+	if e.isBounded(r) && len(firstOccurrences(r, MaxOccurrences+1)) > MaxOccurrences {
+		return fmt.Errorf("recurrence rule produces more than %d occurrences", MaxOccurrences)
+	}
+
+	for _, exdate := range e.ExceptionDates {
+		if !isOccurrenceOf(r, exdate) {
+			return fmt.Errorf("exception date %s is not an occurrence of the event", exdate.Format(time.RFC3339))
+		}
+	}
+
+	return nil
+}
+
+// Expand lazily generates the occurrences of the event that overlap the range
+// [from, to), skipping exception dates. At most MaxOccurrences are returned.
+func (e *Event) Expand(from, to time.Time) ([]Occurrence, error) {
+	duration := e.EndsAt.Sub(e.StartsAt)
+
+	if !e.IsRecurring() {
+		if e.StartsAt.Before(to) && e.EndsAt.After(from) {
+			return []Occurrence{{StartsAt: e.StartsAt, EndsAt: e.EndsAt}}, nil
+		}
+		return []Occurrence{}, nil
+	}
+
+	r, err := e.rule()
+	if err != nil {
+		return nil, err
+	}
+
+	occurrences := []Occurrence{}
+	next := r.Iterator()
+	for start, ok := next(); ok && start.Before(to); start, ok = next() {
+		if !start.Add(duration).After(from) || e.isException(start) {
+			continue
+		}
+
+		occurrences = append(occurrences, Occurrence{StartsAt: start, EndsAt: start.Add(duration)})
+		if len(occurrences) == MaxOccurrences {
+			break
+		}
+	}
+
+	return occurrences, nil
+}
+
+// IsOccurrence reports whether t is the start of an instance of the event
+// that has not been cancelled through an exception date.
+func (e *Event) IsOccurrence(t time.Time) bool {
+	if !e.IsRecurring() {
+		return t.Equal(e.StartsAt)
+	}
+
+	r, err := e.rule()
+	if err != nil {
+		return false
+	}
+
+	return isOccurrenceOf(r, t) && !e.isException(t)
+}
+
+// OccurrenceAt returns the occurrence of the event that is under way at t,
+// or starts within lead after it, or nil when there is none. Of overlapping
+// occurrences the earliest wins.
+func (e *Event) OccurrenceAt(t time.Time, lead time.Duration) (*Occurrence, error) {
+	occurrences, err := e.Expand(t, t.Add(lead+time.Nanosecond))
+	if err != nil || len(occurrences) == 0 {
+		return nil, err
+	}
+
+	return &occurrences[0], nil
+}
+
+// SeriesEndsAt returns when the last occurrence of the event ends, or nil if
+// the series repeats forever.
+func (e *Event) SeriesEndsAt() *time.Time {
+	if !e.IsRecurring() {
+		end := e.EndsAt
+		return &end
+	}
+
+	r, err := e.rule()
+	if err != nil || !e.isBounded(r) {
+		return nil
+	}
+
+	starts := firstOccurrences(r, MaxOccurrences)
+	if len(starts) == 0 {
+		end := e.EndsAt
+		return &end
+	}
+
+	end := starts[len(starts)-1].Add(e.EndsAt.Sub(e.StartsAt))
+	return &end
+}
+
+func (e *Event) isBounded(r *rrule.RRule) bool {
+	return r.OrigOptions.Count > 0 || !r.OrigOptions.Until.IsZero()
+}
+
+func (e *Event) isException(t time.Time) bool {
+	for _, exdate := range e.ExceptionDates {
+		if exdate.Equal(t) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isOccurrenceOf(r *rrule.RRule, t time.Time) bool {
+	return r.After(t.Add(-time.Second), false).Equal(t)
+}
+
+func firstOccurrences(r *rrule.RRule, n int) []time.Time {
+	var starts []time.Time
+	next := r.Iterator()
+	for start, ok := next(); ok && len(starts) < n; start, ok = next() {
+		starts = append(starts, start)
+	}
+
+	return starts
+}
@@ -0,0 +1,277 @@
+package database
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func mustLoad(t *testing.T, name string) *time.Location {
+	t.Helper()
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return loc
+}
+
+func TestEventExpand(t *testing.T) {
+	berlin := mustLoad(t, "Europe/Berlin")
+	newYork := mustLoad(t, "America/New_York")
+
+	utc := func(s string) time.Time {
+		v, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+
+	tests := []struct {
+		name  string
+		event Event
+		from  string
+		to    string
+		want  []string
+	}{
+		{
+			name: "single event inside the range",
+			event: Event{
+				StartsAt: utc("2025-05-01T18:00:00Z"), EndsAt: utc("2025-05-01T20:00:00Z"), TimeZone: "UTC",
+			},
+			from: "2025-05-01T00:00:00Z", to: "2025-05-02T00:00:00Z",
+			want: []string{"2025-05-01T18:00:00Z"},
+		},
+		{
+			name: "single event outside the range",
+			event: Event{
+				StartsAt: utc("2025-05-01T18:00:00Z"), EndsAt: utc("2025-05-01T20:00:00Z"), TimeZone: "UTC",
+			},
+			from: "2025-05-02T00:00:00Z", to: "2025-05-03T00:00:00Z",
+			want: []string{},
+		},
+		{
+			name: "weekly series keeps local time across daylight saving",
+			event: Event{
+				StartsAt: time.Date(2025, 3, 17, 10, 0, 0, 0, berlin), EndsAt: time.Date(2025, 3, 17, 11, 0, 0, 0, berlin),
+				TimeZone: "Europe/Berlin", RecurrenceRule: "FREQ=WEEKLY;BYDAY=MO;COUNT=4",
+			},
+			from: "2025-01-01T00:00:00Z", to: "2026-01-01T00:00:00Z",
+			// Berlin moves from UTC+1 to UTC+2 on 30 March 2025.
+			want: []string{"2025-03-17T09:00:00Z", "2025-03-24T09:00:00Z", "2025-03-31T08:00:00Z", "2025-04-07T08:00:00Z"},
+		},
+		{
+			name: "exception dates are skipped",
+			event: Event{
+				StartsAt: time.Date(2025, 11, 3, 19, 0, 0, 0, newYork), EndsAt: time.Date(2025, 11, 3, 21, 0, 0, 0, newYork),
+				TimeZone: "America/New_York", RecurrenceRule: "RRULE:FREQ=DAILY;COUNT=4",
+				ExceptionDates: []time.Time{time.Date(2025, 11, 4, 19, 0, 0, 0, newYork)},
+			},
+			from: "2025-11-01T00:00:00Z", to: "2025-12-01T00:00:00Z",
+			want: []string{"2025-11-04T00:00:00Z", "2025-11-06T00:00:00Z", "2025-11-07T00:00:00Z"},
+		},
+		{
+			name: "occurrences overlapping the start of the range are included",
+			event: Event{
+				StartsAt: utc("2025-06-01T22:00:00Z"), EndsAt: utc("2025-06-02T02:00:00Z"),
+				TimeZone: "UTC", RecurrenceRule: "FREQ=DAILY",
+			},
+			from: "2025-06-03T01:00:00Z", to: "2025-06-04T00:00:00Z",
+			want: []string{"2025-06-02T22:00:00Z", "2025-06-03T22:00:00Z"},
+		},
+		{
+			name: "until bounds the series",
+			event: Event{
+				StartsAt: utc("2025-01-06T12:00:00Z"), EndsAt: utc("2025-01-06T13:00:00Z"),
+				TimeZone: "UTC", RecurrenceRule: "FREQ=MONTHLY;UNTIL=20250401T000000Z",
+			},
+			from: "2025-01-01T00:00:00Z", to: "2026-01-01T00:00:00Z",
+			want: []string{"2025-01-06T12:00:00Z", "2025-02-06T12:00:00Z", "2025-03-06T12:00:00Z"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			occurrences, err := tt.event.Expand(utc(tt.from), utc(tt.to))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := make([]string, len(occurrences))
+			for i, occurrence := range occurrences {
+				got[i] = occurrence.StartsAt.UTC().Format(time.RFC3339)
+
+				if d := occurrence.EndsAt.Sub(occurrence.StartsAt); d != tt.event.EndsAt.Sub(tt.event.StartsAt) {
+					t.Errorf("occurrence %s lasts %s", got[i], d)
+				}
+			}
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("got %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestEventExpandIsBounded(t *testing.T) {
+	start := time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+	event := Event{StartsAt: start, EndsAt: start.Add(time.Hour), TimeZone: "UTC", RecurrenceRule: "FREQ=DAILY"}
+
+	occurrences, err := event.Expand(start, start.AddDate(10, 0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(occurrences) != MaxOccurrences {
+		t.Errorf("got %d occurrences, want %d", len(occurrences), MaxOccurrences)
+	}
+}
+
+func TestEventIsOccurrence(t *testing.T) {
+	berlin := mustLoad(t, "Europe/Berlin")
+
+	event := Event{
+		StartsAt: time.Date(2025, 3, 17, 10, 0, 0, 0, berlin), EndsAt: time.Date(2025, 3, 17, 11, 0, 0, 0, berlin),
+		TimeZone: "Europe/Berlin", RecurrenceRule: "FREQ=WEEKLY;COUNT=4",
+		ExceptionDates: []time.Time{time.Date(2025, 3, 24, 10, 0, 0, 0, berlin)},
+	}
+
+	tests := []struct {
+		name string
+		t    time.Time
+		want bool
+	}{
+		{"first occurrence", time.Date(2025, 3, 17, 9, 0, 0, 0, time.UTC), true},
+		{"occurrence after daylight saving starts", time.Date(2025, 3, 31, 8, 0, 0, 0, time.UTC), true},
+		{"same local time with the old offset", time.Date(2025, 3, 31, 9, 0, 0, 0, time.UTC), false},
+		{"exception date", time.Date(2025, 3, 24, 9, 0, 0, 0, time.UTC), false},
+		{"after the last occurrence", time.Date(2025, 4, 14, 8, 0, 0, 0, time.UTC), false},
+		{"between occurrences", time.Date(2025, 3, 20, 9, 0, 0, 0, time.UTC), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := event.IsOccurrence(tt.t); got != tt.want {
+				t.Errorf("IsOccurrence(%s) = %v, want %v", tt.t, got, tt.want)
+			}
+		})
+	}
+
+	single := Event{StartsAt: event.StartsAt, EndsAt: event.EndsAt, TimeZone: "Europe/Berlin"}
+	if !single.IsOccurrence(event.StartsAt.UTC()) {
+		t.Error("the start of a single event is its occurrence")
+	}
+	if single.IsOccurrence(event.StartsAt.AddDate(0, 0, 7)) {
+		t.Error("a single event has one occurrence")
+	}
+}
+
+func TestEventValidateRecurrence(t *testing.T) {
+	start := time.Date(2025, 1, 6, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		rule    string
+		exdates []time.Time
+		wantErr bool
+	}{
+		{name: "no rule", rule: ""},
+		{name: "exception dates without a rule", rule: "", exdates: []time.Time{start}, wantErr: true},
+		{name: "weekly", rule: "FREQ=WEEKLY;BYDAY=MO,WE"},
+		{name: "with prefix", rule: "RRULE:FREQ=DAILY;COUNT=5"},
+		{name: "unparsable", rule: "FREQ=SOMETIMES", wantErr: true},
+		{name: "more than one line", rule: "FREQ=DAILY\r\nEXDATE:20250107T090000Z", wantErr: true},
+		{name: "hourly", rule: "FREQ=HOURLY", wantErr: true},
+		{name: "too many occurrences", rule: "FREQ=DAILY;COUNT=1001", wantErr: true},
+		{name: "as many occurrences as allowed", rule: "FREQ=DAILY;COUNT=1000"},
+		{name: "exception date on the series", rule: "FREQ=DAILY", exdates: []time.Time{start.AddDate(0, 0, 3)}},
+		{name: "exception date off the series", rule: "FREQ=DAILY", exdates: []time.Time{start.Add(time.Hour)}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event := Event{StartsAt: start, EndsAt: start.Add(time.Hour), TimeZone: "UTC", RecurrenceRule: tt.rule, ExceptionDates: tt.exdates}
+
+			err := event.ValidateRecurrence()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateRecurrence() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEventSeriesEndsAt(t *testing.T) {
+	start := time.Date(2025, 1, 6, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		rule string
+		want *time.Time
+	}{
+		{name: "single event", rule: "", want: ptr(start.Add(time.Hour))},
+		{name: "counted series", rule: "FREQ=WEEKLY;COUNT=3", want: ptr(start.AddDate(0, 0, 14).Add(time.Hour))},
+		{name: "series with an end date", rule: "FREQ=DAILY;UNTIL=20250110T090000Z", want: ptr(start.AddDate(0, 0, 4).Add(time.Hour))},
+		{name: "endless series", rule: "FREQ=MONTHLY", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event := Event{StartsAt: start, EndsAt: start.Add(time.Hour), TimeZone: "UTC", RecurrenceRule: tt.rule}
+
+			got := event.SeriesEndsAt()
+			switch {
+			case got == nil && tt.want == nil:
+			case got == nil || tt.want == nil || !got.Equal(*tt.want):
+				t.Errorf("SeriesEndsAt() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestEventOccurrenceAt(t *testing.T) {
+	start := time.Date(2025, 1, 6, 18, 0, 0, 0, time.UTC)
+	series := Event{StartsAt: start, EndsAt: start.Add(2 * time.Hour), TimeZone: "UTC", RecurrenceRule: "FREQ=WEEKLY;COUNT=3"}
+	single := Event{StartsAt: start, EndsAt: start.Add(2 * time.Hour), TimeZone: "UTC"}
+
+	tests := []struct {
+		name  string
+		event Event
+		t     time.Time
+		want  *time.Time
+	}{
+		{"before the lead", series, start.Add(-2 * time.Hour), nil},
+		{"within the lead", series, start.Add(-30 * time.Minute), ptr(start)},
+		{"under way", series, start.Add(90 * time.Minute), ptr(start)},
+		{"at the end", series, start.Add(2 * time.Hour), nil},
+		{"a later occurrence", series, start.AddDate(0, 0, 14).Add(time.Hour), ptr(start.AddDate(0, 0, 14))},
+		{"after the series", series, start.AddDate(0, 0, 21), nil},
+		{"single event under way", single, start.Add(time.Hour), ptr(start)},
+		{"single event a week later", single, start.AddDate(0, 0, 7), nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.event.OccurrenceAt(tt.t, time.Hour)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			switch {
+			case got == nil && tt.want == nil:
+			case got == nil || tt.want == nil || !got.StartsAt.Equal(*tt.want):
+				t.Errorf("OccurrenceAt(%s) = %v, want %v", tt.t, got, tt.want)
+			}
+		})
+	}
+}
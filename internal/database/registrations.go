@@ -0,0 +1,398 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+var (
+	ErrEventNotFound       = errors.New("event not found")
+	ErrAttendeeNotFound    = errors.New("attendee not found")
+	ErrAlreadyRegistered   = errors.New("user is already registered for this event")
+	ErrEventFull           = errors.New("event is full")
+	mysqlErrDuplicateEntry = uint16(1062)
+)
+
+// Register adds a user to an event inside a transaction. The event row is
+// locked for the duration of the transaction so concurrent registrations for
+// the same event are serialized and can never exceed its capacity; once the
+// event is full the user is placed on the waitlist instead. A second
+// registration for the same user fails with ErrAlreadyRegistered, while a
+// user who previously declined is registered again.
+//
+// A nil occurrence registers the user for every occurrence of the event;
+// otherwise only for the occurrence starting at that time, which the caller
+// must have checked belongs to the event. Capacity applies to each occurrence.
+func (m *AtendeeModel) Register(ctx context.Context, eventId, userId int, occurrence *time.Time) (*Attendee, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var attendee *Attendee
+	err := withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		capacity, err := lockEvent(ctx, tx, eventId)
+		if err != nil {
+			return err
+		}
+
+		attendee, err = register(ctx, tx, capacity, eventId, userId, occurrence)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return attendee, nil
+}
+
+// Registration is one row of a bulk attendee import.
+type Registration struct {
+	UserId     int        `json:"user_id"`
+	Occurrence *time.Time `json:"occurrence,omitempty"`
+}
+
+// ImportRegistrations registers every user of import id for an event in a
+// single transaction, exactly as Register would one after another, so either
+// all of them are added or none. Once every registration went through, the
+// import is finished with the report made by report from the new
+// registrations. A dry run rolls the transaction back after every
+// registration succeeded. A failed registration is returned as a *RowError,
+// and an import that already finished fails with ErrImportFinished.
+func (m *AtendeeModel) ImportRegistrations(ctx context.Context, id, eventId int, registrations []Registration, dryRun bool, report func(attendees []*Attendee) ([]byte, error)) error {
+	ctx, cancel := context.WithTimeout(ctx, ImportTimeoutDuration)
+	defer cancel()
+
+	attendees := make([]*Attendee, 0, len(registrations))
+	return runImport(ctx, m.DB, id, dryRun, func(tx *sql.Tx) error {
+		capacity, err := lockEvent(ctx, tx, eventId)
+		if err != nil {
+			return err
+		}
+
+		for i, registration := range registrations {
+			attendee, err := register(ctx, tx, capacity, eventId, registration.UserId, registration.Occurrence)
+			if err != nil {
+				return &RowError{Index: i, Err: err}
+			}
+
+			attendees = append(attendees, attendee)
+		}
+
+		return nil
+	}, func() ([]byte, error) {
+		return report(attendees)
+	})
+}
+
+// register adds a user to an event, or one of its occurrences, unless they
+// are already registered. The event row must already be locked by the
+// caller's transaction.
+func register(ctx context.Context, tx *sql.Tx, capacity, eventId, userId int, occurrence *time.Time) (*Attendee, error) {
+	existing, err := checkNotRegistered(ctx, tx, eventId, userId, occurrence)
+	if err != nil {
+		return nil, err
+	}
+
+	attendee, err := respond(ctx, tx, capacity, eventId, userId, occurrence, existing, RSVPGoing)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := writeOutbox(ctx, tx, TopicAttendeeAdded, eventId, AttendeeChange{Attendee: attendee, ByOrganizer: true}); err != nil {
+		return nil, err
+	}
+
+	return attendee, nil
+}
+
+// checkNotRegistered fails with ErrAlreadyRegistered when the user already
+// holds a registration for the occurrence, or for the whole series. It
+// returns the registration the user declined, if any, so it can be reused.
+func checkNotRegistered(ctx context.Context, tx *sql.Tx, eventId, userId int, occurrence *time.Time) (*Attendee, error) {
+	existing, err := getRegistration(ctx, tx, eventId, userId, occurrence)
+	if err != nil {
+		return nil, err
+	}
+
+	if existing != nil && existing.Status != AttendeeStatusDeclined {
+		return nil, ErrAlreadyRegistered
+	}
+
+	if occurrence != nil {
+		series, err := getRegistration(ctx, tx, eventId, userId, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		if series != nil && series.Status != AttendeeStatusDeclined {
+			return nil, ErrAlreadyRegistered
+		}
+	}
+
+	return existing, nil
+}
+
+// Unregister removes a user's registration for an occurrence of an event, or
+// for the whole series when occurrence is nil, and, if they held a confirmed
+// seat, promotes waitlisted users in the same transaction.
+func (m *AtendeeModel) Unregister(ctx context.Context, eventId, userId int, occurrence *time.Time) (*Attendee, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var attendee *Attendee
+	err := withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		capacity, err := lockEvent(ctx, tx, eventId)
+		if err != nil {
+			return err
+		}
+
+		attendee, err = getRegistration(ctx, tx, eventId, userId, occurrence)
+		if err != nil {
+			return err
+		}
+
+		if attendee == nil {
+			return ErrAttendeeNotFound
+		}
+
+		if _, err := tx.ExecContext(ctx, "DELETE FROM attendees WHERE id = ?", attendee.ID); err != nil {
+			return err
+		}
+
+		if err := writeOutbox(ctx, tx, TopicAttendeeRemoved, eventId, AttendeeChange{Attendee: attendee}); err != nil {
+			return err
+		}
+
+		if !holdsSeat(attendee.Status) {
+			return nil
+		}
+
+		_, err = promoteFromWaitlist(ctx, tx, eventId, capacity)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return attendee, nil
+}
+
+// Respond records a user's own RSVP for an event. Answering going or maybe
+// claims a seat, or a waitlist spot when the event is full, unless the user
+// already holds one; answering declined gives the seat up and promotes the
+// next waitlisted user. A nil occurrence answers for the whole series.
+func (m *AtendeeModel) Respond(ctx context.Context, eventId, userId int, occurrence *time.Time, rsvp string) (*Attendee, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var attendee *Attendee
+	err := withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		capacity, err := lockEvent(ctx, tx, eventId)
+		if err != nil {
+			return err
+		}
+
+		existing, err := getRegistration(ctx, tx, eventId, userId, occurrence)
+		if err != nil {
+			return err
+		}
+
+		attendee, err = respond(ctx, tx, capacity, eventId, userId, occurrence, existing, rsvp)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return attendee, nil
+}
+
+// respond applies an RSVP to the existing registration, if any, of a user.
+// The event row must already be locked by the caller's transaction.
+func respond(ctx context.Context, tx *sql.Tx, capacity, eventId, userId int, occurrence *time.Time, existing *Attendee, rsvp string) (*Attendee, error) {
+	attendee := existing
+	if attendee == nil {
+		attendee = &Attendee{EventId: eventId, UserId: userId, Occurrence: occurrence, Status: AttendeeStatusDeclined}
+	}
+
+	previousStatus := attendee.Status
+	attendee.RSVPStatus = rsvp
+
+	switch {
+	case rsvp == RSVPDeclined:
+		attendee.Status = AttendeeStatusDeclined
+	case previousStatus == AttendeeStatusDeclined:
+		attendee.Status = AttendeeStatusConfirmed
+		if capacity > 0 {
+			taken, err := seatsTaken(ctx, tx, eventId, occurrence)
+			if err != nil {
+				return nil, err
+			}
+
+			if taken >= capacity {
+				attendee.Status = AttendeeStatusWaitlisted
+			}
+		}
+	}
+
+	if attendee.ID == 0 {
+		if err := insertAttendee(ctx, tx, attendee); err != nil {
+			if isDuplicateEntry(err) {
+				return nil, ErrAlreadyRegistered
+			}
+			return nil, err
+		}
+	} else {
+		// Rejoining after declining moves the user to the back of the waitlist.
+		query := "UPDATE attendees SET status = ?, rsvp_status = ? WHERE id = ?"
+		if previousStatus == AttendeeStatusDeclined && attendee.Status != AttendeeStatusDeclined {
+			query = "UPDATE attendees SET status = ?, rsvp_status = ?, created_at = CURRENT_TIMESTAMP WHERE id = ?"
+		}
+
+		if _, err := tx.ExecContext(ctx, query, attendee.Status, attendee.RSVPStatus, attendee.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	if holdsSeat(previousStatus) && !holdsSeat(attendee.Status) {
+		if _, err := promoteFromWaitlist(ctx, tx, eventId, capacity); err != nil {
+			return nil, err
+		}
+	}
+
+	return attendee, nil
+}
+
+// PromoteFromWaitlist moves waitlisted attendees of an event to confirmed, in
+// waitlist order, until each occurrence is full. Events without a capacity
+// promote their whole waitlist.
+func (m *AtendeeModel) PromoteFromWaitlist(ctx context.Context, eventId int) ([]*Attendee, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var promoted []*Attendee
+	err := withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		capacity, err := lockEvent(ctx, tx, eventId)
+		if err != nil {
+			return err
+		}
+
+		promoted, err = promoteFromWaitlist(ctx, tx, eventId, capacity)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return promoted, nil
+}
+
+func lockEvent(ctx context.Context, tx *sql.Tx, eventId int) (int, error) {
+	var capacity int
+	err := tx.QueryRowContext(ctx, "SELECT capacity FROM events WHERE id = ? FOR UPDATE", eventId).Scan(&capacity)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, ErrEventNotFound
+		}
+		return 0, err
+	}
+
+	return capacity, nil
+}
+
+// holdsSeat reports whether a registration with the status counts against
+// the capacity of its event.
+func holdsSeat(status string) bool {
+	return status == AttendeeStatusConfirmed || status == AttendeeStatusPendingPayment
+}
+
+// seatsTaken counts the seats of an occurrence of an event that are confirmed
+// or held for a pending payment, which are shared with attendees of the
+// whole series. For the whole series it is
+// the series attendees plus those of its busiest occurrence. Events that do
+// not recur only have series attendees.
+func seatsTaken(ctx context.Context, q dbtx, eventId int, occurrence *time.Time) (int, error) {
+	query := `
+		SELECT COUNT(*) FROM attendees
+		WHERE event_id = ? AND status IN (?, ?) AND (occurrence_start IS NULL OR occurrence_start = ?)
+	`
+	args := []any{eventId, AttendeeStatusConfirmed, AttendeeStatusPendingPayment, occurrence}
+	if occurrence == nil {
+		query = `
+			SELECT
+				(SELECT COUNT(*) FROM attendees WHERE event_id = ? AND status IN (?, ?) AND occurrence_start IS NULL) +
+				(SELECT COALESCE(MAX(taken), 0) FROM (
+					SELECT COUNT(*) AS taken FROM attendees
+					WHERE event_id = ? AND status IN (?, ?) AND occurrence_start IS NOT NULL
+					GROUP BY occurrence_start
+				) AS occurrences)
+		`
+		args = []any{eventId, AttendeeStatusConfirmed, AttendeeStatusPendingPayment, eventId, AttendeeStatusConfirmed, AttendeeStatusPendingPayment}
+	}
+
+	var taken int
+	if err := q.QueryRowContext(ctx, query, args...).Scan(&taken); err != nil {
+		return 0, err
+	}
+
+	return taken, nil
+}
+
+// promoteFromWaitlist confirms waitlisted attendees in waitlist order as long
+// as the occurrence, or series, they are waiting for has a free seat.
+func promoteFromWaitlist(ctx context.Context, tx *sql.Tx, eventId, capacity int) ([]*Attendee, error) {
+	query := "SELECT " + attendeeColumns + " FROM attendees WHERE event_id = ? AND status = ? ORDER BY created_at, id"
+
+	rows, err := tx.QueryContext(ctx, query, eventId, AttendeeStatusWaitlisted)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var waitlisted []*Attendee
+	for rows.Next() {
+		attendee, err := scanAttendee(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		waitlisted = append(waitlisted, attendee)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var promoted []*Attendee
+	for _, attendee := range waitlisted {
+		if capacity > 0 {
+			taken, err := seatsTaken(ctx, tx, eventId, attendee.Occurrence)
+			if err != nil {
+				return nil, err
+			}
+
+			if taken >= capacity {
+				continue
+			}
+		}
+
+		_, err := tx.ExecContext(ctx, "UPDATE attendees SET status = ? WHERE id = ?", AttendeeStatusConfirmed, attendee.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		attendee.Status = AttendeeStatusConfirmed
+		promoted = append(promoted, attendee)
+	}
+
+	return promoted, nil
+}
+
+func isDuplicateEntry(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlErrDuplicateEntry
+}
@@ -0,0 +1,118 @@
+package database
+
+import (
+	"context"
+	"rest-api-event-app/internal/testdb"
+	"sync"
+	"testing"
+)
+
+// TestRegisterConcurrent fires registrations for one event from many
+// goroutines at once. The row lock taken by Register must serialize them,
+// so exactly capacity users get a seat and everybody else is waitlisted.
+func TestRegisterConcurrent(t *testing.T) {
+	db := testdb.Open(t)
+	db.SetMaxOpenConns(50)
+
+	const (
+		registrations = 40
+		capacity      = 7
+	)
+
+	owner := createTestUser(t, db)
+	event := createTestEvent(t, db, owner.ID, capacity)
+
+	users := make([]*User, registrations)
+	for i := range users {
+		users[i] = createTestUser(t, db)
+	}
+
+	attendees := AtendeeModel{DB: db}
+
+	var wg sync.WaitGroup
+	results := make([]*Attendee, registrations)
+	errs := make([]error, registrations)
+	start := make(chan struct{})
+	for i, user := range users {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			results[i], errs[i] = attendees.Register(context.Background(), event.ID, user.ID, nil)
+		}()
+	}
+
+	close(start)
+	wg.Wait()
+
+	counts := map[string]int{}
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("registration %d failed: %s", i, err)
+		}
+		counts[results[i].Status]++
+	}
+
+	if counts[AttendeeStatusConfirmed] != capacity {
+		t.Errorf("confirmed = %d, want %d", counts[AttendeeStatusConfirmed], capacity)
+	}
+	if want := registrations - capacity; counts[AttendeeStatusWaitlisted] != want {
+		t.Errorf("waitlisted = %d, want %d", counts[AttendeeStatusWaitlisted], want)
+	}
+
+	confirmed, err := attendees.GetAttendeesByEvent(context.Background(), event.ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(confirmed) != capacity {
+		t.Errorf("stored confirmed attendees = %d, want %d", len(confirmed), capacity)
+	}
+
+	waitlist, err := attendees.GetWaitlistByEvent(context.Background(), event.ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := registrations - capacity; len(waitlist) != want {
+		t.Errorf("stored waitlist = %d, want %d", len(waitlist), want)
+	}
+}
+
+// TestRegisterConcurrentSameUser registers one user many times at once;
+// only one registration may succeed.
+func TestRegisterConcurrentSameUser(t *testing.T) {
+	db := testdb.Open(t)
+
+	owner := createTestUser(t, db)
+	event := createTestEvent(t, db, owner.ID, 10)
+	user := createTestUser(t, db)
+
+	attendees := AtendeeModel{DB: db}
+
+	const attempts = 10
+
+	var wg sync.WaitGroup
+	errs := make([]error, attempts)
+	for i := range attempts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = attendees.Register(context.Background(), event.ID, user.ID, nil)
+		}()
+	}
+	wg.Wait()
+
+	succeeded := 0
+	for _, err := range errs {
+		switch err {
+		case nil:
+			succeeded++
+		case ErrAlreadyRegistered:
+		default:
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	if succeeded != 1 {
+		t.Errorf("succeeded registrations = %d, want 1", succeeded)
+	}
+}
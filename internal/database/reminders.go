@@ -0,0 +1,113 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type ReminderModel struct {
+	DB *sql.DB
+}
+
+// Reminder identifies one reminder of an occurrence of an event: the one
+// sent Offset before it starts. Non-recurring events have a single
+// occurrence starting at the event's start.
+type Reminder struct {
+	EventId    int
+	Occurrence time.Time
+	Offset     time.Duration
+}
+
+// OptOut stops reminders of an event for a user.
+func (m *ReminderModel) OptOut(ctx context.Context, userId, eventId int) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, "INSERT IGNORE INTO reminder_opt_outs (user_id, event_id) VALUES (?, ?)", userId, eventId)
+	return err
+}
+
+// OptIn sends reminders of an event to a user again.
+func (m *ReminderModel) OptIn(ctx context.Context, userId, eventId int) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, "DELETE FROM reminder_opt_outs WHERE user_id = ? AND event_id = ?", userId, eventId)
+	return err
+}
+
+// IsOptedOut reports whether a user opted out of the reminders of an event.
+func (m *ReminderModel) IsOptedOut(ctx context.Context, userId, eventId int) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var exists bool
+	err := m.DB.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM reminder_opt_outs WHERE user_id = ? AND event_id = ?)", userId, eventId).Scan(&exists)
+	if err != nil {
+		return false, err
+	}
+
+	return exists, nil
+}
+
+// GetOptedOut returns the users who opted out of the reminders of an event.
+func (m *ReminderModel) GetOptedOut(ctx context.Context, eventId int) (map[int]bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, "SELECT user_id FROM reminder_opt_outs WHERE event_id = ?", eventId)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	optedOut := map[int]bool{}
+	for rows.Next() {
+		var userId int
+		if err := rows.Scan(&userId); err != nil {
+			return nil, err
+		}
+
+		optedOut[userId] = true
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return optedOut, nil
+}
+
+// Claim records that a reminder is about to be sent to a user through a
+// channel. It returns false when it was claimed before, so every reminder
+// goes out at most once per user and channel, even across restarts.
+func (m *ReminderModel) Claim(ctx context.Context, reminder Reminder, userId int, channel string, now time.Time) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := "INSERT INTO reminder_deliveries (event_id, occurrence_start, offset_minutes, user_id, channel, sent_at) VALUES (?, ?, ?, ?, ?, ?)"
+
+	_, err := m.DB.ExecContext(ctx, query, reminder.EventId, reminder.Occurrence, int(reminder.Offset/time.Minute), userId, channel, now)
+	if err != nil {
+		if isDuplicateEntry(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Unclaim gives a claim back after the reminder could not be sent, so it is
+// sent when tried again.
+func (m *ReminderModel) Unclaim(ctx context.Context, reminder Reminder, userId int, channel string) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := "DELETE FROM reminder_deliveries WHERE event_id = ? AND occurrence_start = ? AND offset_minutes = ? AND user_id = ? AND channel = ?"
+
+	_, err := m.DB.ExecContext(ctx, query, reminder.EventId, reminder.Occurrence, int(reminder.Offset/time.Minute), userId, channel)
+	return err
+}
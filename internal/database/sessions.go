@@ -0,0 +1,180 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var (
+	ErrRefreshTokenInvalid = errors.New("refresh token is invalid or expired")
+	ErrRefreshTokenReused  = errors.New("refresh token was already used")
+)
+
+type RefreshTokenModel struct {
+	DB *sql.DB
+}
+
+type RevokedTokenModel struct {
+	DB *sql.DB
+}
+
+// RefreshToken is a single login session. Only the SHA-256 hash of the token
+// handed to the client is stored. AccessTokenId is the jti of the access token
+// issued together with it, so revoking the session can revoke that too.
+type RefreshToken struct {
+	ID              int
+	UserId          int
+	TokenHash       string
+	AccessTokenId   string
+	AccessExpiresAt time.Time
+	ExpiresAt       time.Time
+	CreatedAt       time.Time
+}
+
+func (m *RefreshTokenModel) Insert(ctx context.Context, token *RefreshToken) (*RefreshToken, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return insertRefreshToken(ctx, m.DB, token)
+}
+
+func insertRefreshToken(ctx context.Context, q dbtx, token *RefreshToken) (*RefreshToken, error) {
+	query := `
+		INSERT INTO refresh_tokens (user_id, token_hash, access_token_id, access_expires_at, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`
+
+	result, err := q.ExecContext(ctx, query, token.UserId, token.TokenHash, token.AccessTokenId, token.AccessExpiresAt, token.ExpiresAt, token.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	token.ID = int(id)
+
+	return token, nil
+}
+
+// Rotate exchanges the refresh token with the given hash for next. The old
+// token is revoked in the same transaction, so it can only be exchanged once.
+// Presenting a token that was already revoked is treated as theft: every
+// session of its user is revoked and ErrRefreshTokenReused is returned.
+func (m *RefreshTokenModel) Rotate(ctx context.Context, tokenHash string, next *RefreshToken) (*RefreshToken, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	reused := false
+	err := withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		var id, userId int
+		var revokedAt sql.NullString
+		var expired bool
+
+		query := "SELECT id, user_id, revoked_at, expires_at <= ? FROM refresh_tokens WHERE token_hash = ? FOR UPDATE"
+		err := tx.QueryRowContext(ctx, query, next.CreatedAt, tokenHash).Scan(&id, &userId, &revokedAt, &expired)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return ErrRefreshTokenInvalid
+			}
+			return err
+		}
+
+		if revokedAt.Valid {
+			reused = true
+			return revokeAllForUser(ctx, tx, userId, next.CreatedAt)
+		}
+
+		if expired {
+			return ErrRefreshTokenInvalid
+		}
+
+		if _, err := tx.ExecContext(ctx, "UPDATE refresh_tokens SET revoked_at = ? WHERE id = ?", next.CreatedAt, id); err != nil {
+			return err
+		}
+
+		next.UserId = userId
+		_, err = insertRefreshToken(ctx, tx, next)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if reused {
+		return nil, ErrRefreshTokenReused
+	}
+
+	return next, nil
+}
+
+// RevokeSession ends the session that issued the access token with the given
+// jti: the access token is blacklisted until it expires and its refresh token
+// is revoked.
+func (m *RefreshTokenModel) RevokeSession(ctx context.Context, userId int, jti string, accessExpiresAt, now time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, "INSERT IGNORE INTO revoked_tokens (jti, expires_at) VALUES (?, ?)", jti, accessExpiresAt); err != nil {
+			return err
+		}
+
+		query := "UPDATE refresh_tokens SET revoked_at = ? WHERE user_id = ? AND access_token_id = ? AND revoked_at IS NULL"
+		_, err := tx.ExecContext(ctx, query, now, userId, jti)
+		return err
+	})
+}
+
+// RevokeAllForUser ends every session of a user, including access tokens
+// that have not expired yet.
+func (m *RefreshTokenModel) RevokeAllForUser(ctx context.Context, userId int, now time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		return revokeAllForUser(ctx, tx, userId, now)
+	})
+}
+
+func revokeAllForUser(ctx context.Context, tx *sql.Tx, userId int, now time.Time) error {
+	query := `
+		INSERT IGNORE INTO revoked_tokens (jti, expires_at)
+		SELECT access_token_id, access_expires_at
+		FROM refresh_tokens
+		WHERE user_id = ? AND access_expires_at > ?
+	`
+	if _, err := tx.ExecContext(ctx, query, userId, now); err != nil {
+		return err
+	}
+
+	_, err := tx.ExecContext(ctx, "UPDATE refresh_tokens SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL", now, userId)
+	return err
+}
+
+func (m *RevokedTokenModel) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var exists bool
+	err := m.DB.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM revoked_tokens WHERE jti = ?)", jti).Scan(&exists)
+	if err != nil {
+		return false, err
+	}
+
+	return exists, nil
+}
+
+// DeleteExpired removes blacklist entries whose access tokens have expired on
+// their own and no longer need to be checked.
+func (m *RevokedTokenModel) DeleteExpired(ctx context.Context, now time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, "DELETE FROM revoked_tokens WHERE expires_at <= ?", now)
+	return err
+}
@@ -0,0 +1,298 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+var (
+	ErrTicketTypeNotFound  = errors.New("ticket type not found")
+	ErrTicketTypeExists    = errors.New("event already has a ticket type with this name")
+	ErrTicketTypeInUse     = errors.New("ticket type has already been sold")
+	ErrTicketTypeSoldOut   = errors.New("ticket type is sold out")
+	ErrTicketTypeNotOnSale = errors.New("ticket type is not on sale")
+	ErrQuantityBelowSold   = errors.New("quantity is below the number of tickets sold")
+	ErrInvalidSalesWindow  = errors.New("sales_end_at must be after sales_start_at")
+
+	mysqlErrRowIsReferenced = uint16(1451)
+)
+
+type TicketTypeModel struct {
+	DB *sql.DB
+}
+
+// TicketType is a kind of ticket sold for an event, such as Early Bird or
+// VIP. Price is in the minor unit of Currency, for example cents, and a free
+// ticket type has a price of 0. A Quantity of 0 means the ticket type is
+// only limited by the capacity of the event. Tickets can be bought between
+// SalesStartAt and SalesEndAt; either end may be left open.
+//
+// Sold counts the registrations holding a ticket of this type, including
+// those waiting for their payment to go through.
+type TicketType struct {
+	ID           int        `json:"id"`
+	EventId      int        `json:"event_id"`
+	Name         string     `json:"name" binding:"required,min=1,max=100"`
+	Price        int64      `json:"price" binding:"min=0"`
+	Currency     string     `json:"currency" binding:"required,iso4217"`
+	Quantity     int        `json:"quantity" binding:"min=0"`
+	SalesStartAt *time.Time `json:"sales_start_at,omitempty"`
+	SalesEndAt   *time.Time `json:"sales_end_at,omitempty"`
+	Sold         int        `json:"sold"`
+}
+
+const ticketTypeColumns = `
+	t.id, t.event_id, t.name, t.price, t.currency, t.quantity, t.sales_start_at, t.sales_end_at,
+	(SELECT COUNT(*) FROM attendees a WHERE a.ticket_type_id = t.id AND a.status <> 'declined')
+`
+
+// ValidateSalesWindow checks that the sales window does not end before it
+// starts.
+func (t *TicketType) ValidateSalesWindow() error {
+	if t.SalesStartAt != nil && t.SalesEndAt != nil && !t.SalesEndAt.After(*t.SalesStartAt) {
+		return ErrInvalidSalesWindow
+	}
+
+	return nil
+}
+
+// OnSale reports whether tickets of this type can be bought at the given
+// time.
+func (t *TicketType) OnSale(now time.Time) bool {
+	if t.SalesStartAt != nil && now.Before(*t.SalesStartAt) {
+		return false
+	}
+
+	return t.SalesEndAt == nil || now.Before(*t.SalesEndAt)
+}
+
+// SoldOut reports whether every ticket of this type has been taken.
+func (t *TicketType) SoldOut() bool {
+	return t.Quantity > 0 && t.Sold >= t.Quantity
+}
+
+func (t *TicketType) normalize() {
+	if t.SalesStartAt != nil {
+		start := t.SalesStartAt.UTC()
+		t.SalesStartAt = &start
+	}
+	if t.SalesEndAt != nil {
+		end := t.SalesEndAt.UTC()
+		t.SalesEndAt = &end
+	}
+}
+
+func (m *TicketTypeModel) Insert(ctx context.Context, ticketType *TicketType) (*TicketType, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	ticketType.normalize()
+
+	query := `
+		INSERT INTO ticket_types (event_id, name, price, currency, quantity, sales_start_at, sales_end_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`
+
+	result, err := m.DB.ExecContext(ctx, query, ticketType.EventId, ticketType.Name, ticketType.Price, ticketType.Currency, ticketType.Quantity, ticketType.SalesStartAt, ticketType.SalesEndAt)
+	if err != nil {
+		if isDuplicateEntry(err) {
+			return nil, ErrTicketTypeExists
+		}
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	ticketType.ID = int(id)
+	ticketType.Sold = 0
+
+	return ticketType, nil
+}
+
+// Get returns a ticket type of an event, or nil when the event has no such
+// ticket type.
+func (m *TicketTypeModel) Get(ctx context.Context, eventId, id int) (*TicketType, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return getTicketType(ctx, m.DB, "SELECT "+ticketTypeColumns+" FROM ticket_types t WHERE t.id = ? AND t.event_id = ?", id, eventId)
+}
+
+// GetByEvent returns the ticket types of an event, cheapest first.
+func (m *TicketTypeModel) GetByEvent(ctx context.Context, eventId int) ([]*TicketType, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := "SELECT " + ticketTypeColumns + " FROM ticket_types t WHERE t.event_id = ? ORDER BY t.price, t.id"
+
+	rows, err := m.DB.QueryContext(ctx, query, eventId)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	ticketTypes := []*TicketType{}
+	for rows.Next() {
+		ticketType, err := scanTicketType(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		ticketTypes = append(ticketTypes, ticketType)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return ticketTypes, nil
+}
+
+// ExistForEvent reports whether tickets have to be bought to attend an event.
+func (m *TicketTypeModel) ExistForEvent(ctx context.Context, eventId int) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var exists bool
+	err := m.DB.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM ticket_types WHERE event_id = ?)", eventId).Scan(&exists)
+	if err != nil {
+		return false, err
+	}
+
+	return exists, nil
+}
+
+// HasPaid reports whether any event sells tickets that have to be paid for.
+func (m *TicketTypeModel) HasPaid(ctx context.Context) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var exists bool
+	err := m.DB.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM ticket_types WHERE price > 0)").Scan(&exists)
+	if err != nil {
+		return false, err
+	}
+
+	return exists, nil
+}
+
+// Update replaces the settings of a ticket type. Tickets already sold keep
+// the price they were bought at, but the quantity cannot drop below the
+// number sold.
+func (m *TicketTypeModel) Update(ctx context.Context, ticketType *TicketType) (*TicketType, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	ticketType.normalize()
+
+	err := withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		existing, err := lockTicketType(ctx, tx, ticketType.EventId, ticketType.ID)
+		if err != nil {
+			return err
+		}
+
+		if ticketType.Quantity > 0 && ticketType.Quantity < existing.Sold {
+			return ErrQuantityBelowSold
+		}
+
+		query := `
+			UPDATE ticket_types
+			SET name = ?, price = ?, currency = ?, quantity = ?, sales_start_at = ?, sales_end_at = ?
+			WHERE id = ?
+		`
+
+		_, err = tx.ExecContext(ctx, query, ticketType.Name, ticketType.Price, ticketType.Currency, ticketType.Quantity, ticketType.SalesStartAt, ticketType.SalesEndAt, ticketType.ID)
+		if err != nil {
+			if isDuplicateEntry(err) {
+				return ErrTicketTypeExists
+			}
+			return err
+		}
+
+		ticketType.Sold = existing.Sold
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ticketType, nil
+}
+
+// Delete removes a ticket type that has never been sold. Once a registration
+// or payment refers to it, it fails with ErrTicketTypeInUse; set its sales
+// window to stop selling it instead.
+func (m *TicketTypeModel) Delete(ctx context.Context, eventId, id int) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, "DELETE FROM ticket_types WHERE id = ? AND event_id = ?", id, eventId)
+	if err != nil {
+		var mysqlErr *mysql.MySQLError
+		if errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlErrRowIsReferenced {
+			return false, ErrTicketTypeInUse
+		}
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+// lockTicketType reads a ticket type of an event and locks it until the
+// transaction ends, so concurrent sales cannot exceed its quantity.
+func lockTicketType(ctx context.Context, tx *sql.Tx, eventId, id int) (*TicketType, error) {
+	ticketType, err := getTicketType(ctx, tx, "SELECT "+ticketTypeColumns+" FROM ticket_types t WHERE t.id = ? AND t.event_id = ? FOR UPDATE", id, eventId)
+	if err != nil {
+		return nil, err
+	}
+
+	if ticketType == nil {
+		return nil, ErrTicketTypeNotFound
+	}
+
+	return ticketType, nil
+}
+
+func getTicketType(ctx context.Context, q dbtx, query string, args ...any) (*TicketType, error) {
+	ticketType, err := scanTicketType(q.QueryRowContext(ctx, query, args...))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return ticketType, nil
+}
+
+func scanTicketType(row rowScanner) (*TicketType, error) {
+	var ticketType TicketType
+	var salesStartAt, salesEndAt sql.NullTime
+
+	err := row.Scan(&ticketType.ID, &ticketType.EventId, &ticketType.Name, &ticketType.Price, &ticketType.Currency, &ticketType.Quantity, &salesStartAt, &salesEndAt, &ticketType.Sold)
+	if err != nil {
+		return nil, err
+	}
+
+	if salesStartAt.Valid {
+		ticketType.SalesStartAt = &salesStartAt.Time
+	}
+	if salesEndAt.Valid {
+		ticketType.SalesEndAt = &salesEndAt.Time
+	}
+
+	return &ticketType, nil
+}
@@ -9,11 +9,19 @@ type UserModel struct {
 	DB *sql.DB
 }
 
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
 type User struct {
 	ID       int    `json:"id"`
 	Email    string `json:"email"`
 	Name     string `json:"name"`
 	Password string `json:"-"`
+	Verified bool   `json:"verified"`
+	Role     string `json:"role,omitempty"`
 }
 
 type UserRepository interface {
@@ -37,6 +45,7 @@ func (m *UserModel) InsertUser(ctx context.Context, user *User) (*User, error) {
 	}
 
 	user.ID = int(id)
+	user.Role = RoleUser
 
 	return user, nil
 }
@@ -46,7 +55,7 @@ func (m *UserModel) getUser(ctx context.Context, query string, args ...interface
 	defer cancel()
 
 	var user User
-	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&user.ID, &user.Email, &user.Name, &user.Password)
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&user.ID, &user.Email, &user.Name, &user.Password, &user.Verified, &user.Role)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -58,11 +67,19 @@ func (m *UserModel) getUser(ctx context.Context, query string, args ...interface
 }
 
 func (m *UserModel) GetUserById(id int) (*User, error) {
-	query := "SELECT id, email, name, password FROM users WHERE id = ?"
+	query := "SELECT id, email, name, password, verified_at IS NOT NULL, role FROM users WHERE id = ?"
 	return m.getUser(context.Background(), query, id)
 }
 
 func (m *UserModel) GetUserByEmail(email string) (*User, error) {
-	query := "SELECT id, email, name, password FROM users WHERE email = ?"
+	query := "SELECT id, email, name, password, verified_at IS NOT NULL, role FROM users WHERE email = ?"
 	return m.getUser(context.Background(), query, email)
 }
+
+func (m *UserModel) UpdateRole(ctx context.Context, id int, role string) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, "UPDATE users SET role = ? WHERE id = ?", role, id)
+	return err
+}
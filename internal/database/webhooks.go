@@ -0,0 +1,415 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliveryDelivered = "delivered"
+	WebhookDeliveryDead      = "dead"
+)
+
+type WebhookModel struct {
+	DB *sql.DB
+}
+
+// WebhookSubscription sends the webhooks of the listed event types, for every
+// event its user owns, to URL. The secret signs every request; it has to be
+// stored as is, since it is needed to sign.
+type WebhookSubscription struct {
+	ID         int       `json:"id"`
+	UserId     int       `json:"user_id"`
+	URL        string    `json:"url"`
+	EventTypes []string  `json:"event_types"`
+	Secret     string    `json:"-"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// WebhookDelivery is one webhook sent to a subscription, with the outcome of
+// its latest attempt. Pending deliveries are retried at NextAttemptAt; dead
+// ones ran out of attempts and are only sent again on request.
+type WebhookDelivery struct {
+	ID             int             `json:"id"`
+	SubscriptionId int             `json:"subscription_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload" swaggertype:"object"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at,omitempty"`
+	LastStatusCode *int            `json:"last_status_code,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+// DueWebhook is a delivery about to be sent together with where to send it.
+type DueWebhook struct {
+	WebhookDelivery
+	URL    string
+	Secret string
+}
+
+const webhookDeliveryColumns = "d.id, d.subscription_id, d.event_type, d.payload, d.status, d.attempts, d.next_attempt_at, d.last_status_code, d.last_error, d.delivered_at, d.created_at"
+
+func (m *WebhookModel) InsertSubscription(ctx context.Context, subscription *WebhookSubscription) (*WebhookSubscription, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	eventTypes, err := json.Marshal(subscription.EventTypes)
+	if err != nil {
+		return nil, err
+	}
+
+	subscription.CreatedAt = time.Now().UTC().Truncate(time.Second)
+
+	query := "INSERT INTO webhook_subscriptions (user_id, url, secret, event_types, created_at) VALUES (?, ?, ?, ?, ?)"
+	result, err := m.DB.ExecContext(ctx, query, subscription.UserId, subscription.URL, subscription.Secret, string(eventTypes), subscription.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	subscription.ID = int(id)
+
+	return subscription, nil
+}
+
+// GetSubscription returns a subscription of a user, or nil when the user has
+// no such subscription.
+func (m *WebhookModel) GetSubscription(ctx context.Context, userId, id int) (*WebhookSubscription, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := "SELECT id, user_id, url, secret, event_types, created_at FROM webhook_subscriptions WHERE id = ? AND user_id = ?"
+
+	subscription, err := scanWebhookSubscription(m.DB.QueryRowContext(ctx, query, id, userId))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return subscription, nil
+}
+
+func (m *WebhookModel) GetSubscriptionsByUser(ctx context.Context, userId int) ([]*WebhookSubscription, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := "SELECT id, user_id, url, secret, event_types, created_at FROM webhook_subscriptions WHERE user_id = ? ORDER BY id"
+
+	rows, err := m.DB.QueryContext(ctx, query, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	subscriptions := []*WebhookSubscription{}
+	for rows.Next() {
+		subscription, err := scanWebhookSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		subscriptions = append(subscriptions, subscription)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return subscriptions, nil
+}
+
+// DeleteSubscription removes a subscription of a user together with its
+// delivery log; pending deliveries are dropped.
+func (m *WebhookModel) DeleteSubscription(ctx context.Context, userId, id int) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, "DELETE FROM webhook_subscriptions WHERE id = ? AND user_id = ?", id, userId)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+// WebhookJob is the payload of the job that sends a delivery.
+type WebhookJob struct {
+	DeliveryId int `json:"delivery_id"`
+}
+
+// Enqueue queues the webhook of an outbox message for every subscription of
+// a user that asked for its event type and returns how many were queued.
+// Each delivery comes with a job, made from job with a WebhookJob payload,
+// that sends it; both are written in one transaction, so no delivery is left
+// without a job. A message handled again is not queued again for the
+// subscriptions that already have it.
+func (m *WebhookModel) Enqueue(ctx context.Context, userId int, outboxId int64, eventType string, payload []byte, job Job) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var queued int
+	err := withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, "SELECT id FROM webhook_subscriptions WHERE user_id = ? AND JSON_CONTAINS(event_types, JSON_QUOTE(?)) ORDER BY id", userId, eventType)
+		if err != nil {
+			return err
+		}
+
+		defer rows.Close()
+
+		var subscriptionIds []int
+		for rows.Next() {
+			var id int
+			if err := rows.Scan(&id); err != nil {
+				return err
+			}
+
+			subscriptionIds = append(subscriptionIds, id)
+		}
+
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, subscriptionId := range subscriptionIds {
+			query := "INSERT IGNORE INTO webhook_deliveries (subscription_id, outbox_id, event_type, payload, status, next_attempt_at, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)"
+			result, err := tx.ExecContext(ctx, query, subscriptionId, outboxId, eventType, string(payload), WebhookDeliveryPending, job.RunAt, job.RunAt)
+			if err != nil {
+				return err
+			}
+
+			// The subscription already has a delivery of this message.
+			inserted, err := result.RowsAffected()
+			if err != nil {
+				return err
+			}
+			if inserted == 0 {
+				continue
+			}
+
+			id, err := result.LastInsertId()
+			if err != nil {
+				return err
+			}
+
+			if err := enqueueJobWith(ctx, tx, job, WebhookJob{DeliveryId: int(id)}); err != nil {
+				return err
+			}
+
+			queued++
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return queued, nil
+}
+
+// GetDue returns a pending delivery together with where to send it, or nil
+// when it was delivered, is dead or no longer exists.
+func (m *WebhookModel) GetDue(ctx context.Context, id int) (*DueWebhook, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `
+		SELECT ` + webhookDeliveryColumns + `, s.url, s.secret
+		FROM webhook_deliveries d
+		JOIN webhook_subscriptions s ON s.id = d.subscription_id
+		WHERE d.id = ? AND d.status = ?
+	`
+
+	var webhook DueWebhook
+	err := scanWebhookDelivery(m.DB.QueryRowContext(ctx, query, id, WebhookDeliveryPending), &webhook.WebhookDelivery, &webhook.URL, &webhook.Secret)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &webhook, nil
+}
+
+// MarkDelivered records a successful attempt of a delivery.
+func (m *WebhookModel) MarkDelivered(ctx context.Context, id, statusCode int, now time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `
+		UPDATE webhook_deliveries
+		SET status = ?, attempts = attempts + 1, last_status_code = ?, last_error = NULL, next_attempt_at = NULL, delivered_at = ?
+		WHERE id = ?
+	`
+
+	_, err := m.DB.ExecContext(ctx, query, WebhookDeliveryDelivered, statusCode, now, id)
+	return err
+}
+
+// MarkFailed records a failed attempt of a delivery. It is retried at
+// nextAttemptAt, or moved to the dead state when nextAttemptAt is nil. A
+// statusCode of 0 means no response was received.
+func (m *WebhookModel) MarkFailed(ctx context.Context, id, statusCode int, reason string, nextAttemptAt *time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	status := WebhookDeliveryPending
+	if nextAttemptAt == nil {
+		status = WebhookDeliveryDead
+	}
+
+	var code any
+	if statusCode != 0 {
+		code = statusCode
+	}
+
+	if len(reason) > 500 {
+		reason = reason[:500]
+	}
+
+	query := `
+		UPDATE webhook_deliveries
+		SET status = ?, attempts = attempts + 1, last_status_code = ?, last_error = ?, next_attempt_at = ?
+		WHERE id = ?
+	`
+
+	_, err := m.DB.ExecContext(ctx, query, status, code, reason, nextAttemptAt, id)
+	return err
+}
+
+// Redeliver sends a dead delivery again with a fresh set of attempts, by
+// queueing a new job made from job.
+func (m *WebhookModel) Redeliver(ctx context.Context, subscriptionId, id int, job Job) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var queued bool
+	err := withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		query := "UPDATE webhook_deliveries SET status = ?, attempts = 0, next_attempt_at = ? WHERE id = ? AND subscription_id = ? AND status = ?"
+
+		result, err := tx.ExecContext(ctx, query, WebhookDeliveryPending, job.RunAt, id, subscriptionId, WebhookDeliveryDead)
+		if err != nil {
+			return err
+		}
+
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if affected == 0 {
+			return nil
+		}
+
+		queued = true
+		return enqueueJobWith(ctx, tx, job, WebhookJob{DeliveryId: id})
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return queued, nil
+}
+
+// GetDeliveries returns the delivery log of a subscription, newest first,
+// optionally only the deliveries in one status.
+func (m *WebhookModel) GetDeliveries(ctx context.Context, subscriptionId int, status string, limit int) ([]*WebhookDelivery, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := "SELECT " + webhookDeliveryColumns + " FROM webhook_deliveries d WHERE d.subscription_id = ?"
+	args := []any{subscriptionId}
+	if status != "" {
+		query += " AND d.status = ?"
+		args = append(args, status)
+	}
+	query += " ORDER BY d.created_at DESC, d.id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	deliveries := []*WebhookDelivery{}
+	for rows.Next() {
+		var delivery WebhookDelivery
+		if err := scanWebhookDelivery(rows, &delivery); err != nil {
+			return nil, err
+		}
+
+		deliveries = append(deliveries, &delivery)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+func scanWebhookSubscription(row rowScanner) (*WebhookSubscription, error) {
+	var subscription WebhookSubscription
+	var eventTypes string
+
+	err := row.Scan(&subscription.ID, &subscription.UserId, &subscription.URL, &subscription.Secret, &eventTypes, &subscription.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(eventTypes), &subscription.EventTypes); err != nil {
+		return nil, err
+	}
+
+	return &subscription, nil
+}
+
+// scanWebhookDelivery scans webhookDeliveryColumns into delivery, followed
+// by any extra columns into extra.
+func scanWebhookDelivery(row rowScanner, delivery *WebhookDelivery, extra ...any) error {
+	var payload string
+	var nextAttemptAt, deliveredAt sql.NullTime
+	var lastStatusCode sql.NullInt64
+	var lastError sql.NullString
+
+	dest := []any{&delivery.ID, &delivery.SubscriptionId, &delivery.EventType, &payload, &delivery.Status, &delivery.Attempts, &nextAttemptAt, &lastStatusCode, &lastError, &deliveredAt, &delivery.CreatedAt}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return err
+	}
+
+	delivery.Payload = json.RawMessage(payload)
+	if nextAttemptAt.Valid {
+		delivery.NextAttemptAt = &nextAttemptAt.Time
+	}
+	if lastStatusCode.Valid {
+		code := int(lastStatusCode.Int64)
+		delivery.LastStatusCode = &code
+	}
+	delivery.LastError = lastError.String
+	if deliveredAt.Valid {
+		delivery.DeliveredAt = &deliveredAt.Time
+	}
+
+	return nil
+}
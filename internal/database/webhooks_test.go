@@ -0,0 +1,57 @@
+package database
+
+import (
+	"context"
+	"rest-api-event-app/internal/testdb"
+	"testing"
+	"time"
+)
+
+// TestEnqueueWebhookOncePerMessage hands the same outbox message to Enqueue
+// twice, as when a step after publishing its webhook failed and the message
+// is retried, and checks that each subscription gets a single delivery.
+func TestEnqueueWebhookOncePerMessage(t *testing.T) {
+	db := testdb.Open(t)
+	webhooks := WebhookModel{DB: db}
+	ctx := context.Background()
+
+	user := createTestUser(t, db)
+
+	var subscriptions []*WebhookSubscription
+	for _, eventTypes := range [][]string{{"event.updated"}, {"event.created", "event.updated"}, {"event.deleted"}} {
+		subscription, err := webhooks.InsertSubscription(ctx, &WebhookSubscription{UserId: user.ID, URL: "https://example.com/hook", EventTypes: eventTypes, Secret: "whsec_test"})
+		if err != nil {
+			t.Fatalf("creating subscription: %s", err)
+		}
+		subscriptions = append(subscriptions, subscription)
+	}
+
+	outboxId := time.Now().UnixNano()
+	job := Job{Kind: testdb.UniqueName("webhooks"), MaxAttempts: 1, RunAt: time.Now().UTC()}
+	payload := []byte(`{"id":"evt_1"}`)
+
+	for i, want := range []int{2, 0} {
+		queued, err := webhooks.Enqueue(ctx, user.ID, outboxId, "event.updated", payload, job)
+		if err != nil {
+			t.Fatalf("queueing the message, time %d: %s", i+1, err)
+		}
+		if queued != want {
+			t.Errorf("queueing the message, time %d: queued %d deliveries, want %d", i+1, queued, want)
+		}
+	}
+
+	for i, want := range []int{1, 1, 0} {
+		deliveries, err := webhooks.GetDeliveries(ctx, subscriptions[i].ID, "", 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(deliveries) != want {
+			t.Errorf("subscription %d has %d deliveries, want %d", i+1, len(deliveries), want)
+		}
+	}
+
+	// Another message is delivered again.
+	if queued, err := webhooks.Enqueue(ctx, user.ID, outboxId+1, "event.updated", payload, job); err != nil || queued != 2 {
+		t.Errorf("queueing another message: queued %d, %v; want 2", queued, err)
+	}
+}
@@ -3,6 +3,8 @@ package env
 import (
 	"os"
 	"strconv"
+	"strings"
+	"time"
 )
 
 func GetEnvString(key, defaultValue string) string {
@@ -22,3 +24,34 @@ func GetEnvInt(key string, defaultValue int) int {
 
 	return defaultValue
 }
+
+func GetEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value, exists := os.LookupEnv(key); exists {
+		if durationValue, err := time.ParseDuration(value); err == nil {
+			return durationValue
+		}
+	}
+
+	return defaultValue
+}
+
+// GetEnvDurations reads a comma-separated list of durations, such as
+// "24h,1h". The default is returned when any of them does not parse.
+func GetEnvDurations(key string, defaultValue []time.Duration) []time.Duration {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+
+	var durations []time.Duration
+	for _, part := range strings.Split(value, ",") {
+		durationValue, err := time.ParseDuration(strings.TrimSpace(part))
+		if err != nil {
+			return defaultValue
+		}
+
+		durations = append(durations, durationValue)
+	}
+
+	return durations
+}
@@ -0,0 +1,335 @@
+package ical
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const dateFormat = "20060102"
+
+// ParseError reports a VEVENT that could not be decoded. Line is the line of
+// its BEGIN:VEVENT.
+type ParseError struct {
+	Line int
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// Decode reads the VEVENTs of an iCalendar object. Events that cannot be
+// decoded are skipped and reported as a ParseError each, so one bad event
+// does not hide the others; the returned error is only set when the object
+// as a whole cannot be read.
+//
+// Times with a TZID must use an IANA zone name. Floating times use the
+// calendar's X-WR-TIMEZONE, or UTC. Events without DTEND end after their
+// DURATION, or after one day when DTSTART is a date.
+func Decode(r io.Reader) ([]Event, []*ParseError, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var events []Event
+	var errs []*ParseError
+
+	defaultLoc := time.UTC
+	var stack []string
+	var current *eventDecoder
+
+	for _, line := range lines {
+		name, params, value, err := parseLine(line.text)
+		if err != nil {
+			if current != nil {
+				current.fail(err)
+				continue
+			}
+			return nil, nil, fmt.Errorf("line %d: %w", line.number, err)
+		}
+
+		switch name {
+		case "BEGIN":
+			stack = append(stack, strings.ToUpper(value))
+			if len(stack) == 2 && stack[1] == "VEVENT" {
+				current = &eventDecoder{line: line.number, defaultLoc: defaultLoc}
+				current.event.Line = line.number
+			}
+			continue
+		case "END":
+			if len(stack) == 0 || stack[len(stack)-1] != strings.ToUpper(value) {
+				return nil, nil, fmt.Errorf("line %d: unexpected END:%s", line.number, value)
+			}
+			stack = stack[:len(stack)-1]
+			if current != nil && len(stack) == 1 {
+				if event, err := current.finish(); err != nil {
+					errs = append(errs, &ParseError{Line: current.line, Err: err})
+				} else {
+					events = append(events, event)
+				}
+				current = nil
+			}
+			continue
+		}
+
+		switch {
+		case len(stack) == 1 && stack[0] == "VCALENDAR" && name == "X-WR-TIMEZONE":
+			if loc, err := time.LoadLocation(value); err == nil {
+				defaultLoc = loc
+			}
+		case current != nil && len(stack) == 2:
+			current.property(name, params, value)
+		}
+	}
+
+	if len(stack) != 0 {
+		return nil, nil, errors.New("unterminated " + stack[len(stack)-1])
+	}
+
+	return events, errs, nil
+}
+
+type contentLine struct {
+	number int
+	text   string
+}
+
+// unfold joins folded continuation lines, remembering where each logical
+// line started.
+func unfold(r io.Reader) ([]contentLine, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var lines []contentLine
+	number := 0
+	for scanner.Scan() {
+		number++
+		text := strings.TrimRight(scanner.Text(), "\r")
+
+		if (strings.HasPrefix(text, " ") || strings.HasPrefix(text, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1].text += text[1:]
+			continue
+		}
+
+		if text == "" {
+			continue
+		}
+
+		lines = append(lines, contentLine{number: number, text: text})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return lines, nil
+}
+
+// parseLine splits a content line into its upper-cased name, its parameters
+// and its value. Parameter values may be quoted to contain ':' and ';'.
+func parseLine(line string) (string, map[string]string, string, error) {
+	inQuotes := false
+	colon := -1
+	for i, r := range line {
+		if r == '"' {
+			inQuotes = !inQuotes
+		}
+		if r == ':' && !inQuotes {
+			colon = i
+			break
+		}
+	}
+
+	if colon < 0 {
+		return "", nil, "", fmt.Errorf("malformed content line %q", line)
+	}
+
+	head, value := line[:colon], line[colon+1:]
+	parts := splitUnquoted(head, ';')
+
+	params := map[string]string{}
+	for _, part := range parts[1:] {
+		key, val, ok := strings.Cut(part, "=")
+		if !ok {
+			return "", nil, "", fmt.Errorf("malformed parameter %q", part)
+		}
+		params[strings.ToUpper(key)] = strings.Trim(val, `"`)
+	}
+
+	return strings.ToUpper(parts[0]), params, value, nil
+}
+
+func splitUnquoted(s string, sep rune) []string {
+	var parts []string
+	inQuotes := false
+	start := 0
+	for i, r := range s {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+		case r == sep && !inQuotes:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+
+	return append(parts, s[start:])
+}
+
+type eventDecoder struct {
+	line       int
+	defaultLoc *time.Location
+	event      Event
+	allDay     bool
+	duration   time.Duration
+	err        error
+}
+
+func (d *eventDecoder) fail(err error) {
+	if d.err == nil {
+		d.err = err
+	}
+}
+
+func (d *eventDecoder) property(name string, params map[string]string, value string) {
+	switch name {
+	case "UID":
+		d.event.UID = unescapeText(value)
+	case "DTSTAMP":
+		d.event.Stamp, _, _, _ = parseDateTime(value, params, time.UTC)
+	case "SUMMARY":
+		d.event.Summary = unescapeText(value)
+	case "DESCRIPTION":
+		d.event.Description = unescapeText(value)
+	case "LOCATION":
+		d.event.Location = unescapeText(value)
+	case "URL":
+		d.event.URL = value
+	case "RRULE":
+		d.event.RRule = value
+	case "DTSTART":
+		start, loc, allDay, err := parseDateTime(value, params, d.defaultLoc)
+		if err != nil {
+			d.fail(fmt.Errorf("DTSTART: %w", err))
+			return
+		}
+		d.event.Start, d.event.TimeZone, d.allDay = start, loc.String(), allDay
+	case "DTEND":
+		end, _, _, err := parseDateTime(value, params, d.defaultLoc)
+		if err != nil {
+			d.fail(fmt.Errorf("DTEND: %w", err))
+			return
+		}
+		d.event.End = end
+	case "DURATION":
+		duration, err := parseDuration(value)
+		if err != nil {
+			d.fail(fmt.Errorf("DURATION: %w", err))
+			return
+		}
+		d.duration = duration
+	case "EXDATE":
+		for _, v := range strings.Split(value, ",") {
+			exdate, _, _, err := parseDateTime(v, params, d.defaultLoc)
+			if err != nil {
+				d.fail(fmt.Errorf("EXDATE: %w", err))
+				return
+			}
+			d.event.ExDates = append(d.event.ExDates, exdate)
+		}
+	}
+}
+
+func (d *eventDecoder) finish() (Event, error) {
+	if d.err != nil {
+		return Event{}, d.err
+	}
+
+	if d.event.Start.IsZero() {
+		return Event{}, errors.New("missing DTSTART")
+	}
+
+	if d.event.End.IsZero() {
+		switch {
+		case d.duration > 0:
+			d.event.End = d.event.Start.Add(d.duration)
+		case d.allDay:
+			d.event.End = d.event.Start.AddDate(0, 0, 1)
+		default:
+			d.event.End = d.event.Start
+		}
+	}
+
+	return d.event, nil
+}
+
+// parseDateTime parses a DATE or DATE-TIME value and returns it with the
+// location it is in and whether it was a date.
+func parseDateTime(value string, params map[string]string, defaultLoc *time.Location) (time.Time, *time.Location, bool, error) {
+	loc := defaultLoc
+	if tzid, ok := params["TZID"]; ok {
+		var err error
+		loc, err = time.LoadLocation(strings.TrimPrefix(tzid, "/"))
+		if err != nil {
+			return time.Time{}, nil, false, fmt.Errorf("unknown time zone %q", tzid)
+		}
+	}
+
+	if params["VALUE"] == "DATE" || len(value) == len(dateFormat) {
+		t, err := time.ParseInLocation(dateFormat, value, loc)
+		return t, loc, true, err
+	}
+
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse(utcFormat, value)
+		return t, time.UTC, false, err
+	}
+
+	t, err := time.ParseInLocation(localFormat, value, loc)
+	return t, loc, false, err
+}
+
+var durationPattern = regexp.MustCompile(`^([+-])?P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+// parseDuration parses an RFC 5545 DURATION value such as PT1H30M.
+func parseDuration(value string) (time.Duration, error) {
+	match := durationPattern.FindStringSubmatch(value)
+	if match == nil || value == "P" || strings.HasSuffix(value, "T") {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+
+	units := []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second}
+
+	var duration time.Duration
+	for i, unit := range units {
+		if match[i+2] == "" {
+			continue
+		}
+		n, err := strconv.Atoi(match[i+2])
+		if err != nil {
+			return 0, err
+		}
+		duration += time.Duration(n) * unit
+	}
+
+	if match[1] == "-" {
+		duration = -duration
+	}
+
+	return duration, nil
+}
+
+func unescapeText(s string) string {
+	return strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n").Replace(s)
+}
@@ -0,0 +1,255 @@
+package ical
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events := []Event{
+		{
+			UID:         "event-1@example.com",
+			Stamp:       time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+			Summary:     "Weekly sync; team, all hands",
+			Description: "Line one\nLine two with a backslash \\ and a comma,",
+			Location:    "Room 4, Main St. 1",
+			URL:         "https://example.com/events/1",
+			Start:       time.Date(2025, 3, 17, 10, 0, 0, 0, berlin),
+			End:         time.Date(2025, 3, 17, 11, 30, 0, 0, berlin),
+			TimeZone:    "Europe/Berlin",
+			RRule:       "FREQ=WEEKLY;BYDAY=MO;COUNT=10",
+			ExDates:     []time.Time{time.Date(2025, 3, 31, 10, 0, 0, 0, berlin)},
+		},
+		{
+			UID:      "event-2@example.com",
+			Stamp:    time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+			Summary:  strings.Repeat("Ünïcödé summary that is long enough to be folded ", 3),
+			Start:    time.Date(2025, 6, 1, 18, 0, 0, 0, time.UTC),
+			End:      time.Date(2025, 6, 1, 20, 0, 0, 0, time.UTC),
+			TimeZone: "UTC",
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, Calendar{ProdID: "-//Test//Events//EN", Name: "Test", Events: events}); err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, errs, err := Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(errs) > 0 {
+		t.Fatalf("decode errors: %v", errs)
+	}
+	if len(decoded) != len(events) {
+		t.Fatalf("decoded %d events, want %d", len(decoded), len(events))
+	}
+
+	for i, want := range events {
+		got := decoded[i]
+
+		if got.UID != want.UID || got.Summary != want.Summary || got.Description != want.Description ||
+			got.Location != want.Location || got.URL != want.URL || got.RRule != want.RRule || got.TimeZone != want.TimeZone {
+			t.Errorf("event %d: got %+v, want %+v", i, got, want)
+		}
+		if !got.Start.Equal(want.Start) || !got.End.Equal(want.End) || !got.Stamp.Equal(want.Stamp) {
+			t.Errorf("event %d: times %s-%s (%s), want %s-%s (%s)", i, got.Start, got.End, got.Stamp, want.Start, want.End, want.Stamp)
+		}
+		if len(got.ExDates) != len(want.ExDates) {
+			t.Fatalf("event %d: exdates %v, want %v", i, got.ExDates, want.ExDates)
+		}
+		for j := range want.ExDates {
+			if !got.ExDates[j].Equal(want.ExDates[j]) {
+				t.Errorf("event %d: exdate %s, want %s", i, got.ExDates[j], want.ExDates[j])
+			}
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		want     []Event
+		wantErrs []int
+	}{
+		{
+			name: "utc times",
+			input: `BEGIN:VCALENDAR
+BEGIN:VEVENT
+UID:a
+SUMMARY:A
+DTSTART:20250601T180000Z
+DTEND:20250601T200000Z
+END:VEVENT
+END:VCALENDAR`,
+			want: []Event{{UID: "a", Summary: "A", Start: time.Date(2025, 6, 1, 18, 0, 0, 0, time.UTC), End: time.Date(2025, 6, 1, 20, 0, 0, 0, time.UTC), TimeZone: "UTC", Line: 2}},
+		},
+		{
+			name: "tzid and duration",
+			input: `BEGIN:VCALENDAR
+BEGIN:VEVENT
+UID:b
+DTSTART;TZID=Europe/Berlin:20250601T180000
+DURATION:PT1H30M
+END:VEVENT
+END:VCALENDAR`,
+			want: []Event{{UID: "b", Start: time.Date(2025, 6, 1, 18, 0, 0, 0, berlin), End: time.Date(2025, 6, 1, 19, 30, 0, 0, berlin), TimeZone: "Europe/Berlin", Line: 2}},
+		},
+		{
+			name: "floating time uses the calendar time zone",
+			input: `BEGIN:VCALENDAR
+X-WR-TIMEZONE:Europe/Berlin
+BEGIN:VEVENT
+UID:c
+DTSTART:20250601T180000
+DTEND:20250601T190000
+END:VEVENT
+END:VCALENDAR`,
+			want: []Event{{UID: "c", Start: time.Date(2025, 6, 1, 18, 0, 0, 0, berlin), End: time.Date(2025, 6, 1, 19, 0, 0, 0, berlin), TimeZone: "Europe/Berlin", Line: 3}},
+		},
+		{
+			name: "all day event lasts a day",
+			input: `BEGIN:VCALENDAR
+BEGIN:VEVENT
+UID:d
+DTSTART;VALUE=DATE:20250601
+END:VEVENT
+END:VCALENDAR`,
+			want: []Event{{UID: "d", Start: time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC), End: time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC), TimeZone: "UTC", Line: 2}},
+		},
+		{
+			name: "folded lines, escapes and exception dates",
+			input: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:e\r\nSUMMARY:Long\r\n  title\\, with comma\r\nDTSTART:20250601T180000Z\r\nDTEND:20250601T190000Z\r\n" +
+				"RRULE:FREQ=DAILY;COUNT=3\r\nEXDATE:20250602T180000Z,20250603T180000Z\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n",
+			want: []Event{{
+				UID: "e", Summary: "Long title, with comma", RRule: "FREQ=DAILY;COUNT=3", TimeZone: "UTC", Line: 2,
+				Start: time.Date(2025, 6, 1, 18, 0, 0, 0, time.UTC), End: time.Date(2025, 6, 1, 19, 0, 0, 0, time.UTC),
+				ExDates: []time.Time{time.Date(2025, 6, 2, 18, 0, 0, 0, time.UTC), time.Date(2025, 6, 3, 18, 0, 0, 0, time.UTC)},
+			}},
+		},
+		{
+			name: "bad events are reported without hiding the others",
+			input: `BEGIN:VCALENDAR
+BEGIN:VEVENT
+UID:missing-start
+END:VEVENT
+BEGIN:VEVENT
+UID:bad-zone
+DTSTART;TZID=Mars/Olympus:20250601T180000
+END:VEVENT
+BEGIN:VEVENT
+UID:ok
+DTSTART:20250601T180000Z
+DTEND:20250601T190000Z
+END:VEVENT
+END:VCALENDAR`,
+			want:     []Event{{UID: "ok", Start: time.Date(2025, 6, 1, 18, 0, 0, 0, time.UTC), End: time.Date(2025, 6, 1, 19, 0, 0, 0, time.UTC), TimeZone: "UTC", Line: 9}},
+			wantErrs: []int{2, 5},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, errs, err := Decode(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(errs) != len(tt.wantErrs) {
+				t.Fatalf("got errors %v, want errors on lines %v", errs, tt.wantErrs)
+			}
+			for i, line := range tt.wantErrs {
+				if errs[i].Line != line {
+					t.Errorf("error %d on line %d, want %d", i, errs[i].Line, line)
+				}
+			}
+
+			if len(events) != len(tt.want) {
+				t.Fatalf("got %d events, want %d", len(events), len(tt.want))
+			}
+			for i, want := range tt.want {
+				got := events[i]
+				if got.UID != want.UID || got.Summary != want.Summary || got.RRule != want.RRule || got.TimeZone != want.TimeZone || got.Line != want.Line {
+					t.Errorf("got %+v, want %+v", got, want)
+				}
+				if !got.Start.Equal(want.Start) || !got.End.Equal(want.End) {
+					t.Errorf("got %s-%s, want %s-%s", got.Start, got.End, want.Start, want.End)
+				}
+				if len(got.ExDates) != len(want.ExDates) {
+					t.Fatalf("got exdates %v, want %v", got.ExDates, want.ExDates)
+				}
+				for j := range want.ExDates {
+					if !got.ExDates[j].Equal(want.ExDates[j]) {
+						t.Errorf("got exdate %s, want %s", got.ExDates[j], want.ExDates[j])
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeMalformed(t *testing.T) {
+	for name, input := range map[string]string{
+		"unterminated calendar": "BEGIN:VCALENDAR\nBEGIN:VEVENT\nEND:VEVENT\n",
+		"mismatched end":        "BEGIN:VCALENDAR\nEND:VEVENT\n",
+		"line without a colon":  "BEGIN:VCALENDAR\nNONSENSE\nEND:VCALENDAR\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, _, err := Decode(strings.NewReader(input)); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{value: "PT1H30M", want: 90 * time.Minute},
+		{value: "P1W", want: 7 * 24 * time.Hour},
+		{value: "P1DT12H", want: 36 * time.Hour},
+		{value: "-PT15M", want: -15 * time.Minute},
+		{value: "PT45S", want: 45 * time.Second},
+		{value: "P", wantErr: true},
+		{value: "PT", wantErr: true},
+		{value: "1H", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseDuration(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseDuration(%q) error = %v, want error %v", tt.value, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseDuration(%q) = %s, want %s", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnescapeText(t *testing.T) {
+	for _, in := range []string{"plain", "a;b,c", `back\slash`, "two\r\nlines\nhere"} {
+		if got := unescapeText(escapeText(in)); got != strings.NewReplacer("\r\n", "\n").Replace(in) {
+			t.Errorf("unescapeText(escapeText(%q)) = %q", in, got)
+		}
+	}
+}
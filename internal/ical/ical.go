@@ -0,0 +1,295 @@
+// Package ical encodes events as RFC 5545 iCalendar objects that calendar
+// apps can import or subscribe to.
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	localFormat = "20060102T150405"
+	utcFormat   = "20060102T150405Z"
+
+	// maxLineOctets is the longest a content line may be before it has to be
+	// folded onto a continuation line.
+	maxLineOctets = 75
+)
+
+// Calendar is a VCALENDAR object holding any number of events.
+type Calendar struct {
+	ProdID string
+	Name   string
+	Events []Event
+}
+
+// Event is a VEVENT. Start and End are presented in TimeZone, which is an
+// IANA zone name; recurring events carry an RRULE value and the start of
+// every cancelled occurrence in ExDates. Decode sets Line to the line of the
+// event's BEGIN:VEVENT.
+type Event struct {
+	UID         string
+	Stamp       time.Time
+	Summary     string
+	Description string
+	Location    string
+	URL         string
+	Start       time.Time
+	End         time.Time
+	TimeZone    string
+	RRule       string
+	ExDates     []time.Time
+	Line        int
+}
+
+// Encode writes the calendar to w, including a VTIMEZONE component for every
+// time zone the events use.
+func Encode(w io.Writer, cal Calendar) error {
+	e := &encoder{w: bufio.NewWriter(w)}
+
+	e.line("BEGIN:VCALENDAR")
+	e.line("VERSION:2.0")
+	e.line("PRODID:" + cal.ProdID)
+	e.line("CALSCALE:GREGORIAN")
+	e.line("METHOD:PUBLISH")
+	if cal.Name != "" {
+		e.line("X-WR-CALNAME:" + escapeText(cal.Name))
+	}
+
+	for _, zone := range zonesOf(cal.Events) {
+		e.timezone(zone)
+	}
+
+	for _, event := range cal.Events {
+		e.event(event)
+	}
+
+	e.line("END:VCALENDAR")
+
+	if e.err != nil {
+		return e.err
+	}
+
+	return e.w.Flush()
+}
+
+type encoder struct {
+	w   *bufio.Writer
+	err error
+}
+
+func (e *encoder) event(event Event) {
+	loc := location(event.TimeZone)
+
+	e.line("BEGIN:VEVENT")
+	e.line("UID:" + escapeText(event.UID))
+	e.line("DTSTAMP:" + event.Stamp.UTC().Format(utcFormat))
+	e.line("DTSTART" + dateTime(event.Start, loc))
+	e.line("DTEND" + dateTime(event.End, loc))
+	if event.RRule != "" {
+		e.line("RRULE:" + strings.TrimPrefix(event.RRule, "RRULE:"))
+	}
+	for _, exdate := range event.ExDates {
+		e.line("EXDATE" + dateTime(exdate, loc))
+	}
+	e.line("SUMMARY:" + escapeText(event.Summary))
+	if event.Description != "" {
+		e.line("DESCRIPTION:" + escapeText(event.Description))
+	}
+	if event.Location != "" {
+		e.line("LOCATION:" + escapeText(event.Location))
+	}
+	if event.URL != "" {
+		e.line("URL:" + event.URL)
+	}
+	e.line("END:VEVENT")
+}
+
+// timezone writes a VTIMEZONE with one observance per UTC offset change in
+// the years around the events. Go does not expose the rules of a zone, so
+// the transitions are found by probing the zone database.
+func (e *encoder) timezone(zone zoneRange) {
+	e.line("BEGIN:VTIMEZONE")
+	e.line("TZID:" + zone.loc.String())
+
+	start := zone.from
+	name, offset := start.Zone()
+	e.observance(start, offset, offset, name, start.IsDST())
+
+	for _, t := range transitions(zone.from, zone.to) {
+		name, to := t.Zone()
+		e.observance(t, offset, to, name, t.IsDST())
+		offset = to
+	}
+
+	e.line("END:VTIMEZONE")
+}
+
+func (e *encoder) observance(onset time.Time, from, to int, name string, dst bool) {
+	kind := "STANDARD"
+	if dst {
+		kind = "DAYLIGHT"
+	}
+
+	e.line("BEGIN:" + kind)
+	// The onset is given in the local time that was in effect before it.
+	e.line("DTSTART:" + onset.In(time.FixedZone("", from)).Format(localFormat))
+	e.line("TZOFFSETFROM:" + formatOffset(from))
+	e.line("TZOFFSETTO:" + formatOffset(to))
+	e.line("TZNAME:" + escapeText(name))
+	e.line("END:" + kind)
+}
+
+// line writes a content line terminated by CRLF, folding it so no physical
+// line exceeds 75 octets without splitting a UTF-8 sequence.
+func (e *encoder) line(s string) {
+	if e.err != nil {
+		return
+	}
+
+	limit := maxLineOctets
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+
+		if _, e.err = e.w.WriteString(s[:cut] + "\r\n "); e.err != nil {
+			return
+		}
+
+		s = s[cut:]
+		// Continuation lines start with a space, which counts towards the limit.
+		limit = maxLineOctets - 1
+	}
+
+	_, e.err = e.w.WriteString(s + "\r\n")
+}
+
+// dateTime formats the parameters and value of a DATE-TIME property, in UTC
+// for UTC events and as local time with a TZID otherwise.
+func dateTime(t time.Time, loc *time.Location) string {
+	if loc == time.UTC {
+		return ":" + t.UTC().Format(utcFormat)
+	}
+
+	return ";TZID=" + loc.String() + ":" + t.In(loc).Format(localFormat)
+}
+
+func location(name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil || loc.String() == "UTC" {
+		return time.UTC
+	}
+
+	return loc
+}
+
+type zoneRange struct {
+	loc      *time.Location
+	from, to time.Time
+}
+
+// zonesOf returns every non-UTC zone used by the events, with a range
+// spanning from the start of the year of the first event to two years after
+// the start of the last one, which covers the series most calendars show.
+func zonesOf(events []Event) []zoneRange {
+	zones := map[string]*zoneRange{}
+
+	for _, event := range events {
+		loc := location(event.TimeZone)
+		if loc == time.UTC {
+			continue
+		}
+
+		start := event.Start.In(loc)
+		from := time.Date(start.Year(), time.January, 1, 0, 0, 0, 0, loc)
+		to := time.Date(start.Year()+2, time.January, 1, 0, 0, 0, 0, loc)
+
+		zone, ok := zones[loc.String()]
+		if !ok {
+			zones[loc.String()] = &zoneRange{loc: loc, from: from, to: to}
+			continue
+		}
+
+		if from.Before(zone.from) {
+			zone.from = from
+		}
+		if to.After(zone.to) {
+			zone.to = to
+		}
+	}
+
+	result := make([]zoneRange, 0, len(zones))
+	for _, zone := range zones {
+		result = append(result, *zone)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].loc.String() < result[j].loc.String()
+	})
+
+	return result
+}
+
+// transitions returns the instants in [from, to) at which the UTC offset of
+// the zone changes. It probes the zone daily and narrows every change down to
+// the second.
+func transitions(from, to time.Time) []time.Time {
+	var result []time.Time
+
+	_, offset := from.Zone()
+	for day := from; day.Before(to); day = day.Add(24 * time.Hour) {
+		next := day.Add(24 * time.Hour)
+		if _, nextOffset := next.Zone(); nextOffset == offset {
+			continue
+		}
+
+		low, high := day, next
+		for high.Sub(low) > time.Second {
+			mid := low.Add(high.Sub(low) / 2)
+			if _, o := mid.Zone(); o == offset {
+				low = mid
+			} else {
+				high = mid
+			}
+		}
+
+		result = append(result, high)
+		_, offset = high.Zone()
+	}
+
+	return result
+}
+
+func formatOffset(seconds int) string {
+	sign := '+'
+	if seconds < 0 {
+		sign = '-'
+		seconds = -seconds
+	}
+
+	hours, minutes := seconds/3600, seconds%3600/60
+	if rest := seconds % 60; rest != 0 {
+		return fmt.Sprintf("%c%02d%02d%02d", sign, hours, minutes, rest)
+	}
+
+	return fmt.Sprintf("%c%02d%02d", sign, hours, minutes)
+}
+
+// escapeText escapes a TEXT value as required by RFC 5545 section 3.3.11.
+func escapeText(s string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+		"\r", `\n`,
+	).Replace(s)
+}
@@ -0,0 +1,91 @@
+package ical
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata"
+	"unicode/utf8"
+)
+
+func TestEncodeTimeZones(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	err = Encode(&buf, Calendar{ProdID: "-//Test//Events//EN", Events: []Event{{
+		UID:      "1",
+		Summary:  "Meetup",
+		Start:    time.Date(2025, 7, 4, 19, 0, 0, 0, newYork),
+		End:      time.Date(2025, 7, 4, 21, 0, 0, 0, newYork),
+		TimeZone: "America/New_York",
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.String()
+	for _, want := range []string{
+		"BEGIN:VTIMEZONE\r\nTZID:America/New_York\r\n",
+		"DTSTART;TZID=America/New_York:20250704T190000\r\n",
+		"DTEND;TZID=America/New_York:20250704T210000\r\n",
+		// Daylight saving time starts on 9 March 2025 at 02:00 local time.
+		"BEGIN:DAYLIGHT\r\nDTSTART:20250309T020000\r\nTZOFFSETFROM:-0500\r\nTZOFFSETTO:-0400\r\nTZNAME:EDT\r\n",
+		"BEGIN:STANDARD\r\nDTSTART:20251102T020000\r\nTZOFFSETFROM:-0400\r\nTZOFFSETTO:-0500\r\nTZNAME:EST\r\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output lacks %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestEncodeFoldsLines(t *testing.T) {
+	var buf bytes.Buffer
+	err := Encode(&buf, Calendar{ProdID: "-//Test//Events//EN", Events: []Event{{
+		UID:         "1",
+		Summary:     strings.Repeat("Ünïcödé summary that is long enough to be folded ", 3),
+		Description: strings.Repeat("ü", 200),
+		Start:       time.Date(2025, 6, 1, 18, 0, 0, 0, time.UTC),
+		End:         time.Date(2025, 6, 1, 20, 0, 0, 0, time.UTC),
+		TimeZone:    "UTC",
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n")
+	folded := 0
+	for i, line := range lines {
+		if len(line) > maxLineOctets {
+			t.Errorf("line %d is %d octets long", i+1, len(line))
+		}
+		if !utf8.ValidString(line) {
+			t.Errorf("line %d splits a UTF-8 sequence", i+1)
+		}
+		if strings.HasPrefix(line, " ") {
+			folded++
+		}
+	}
+
+	if folded == 0 {
+		t.Errorf("no line was folded:\n%s", buf.String())
+	}
+}
+
+func TestEscapeText(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"plain", "plain"},
+		{"a;b,c", `a\;b\,c`},
+		{`back\slash`, `back\\slash`},
+		{"two\r\nlines\nhere", `two\nlines\nhere`},
+	}
+
+	for _, tt := range tests {
+		if got := escapeText(tt.in); got != tt.want {
+			t.Errorf("escapeText(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,411 @@
+// Package jobs runs background work queued in the jobs table with a pool of
+// workers. Jobs survive restarts, are retried with exponential backoff and
+// are handed to one worker at a time through a lease that runs out if the
+// worker stops, so a job runs at least once, and may run more than once.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"rest-api-event-app/internal/database"
+	"sync"
+	"time"
+)
+
+// Handler runs a job. Returning an error retries the job after a backoff,
+// unless it is wrapped with Permanent, or with RetryAfter to pick the delay. ctx is cancelled when the pool stops waiting for
+// the job while draining.
+type Handler func(ctx context.Context, job *database.Job) error
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks an error a retry cannot fix, so the job is marked dead
+// straight away.
+func Permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+type retryAfterError struct {
+	err   error
+	delay time.Duration
+}
+
+func (e *retryAfterError) Error() string { return e.err.Error() }
+func (e *retryAfterError) Unwrap() error { return e.err }
+
+// RetryAfter retries the job after delay instead of the pool's backoff, for
+// handlers that know better when to try again. It still counts an attempt.
+func RetryAfter(err error, delay time.Duration) error {
+	return &retryAfterError{err: err, delay: delay}
+}
+
+// Store keeps the jobs of a pool. It is implemented by database.JobModel;
+// see there for what each method does.
+type Store interface {
+	Enqueue(ctx context.Context, job *database.Job) (*database.Job, error)
+	Lease(ctx context.Context, kinds []string, now time.Time, limit int, lease time.Duration) ([]*database.Job, error)
+	Extend(ctx context.Context, job *database.Job, lockedUntil time.Time) error
+	Complete(ctx context.Context, job *database.Job, now time.Time) error
+	Retry(ctx context.Context, job *database.Job, reason string, runAt time.Time) error
+	Kill(ctx context.Context, job *database.Job, reason string, now time.Time) error
+	Release(ctx context.Context, job *database.Job) error
+	DeleteFinished(ctx context.Context, before time.Time) (int, error)
+}
+
+// Options of an enqueued job. The zero value runs the job right away with
+// the pool's default number of attempts.
+type Options struct {
+	UniqueKey   string
+	RunAt       time.Time
+	MaxAttempts int
+}
+
+// Config of a pool. Zero values fall back to the defaults below.
+type Config struct {
+	// Workers is the number of jobs run at the same time.
+	Workers int
+	// Lease is how long a job is held before another worker may take it
+	// over. Running jobs keep extending their lease.
+	Lease time.Duration
+	// PollInterval is how often the pool looks for due jobs when idle.
+	PollInterval time.Duration
+	// Backoff is the delay before the first retry, doubling with every
+	// further attempt up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// MaxAttempts is the number of attempts of jobs enqueued without one.
+	MaxAttempts int
+	// DrainTimeout is how long Run waits for running jobs once its context
+	// is cancelled, before cancelling theirs.
+	DrainTimeout time.Duration
+	// Retention is how long succeeded jobs are kept.
+	Retention time.Duration
+}
+
+func (c *Config) setDefaults() {
+	if c.Workers <= 0 {
+		c.Workers = 4
+	}
+	if c.Lease <= 0 {
+		c.Lease = 5 * time.Minute
+	}
+	if c.PollInterval <= 0 {
+		c.PollInterval = time.Second
+	}
+	if c.Backoff <= 0 {
+		c.Backoff = 10 * time.Second
+	}
+	if c.MaxBackoff <= 0 {
+		c.MaxBackoff = time.Hour
+	}
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = 10
+	}
+	if c.DrainTimeout <= 0 {
+		c.DrainTimeout = 30 * time.Second
+	}
+	if c.Retention <= 0 {
+		c.Retention = 7 * 24 * time.Hour
+	}
+}
+
+// Pool runs the jobs of the kinds it has handlers for.
+type Pool struct {
+	jobs     Store
+	config   Config
+	handlers map[string]Handler
+	periodic []*periodicJob
+	wake     chan struct{}
+}
+
+// periodicJob is a kind of job queued once every interval. next is the
+// start of the next interval to queue a job for.
+type periodicJob struct {
+	kind     string
+	interval time.Duration
+	next     time.Time
+}
+
+func NewPool(jobs Store, config Config) *Pool {
+	config.setDefaults()
+
+	return &Pool{
+		jobs:     jobs,
+		config:   config,
+		handlers: map[string]Handler{},
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// Handle registers the handler of a kind of job. It must be called before
+// Run.
+func (p *Pool) Handle(kind string, handler Handler) {
+	p.handlers[kind] = handler
+}
+
+// Every makes Run queue a job of the given kind, with an empty payload, once
+// every interval, starting with the current one. The job of an interval has
+// a unique key, so it is queued once however many pools run. It must be
+// called before Run, and the kind needs a handler.
+func (p *Pool) Every(kind string, interval time.Duration) {
+	p.periodic = append(p.periodic, &periodicJob{kind: kind, interval: interval})
+}
+
+// Enqueue queues a job of the given kind with payload encoded as JSON. It
+// fails with database.ErrDuplicateJob when opts.UniqueKey is taken.
+func (p *Pool) Enqueue(ctx context.Context, kind string, payload any, opts Options) (*database.Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	job := &database.Job{
+		Kind:        kind,
+		Payload:     data,
+		RunAt:       opts.RunAt,
+		MaxAttempts: opts.MaxAttempts,
+	}
+	if job.RunAt.IsZero() {
+		job.RunAt = time.Now()
+	}
+	if job.MaxAttempts <= 0 {
+		job.MaxAttempts = p.config.MaxAttempts
+	}
+	if opts.UniqueKey != "" {
+		job.UniqueKey = &opts.UniqueKey
+	}
+
+	job, err = p.jobs.Enqueue(ctx, job)
+	if err != nil {
+		return nil, err
+	}
+
+	if !job.RunAt.After(time.Now()) {
+		p.Notify()
+	}
+
+	return job, nil
+}
+
+// NewJob returns a job of the given kind that is due right away and has the
+// pool's default number of attempts, for models that queue jobs in their own
+// transactions. Call Notify once the transaction is committed.
+func (p *Pool) NewJob(kind string) database.Job {
+	return database.Job{Kind: kind, RunAt: time.Now().UTC(), MaxAttempts: p.config.MaxAttempts}
+}
+
+// Notify makes the pool look for due jobs right away rather than at the
+// next poll.
+func (p *Pool) Notify() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run leases and runs due jobs until ctx is cancelled. It then stops
+// leasing and drains: running jobs get DrainTimeout to finish, after which
+// their context is cancelled and those still running are given back to the
+// queue. Run returns once every worker has stopped.
+func (p *Pool) Run(ctx context.Context) {
+	kinds := make([]string, 0, len(p.handlers))
+	for kind := range p.handlers {
+		kinds = append(kinds, kind)
+	}
+
+	// Jobs run with their own context, cancelled only once draining times
+	// out, so a shutdown does not interrupt jobs that can finish in time.
+	jobCtx, cancelJobs := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelJobs()
+
+	slots := make(chan struct{}, p.config.Workers)
+	var wg sync.WaitGroup
+
+	ticker := time.NewTicker(p.config.PollInterval)
+	defer ticker.Stop()
+
+	lastPurge := time.Time{}
+	for ctx.Err() == nil {
+		if time.Since(lastPurge) > time.Hour {
+			lastPurge = time.Now()
+			p.purge(ctx)
+		}
+
+		p.schedule(ctx, time.Now().UTC())
+
+		free := p.config.Workers - len(slots)
+		if free > 0 {
+			jobs, err := p.jobs.Lease(ctx, kinds, time.Now().UTC(), free, p.config.Lease)
+			if err != nil && ctx.Err() == nil {
+				log.Printf("Leasing jobs failed: %s", err)
+			}
+
+			for _, job := range jobs {
+				slots <- struct{}{}
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					defer func() { <-slots }()
+					p.run(jobCtx, job)
+				}()
+			}
+
+			// A full batch means more jobs may be due; ask again as soon as
+			// a worker is free.
+			if len(jobs) == free {
+				select {
+				case <-ctx.Done():
+				case slots <- struct{}{}:
+					<-slots
+				}
+				continue
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-ticker.C:
+		case <-p.wake:
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(p.config.DrainTimeout):
+		log.Printf("Jobs still running after %s; cancelling them", p.config.DrainTimeout)
+		cancelJobs()
+		<-done
+	}
+}
+
+// run runs a leased job, keeping its lease alive meanwhile, and records the
+// outcome.
+func (p *Pool) run(ctx context.Context, job *database.Job) {
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var heartbeat sync.WaitGroup
+	heartbeat.Add(1)
+	go func() {
+		defer heartbeat.Done()
+		p.keepLeased(runCtx, job)
+	}()
+
+	err := p.call(runCtx, job)
+	cancel()
+	heartbeat.Wait()
+
+	// The outcome is recorded even when the job was cancelled by draining.
+	recordCtx := context.WithoutCancel(ctx)
+	now := time.Now().UTC()
+
+	var recordErr error
+	var permanent *permanentError
+	var retryAfter *retryAfterError
+	switch {
+	case err == nil:
+		recordErr = p.jobs.Complete(recordCtx, job, now)
+	case ctx.Err() != nil:
+		recordErr = p.jobs.Release(recordCtx, job)
+	case errors.As(err, &permanent), job.Attempts >= job.MaxAttempts:
+		log.Printf("Job %d (%s) failed for good after %d attempts: %s", job.ID, job.Kind, job.Attempts, err)
+		recordErr = p.jobs.Kill(recordCtx, job, err.Error(), now)
+	case errors.As(err, &retryAfter):
+		recordErr = p.jobs.Retry(recordCtx, job, err.Error(), now.Add(retryAfter.delay))
+	default:
+		recordErr = p.jobs.Retry(recordCtx, job, err.Error(), now.Add(p.backoff(job.Attempts)))
+	}
+
+	if recordErr != nil {
+		log.Printf("Recording the outcome of job %d (%s) failed: %s", job.ID, job.Kind, recordErr)
+	}
+}
+
+// call runs the handler of a job, turning a panic into an error.
+func (p *Pool) call(ctx context.Context, job *database.Job) (err error) {
+	handler, ok := p.handlers[job.Kind]
+	if !ok {
+		return Permanent(fmt.Errorf("no handler for jobs of kind %q", job.Kind))
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+
+	return handler(ctx, job)
+}
+
+// keepLeased extends the lease of a running job until ctx is cancelled.
+func (p *Pool) keepLeased(ctx context.Context, job *database.Job) {
+	ticker := time.NewTicker(p.config.Lease / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := p.jobs.Extend(ctx, job, time.Now().UTC().Add(p.config.Lease)); err != nil && ctx.Err() == nil {
+				log.Printf("Extending the lease of job %d (%s) failed: %s", job.ID, job.Kind, err)
+			}
+		}
+	}
+}
+
+// backoff returns the delay before retrying a job that has failed attempts
+// times, with up to a quarter of jitter so jobs failing together do not
+// retry together.
+func (p *Pool) backoff(attempts int) time.Duration {
+	delay := p.config.Backoff
+	for i := 1; i < attempts && delay < p.config.MaxBackoff; i++ {
+		delay *= 2
+	}
+	delay = min(delay, p.config.MaxBackoff)
+
+	return delay - time.Duration(rand.Int64N(int64(delay)/4+1))
+}
+
+// schedule queues the jobs of the periodic kinds whose interval has begun.
+func (p *Pool) schedule(ctx context.Context, now time.Time) {
+	for _, periodic := range p.periodic {
+		if now.Before(periodic.next) {
+			continue
+		}
+
+		start := now.Truncate(periodic.interval)
+		key := fmt.Sprintf("%s@%d", periodic.kind, start.Unix())
+
+		_, err := p.Enqueue(ctx, periodic.kind, struct{}{}, Options{UniqueKey: key, RunAt: start})
+		if err != nil && !errors.Is(err, database.ErrDuplicateJob) {
+			if ctx.Err() == nil {
+				log.Printf("Queueing job %s failed: %s", key, err)
+			}
+			continue
+		}
+
+		periodic.next = start.Add(periodic.interval)
+	}
+}
+
+func (p *Pool) purge(ctx context.Context) {
+	if _, err := p.jobs.DeleteFinished(ctx, time.Now().UTC().Add(-p.config.Retention)); err != nil && ctx.Err() == nil {
+		log.Printf("Purging finished jobs failed: %s", err)
+	}
+}
@@ -0,0 +1,440 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"rest-api-event-app/internal/database"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryStore keeps jobs in memory the way database.JobModel keeps them in
+// the jobs table, closely enough to drive a pool in tests.
+type memoryStore struct {
+	mu     sync.Mutex
+	jobs   []*database.Job
+	tokens int
+}
+
+func (s *memoryStore) Enqueue(ctx context.Context, job *database.Job) (*database.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if job.UniqueKey != nil {
+		for _, other := range s.jobs {
+			if other.UniqueKey != nil && *other.UniqueKey == *job.UniqueKey {
+				return nil, database.ErrDuplicateJob
+			}
+		}
+	}
+
+	stored := *job
+	stored.ID = int64(len(s.jobs) + 1)
+	stored.Status = database.JobStatusQueued
+	s.jobs = append(s.jobs, &stored)
+
+	copied := stored
+	return &copied, nil
+}
+
+func (s *memoryStore) Lease(ctx context.Context, kinds []string, now time.Time, limit int, lease time.Duration) ([]*database.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens++
+	token := fmt.Sprint(s.tokens)
+	lockedUntil := now.Add(lease)
+
+	var leased []*database.Job
+	for _, job := range s.jobs {
+		due := job.Status == database.JobStatusQueued && !job.RunAt.After(now) ||
+			job.Status == database.JobStatusRunning && !job.LockedUntil.After(now)
+		if !due || len(leased) == limit {
+			continue
+		}
+
+		job.Status = database.JobStatusRunning
+		job.Attempts++
+		job.LeaseToken = token
+		job.LockedUntil = &lockedUntil
+
+		copied := *job
+		leased = append(leased, &copied)
+	}
+
+	return leased, nil
+}
+
+// update applies fn to the stored copy of a leased job, as long as the
+// lease was not taken over.
+func (s *memoryStore) update(job *database.Job, fn func(stored *database.Job)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, stored := range s.jobs {
+		if stored.ID == job.ID {
+			if stored.LeaseToken != job.LeaseToken {
+				return database.ErrJobLeaseLost
+			}
+			fn(stored)
+			return nil
+		}
+	}
+
+	return database.ErrJobNotFound
+}
+
+func (s *memoryStore) Extend(ctx context.Context, job *database.Job, lockedUntil time.Time) error {
+	return s.update(job, func(stored *database.Job) { stored.LockedUntil = &lockedUntil })
+}
+
+func (s *memoryStore) Complete(ctx context.Context, job *database.Job, now time.Time) error {
+	return s.update(job, func(stored *database.Job) {
+		stored.Status = database.JobStatusSucceeded
+		stored.LeaseToken = ""
+		stored.FinishedAt = &now
+	})
+}
+
+func (s *memoryStore) Retry(ctx context.Context, job *database.Job, reason string, runAt time.Time) error {
+	return s.update(job, func(stored *database.Job) {
+		stored.Status = database.JobStatusQueued
+		stored.LeaseToken = ""
+		stored.LastError = reason
+		stored.RunAt = runAt
+	})
+}
+
+func (s *memoryStore) Kill(ctx context.Context, job *database.Job, reason string, now time.Time) error {
+	return s.update(job, func(stored *database.Job) {
+		stored.Status = database.JobStatusDead
+		stored.LeaseToken = ""
+		stored.LastError = reason
+		stored.FinishedAt = &now
+	})
+}
+
+func (s *memoryStore) Release(ctx context.Context, job *database.Job) error {
+	return s.update(job, func(stored *database.Job) {
+		stored.Status = database.JobStatusQueued
+		stored.Attempts--
+		stored.LeaseToken = ""
+	})
+}
+
+func (s *memoryStore) DeleteFinished(ctx context.Context, before time.Time) (int, error) {
+	return 0, nil
+}
+
+func (s *memoryStore) get(id int64) database.Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return *s.jobs[id-1]
+}
+
+func (s *memoryStore) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.jobs)
+}
+
+// runOnce leases every due job of the pool and runs it to completion.
+func runOnce(t *testing.T, pool *Pool, store *memoryStore, now time.Time) {
+	t.Helper()
+
+	kinds := make([]string, 0, len(pool.handlers))
+	for kind := range pool.handlers {
+		kinds = append(kinds, kind)
+	}
+
+	leased, err := store.Lease(context.Background(), kinds, now, 100, pool.config.Lease)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, job := range leased {
+		pool.run(context.Background(), job)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	pool := NewPool(&memoryStore{}, Config{Backoff: 10 * time.Second, MaxBackoff: time.Minute})
+
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 10 * time.Second},
+		{2, 20 * time.Second},
+		{3, 40 * time.Second},
+		{4, time.Minute},
+		{20, time.Minute},
+	}
+
+	for _, tt := range tests {
+		for range 50 {
+			// Up to a quarter of the delay is taken off as jitter.
+			got := pool.backoff(tt.attempts)
+			if got > tt.want || got < tt.want-tt.want/4 {
+				t.Fatalf("backoff(%d) = %s, want between %s and %s", tt.attempts, got, tt.want-tt.want/4, tt.want)
+			}
+		}
+	}
+}
+
+func TestRunRetriesWithBackoff(t *testing.T) {
+	store := &memoryStore{}
+	pool := NewPool(store, Config{Backoff: time.Minute, MaxBackoff: time.Hour})
+
+	failure := errors.New("receiver unavailable")
+	pool.Handle("flaky", func(ctx context.Context, job *database.Job) error {
+		return failure
+	})
+
+	job, err := pool.Enqueue(context.Background(), "flaky", nil, Options{MaxAttempts: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().UTC()
+	for attempt := 1; attempt < 3; attempt++ {
+		// The pool schedules retries from the wall clock, not the lease time.
+		ranAt := time.Now().UTC()
+		runOnce(t, pool, store, now)
+
+		stored := store.get(job.ID)
+		if stored.Status != database.JobStatusQueued || stored.Attempts != attempt || stored.LastError != failure.Error() {
+			t.Fatalf("after attempt %d: %+v, want it queued for a retry", attempt, stored)
+		}
+
+		want := time.Minute << (attempt - 1)
+		if delay := stored.RunAt.Sub(ranAt); delay > want+time.Second || delay < want-want/4 {
+			t.Fatalf("retry %d after %s, want about %s", attempt, delay, want)
+		}
+
+		// Nothing runs before the retry is due.
+		runOnce(t, pool, store, stored.RunAt.Add(-time.Second))
+		if store.get(job.ID).Attempts != attempt {
+			t.Fatalf("job ran again before its retry was due")
+		}
+
+		now = stored.RunAt
+	}
+
+	runOnce(t, pool, store, now)
+	if stored := store.get(job.ID); stored.Status != database.JobStatusDead || stored.Attempts != 3 {
+		t.Errorf("after the last attempt: %+v, want it dead", stored)
+	}
+}
+
+func TestRunHandlerOutcomes(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus string
+		wantDelay  time.Duration
+	}{
+		{"success", nil, database.JobStatusSucceeded, 0},
+		{"permanent failure", Permanent(errors.New("bad payload")), database.JobStatusDead, 0},
+		{"retry after a delay", RetryAfter(errors.New("slow down"), 5*time.Minute), database.JobStatusQueued, 5 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &memoryStore{}
+			pool := NewPool(store, Config{})
+			pool.Handle("job", func(ctx context.Context, job *database.Job) error {
+				return tt.err
+			})
+
+			job, err := pool.Enqueue(context.Background(), "job", nil, Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			now := time.Now().UTC()
+			runOnce(t, pool, store, now)
+
+			stored := store.get(job.ID)
+			if stored.Status != tt.wantStatus {
+				t.Fatalf("status = %s, want %s", stored.Status, tt.wantStatus)
+			}
+
+			if tt.wantDelay > 0 {
+				if delay := stored.RunAt.Sub(now); delay < tt.wantDelay || delay > tt.wantDelay+time.Second {
+					t.Errorf("retried after %s, want %s", delay, tt.wantDelay)
+				}
+			}
+		})
+	}
+}
+
+func TestRunRecoversFromPanics(t *testing.T) {
+	store := &memoryStore{}
+	pool := NewPool(store, Config{})
+	pool.Handle("panics", func(ctx context.Context, job *database.Job) error {
+		panic("boom")
+	})
+
+	job, err := pool.Enqueue(context.Background(), "panics", nil, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runOnce(t, pool, store, time.Now().UTC())
+
+	if stored := store.get(job.ID); stored.Status != database.JobStatusQueued || stored.LastError != "job panicked: boom" {
+		t.Errorf("after a panic: %+v, want it queued for a retry", stored)
+	}
+}
+
+func TestLeaseExpiry(t *testing.T) {
+	store := &memoryStore{}
+	pool := NewPool(store, Config{Lease: time.Minute})
+
+	var runs int
+	pool.Handle("job", func(ctx context.Context, job *database.Job) error {
+		runs++
+		return nil
+	})
+
+	job, err := pool.Enqueue(context.Background(), "job", nil, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A worker leases the job and stops without recording an outcome.
+	now := time.Now().UTC()
+	abandoned, err := store.Lease(context.Background(), []string{"job"}, now, 1, time.Minute)
+	if err != nil || len(abandoned) != 1 {
+		t.Fatalf("leasing the job: %v, %v", abandoned, err)
+	}
+
+	runOnce(t, pool, store, now.Add(30*time.Second))
+	if runs != 0 {
+		t.Fatal("job was taken over before its lease ran out")
+	}
+
+	runOnce(t, pool, store, now.Add(time.Minute))
+	if runs != 1 {
+		t.Fatal("job was not taken over once its lease ran out")
+	}
+
+	stored := store.get(job.ID)
+	if stored.Status != database.JobStatusSucceeded || stored.Attempts != 2 {
+		t.Errorf("after the takeover: %+v, want it succeeded on the second attempt", stored)
+	}
+
+	// The worker that lost the lease can no longer record an outcome.
+	if err := store.Complete(context.Background(), abandoned[0], now); !errors.Is(err, database.ErrJobLeaseLost) {
+		t.Errorf("completing with a lost lease: got %v, want ErrJobLeaseLost", err)
+	}
+}
+
+func TestEnqueueUniqueKey(t *testing.T) {
+	store := &memoryStore{}
+	pool := NewPool(store, Config{})
+
+	if _, err := pool.Enqueue(context.Background(), "job", nil, Options{UniqueKey: "once"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := pool.Enqueue(context.Background(), "job", nil, Options{UniqueKey: "once"}); !errors.Is(err, database.ErrDuplicateJob) {
+		t.Errorf("second job with the same key: got %v, want ErrDuplicateJob", err)
+	}
+
+	if _, err := pool.Enqueue(context.Background(), "job", nil, Options{UniqueKey: "twice"}); err != nil {
+		t.Errorf("job with another key: %s", err)
+	}
+}
+
+func TestScheduleQueuesOncePerInterval(t *testing.T) {
+	store := &memoryStore{}
+	pool := NewPool(store, Config{})
+	pool.Every("periodic", time.Hour)
+
+	start := time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC)
+	pool.schedule(context.Background(), start.Add(5*time.Minute))
+	pool.schedule(context.Background(), start.Add(50*time.Minute))
+
+	// Another pool, as on a second server, shares the interval's key.
+	other := NewPool(store, Config{})
+	other.Every("periodic", time.Hour)
+	other.schedule(context.Background(), start.Add(20*time.Minute))
+
+	if n := store.len(); n != 1 {
+		t.Fatalf("queued %d jobs in one interval, want 1", n)
+	}
+
+	job := store.get(1)
+	if want := fmt.Sprintf("periodic@%d", start.Unix()); job.UniqueKey == nil || *job.UniqueKey != want {
+		t.Errorf("unique key = %v, want %s", job.UniqueKey, want)
+	}
+
+	pool.schedule(context.Background(), start.Add(time.Hour))
+	if n := store.len(); n != 2 {
+		t.Errorf("queued %d jobs after the next interval began, want 2", n)
+	}
+}
+
+func TestRunDrains(t *testing.T) {
+	tests := []struct {
+		name       string
+		finishIn   time.Duration
+		wantStatus string
+	}{
+		{"jobs finishing in time complete", 20 * time.Millisecond, database.JobStatusSucceeded},
+		{"jobs outlasting the drain are given back", time.Hour, database.JobStatusQueued},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &memoryStore{}
+			pool := NewPool(store, Config{PollInterval: 10 * time.Millisecond, DrainTimeout: 200 * time.Millisecond})
+
+			started := make(chan struct{})
+			pool.Handle("slow", func(ctx context.Context, job *database.Job) error {
+				close(started)
+				select {
+				case <-time.After(tt.finishIn):
+					return nil
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			})
+
+			job, err := pool.Enqueue(context.Background(), "slow", nil, Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			go func() {
+				pool.Run(ctx)
+				close(done)
+			}()
+
+			<-started
+			cancel()
+
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("Run did not return after draining")
+			}
+
+			stored := store.get(job.ID)
+			if stored.Status != tt.wantStatus {
+				t.Errorf("status = %s, want %s", stored.Status, tt.wantStatus)
+			}
+			if tt.wantStatus == database.JobStatusQueued && stored.Attempts != 0 {
+				t.Errorf("attempts = %d, want the interrupted attempt not to count", stored.Attempts)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+// Package notify delivers notifications to users through channels such as
+// email and the in-app inbox.
+package notify
+
+import (
+	"context"
+	"fmt"
+	"rest-api-event-app/internal/database"
+	"rest-api-event-app/internal/mailer"
+)
+
+// Channels notifications can be sent through.
+const (
+	ChannelEmail = "email"
+	ChannelInApp = "in_app"
+)
+
+// Kinds of notifications.
+const (
+	KindEventReminder    = "event.reminder"
+	KindEventRescheduled = "event.rescheduled"
+	KindEventMoved       = "event.moved"
+	KindEventUpdated     = "event.updated"
+	KindEventCancelled   = "event.cancelled"
+	KindAttendeeAdded    = "attendee.added"
+	KindOrganizerInvited = "organizer.invited"
+)
+
+// Notification is a message for a single user.
+type Notification struct {
+	User    *database.User
+	Kind    string
+	Title   string
+	Body    string
+	EventId int
+}
+
+// Notifier sends notifications through one channel. Implementations must be
+// safe for concurrent use.
+type Notifier interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+// EmailNotifier sends notifications as emails.
+type EmailNotifier struct {
+	Mailer mailer.Mailer
+}
+
+func (e *EmailNotifier) Notify(ctx context.Context, n Notification) error {
+	return e.Mailer.Send(ctx, mailer.Message{
+		To:      n.User.Email,
+		Subject: n.Title,
+		Body:    fmt.Sprintf("Hi %s,\n\n%s\n", n.User.Name, n.Body),
+	})
+}
+
+// InAppNotifier adds notifications to the user's inbox.
+type InAppNotifier struct {
+	Notifications *database.NotificationModel
+}
+
+func (i *InAppNotifier) Notify(ctx context.Context, n Notification) error {
+	notification := &database.Notification{
+		UserId: n.User.ID,
+		Kind:   n.Kind,
+		Title:  n.Title,
+		Body:   n.Body,
+	}
+	if n.EventId != 0 {
+		notification.EventId = &n.EventId
+	}
+
+	_, err := i.Notifications.Insert(ctx, notification)
+	return err
+}
@@ -0,0 +1,174 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrDeclined       = errors.New("payment was declined")
+	ErrChargeNotFound = errors.New("charge not found")
+	ErrRefundTooLarge = errors.New("refund exceeds the amount left on the charge")
+)
+
+// ChargeRequest asks a provider to take a payment. Amount is in the minor
+// unit of Currency, for example cents for USD. Source is the payment method
+// collected by the client, such as a card token. Requests with the same
+// IdempotencyKey are only charged once.
+type ChargeRequest struct {
+	Amount         int64
+	Currency       string
+	Source         string
+	Description    string
+	IdempotencyKey string
+}
+
+type Charge struct {
+	ID       string
+	Amount   int64
+	Currency string
+	Refunded int64
+}
+
+// RefundRequest gives back part or all of a charge. Requests with the same
+// IdempotencyKey are only refunded once.
+type RefundRequest struct {
+	ChargeID       string
+	Amount         int64
+	IdempotencyKey string
+}
+
+type Refund struct {
+	ID       string
+	ChargeID string
+	Amount   int64
+}
+
+// PaymentProvider takes and refunds payments. Implementations must be safe
+// for concurrent use.
+type PaymentProvider interface {
+	Charge(ctx context.Context, req ChargeRequest) (*Charge, error)
+	Refund(ctx context.Context, req RefundRequest) (*Refund, error)
+	// FindCharge returns the successful charge made with an idempotency
+	// key, or ErrChargeNotFound, so a payment interrupted between charging
+	// and recording the charge can be reconciled.
+	FindCharge(ctx context.Context, idempotencyKey string) (*Charge, error)
+}
+
+// DeclinedSource is the payment method FakeProvider always declines.
+const DeclinedSource = "tok_declined"
+
+// FakeProvider settles payments in memory without talking to anyone. Every
+// charge succeeds unless its source is DeclinedSource. It is meant for tests
+// and local development, and is only used when asked for by name.
+type FakeProvider struct {
+	mu      sync.Mutex
+	seq     int
+	charges map[string]*Charge
+	refunds []Refund
+	keys    map[string]any
+}
+
+func NewFakeProvider() *FakeProvider {
+	return &FakeProvider{
+		charges: map[string]*Charge{},
+		keys:    map[string]any{},
+	}
+}
+
+func (p *FakeProvider) Charge(ctx context.Context, req ChargeRequest) (*Charge, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if previous, ok := p.keys[req.IdempotencyKey].(*Charge); ok && req.IdempotencyKey != "" {
+		charge := *previous
+		return &charge, nil
+	}
+
+	if req.Amount <= 0 {
+		return nil, fmt.Errorf("invalid amount %d", req.Amount)
+	}
+
+	if strings.TrimSpace(req.Source) == "" || req.Source == DeclinedSource {
+		return nil, ErrDeclined
+	}
+
+	p.seq++
+	charge := &Charge{ID: fmt.Sprintf("ch_fake_%d", p.seq), Amount: req.Amount, Currency: req.Currency}
+	p.charges[charge.ID] = charge
+	if req.IdempotencyKey != "" {
+		p.keys[req.IdempotencyKey] = charge
+	}
+
+	result := *charge
+	return &result, nil
+}
+
+func (p *FakeProvider) FindCharge(ctx context.Context, idempotencyKey string) (*Charge, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	charge, ok := p.keys[idempotencyKey].(*Charge)
+	if !ok || idempotencyKey == "" {
+		return nil, ErrChargeNotFound
+	}
+
+	result := *charge
+	return &result, nil
+}
+
+func (p *FakeProvider) Refund(ctx context.Context, req RefundRequest) (*Refund, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if previous, ok := p.keys[req.IdempotencyKey].(*Refund); ok && req.IdempotencyKey != "" {
+		refund := *previous
+		return &refund, nil
+	}
+
+	charge, ok := p.charges[req.ChargeID]
+	if !ok {
+		return nil, ErrChargeNotFound
+	}
+
+	if req.Amount <= 0 || req.Amount > charge.Amount-charge.Refunded {
+		return nil, ErrRefundTooLarge
+	}
+
+	p.seq++
+	charge.Refunded += req.Amount
+	refund := Refund{ID: fmt.Sprintf("re_fake_%d", p.seq), ChargeID: charge.ID, Amount: req.Amount}
+	p.refunds = append(p.refunds, refund)
+	if req.IdempotencyKey != "" {
+		p.keys[req.IdempotencyKey] = &refund
+	}
+
+	result := refund
+	return &result, nil
+}
+
+// Charges returns a copy of the charges taken so far.
+func (p *FakeProvider) Charges() []Charge {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	charges := make([]Charge, 0, len(p.charges))
+	for i := 1; i <= p.seq; i++ {
+		if charge, ok := p.charges[fmt.Sprintf("ch_fake_%d", i)]; ok {
+			charges = append(charges, *charge)
+		}
+	}
+
+	return charges
+}
+
+// Refunds returns a copy of the refunds made so far.
+func (p *FakeProvider) Refunds() []Refund {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]Refund(nil), p.refunds...)
+}
@@ -0,0 +1,75 @@
+// Package testdb gives tests a MySQL database to run against.
+//
+// Tests that need MySQL run against the database named by
+// TEST_DATABASE_DSN, for example
+//
+//	root:secret@tcp(localhost:3306)/events_test?multiStatements=true&parseTime=true&loc=UTC
+//
+// and are skipped when it is not set. The database is migrated once per test
+// binary and shared by every test, so tests create the users and events they
+// work on, named with UniqueName, rather than expecting empty tables.
+package testdb
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"rest-api-event-app/cmd/migrate/migrations"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/golang-migrate/migrate/v4"
+)
+
+var (
+	once sync.Once
+	conn *sql.DB
+	err  error
+	seq  atomic.Int64
+)
+
+// Open returns the migrated test database, skipping the test when
+// TEST_DATABASE_DSN is not set.
+func Open(t testing.TB) *sql.DB {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN is not set")
+	}
+
+	once.Do(func() {
+		conn, err = sql.Open("mysql", dsn)
+		if err != nil {
+			return
+		}
+
+		var m *migrate.Migrate
+		m, err = migrations.New(conn)
+		if err != nil {
+			return
+		}
+
+		defer m.Close()
+
+		if upErr := m.Up(); upErr != nil && !errors.Is(upErr, migrate.ErrNoChange) {
+			err = upErr
+		}
+	})
+
+	if err != nil {
+		t.Fatalf("opening test database: %s", err)
+	}
+
+	return conn
+}
+
+// UniqueName returns a name no other test uses, even across runs against
+// the same database.
+func UniqueName(prefix string) string {
+	return fmt.Sprintf("%s-%d-%d", prefix, time.Now().UnixNano(), seq.Add(1))
+}
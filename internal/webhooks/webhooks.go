@@ -0,0 +1,256 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Event types sent to webhook subscriptions.
+const (
+	EventCreated    = "event.created"
+	EventUpdated    = "event.updated"
+	EventDeleted    = "event.deleted"
+	AttendeeAdded   = "attendee.added"
+	AttendeeRemoved = "attendee.removed"
+)
+
+// EventTypes lists every event type a subscription can ask for.
+var EventTypes = []string{EventCreated, EventUpdated, EventDeleted, AttendeeAdded, AttendeeRemoved}
+
+const (
+	SignatureHeader = "X-Webhook-Signature"
+	EventHeader     = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery"
+)
+
+var (
+	ErrInvalidSignature = errors.New("webhook signature does not match")
+	ErrStaleSignature   = errors.New("webhook signature is too old")
+	ErrInvalidURL       = errors.New("url must be an http or https URL")
+	ErrPrivateAddress   = errors.New("url must point to a public address")
+	ErrRedirect         = errors.New("webhook receivers must not redirect")
+)
+
+// Payload is the JSON body of every webhook request. ID identifies the
+// occurrence of the event and stays the same across retries and
+// subscriptions, so receivers can drop duplicates.
+type Payload struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+// GenerateSecret returns a new signing secret for a subscription.
+func GenerateSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+// Sign returns the signature header of a request body sent at the given
+// time: "t=<unix seconds>,v1=<hex HMAC-SHA256 of "<t>.<body>">". Signing the
+// timestamp along with the body lets receivers reject replayed requests.
+func Sign(secret string, body []byte, at time.Time) string {
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+	return "t=" + timestamp + ",v1=" + signature(secret, timestamp, body)
+}
+
+func signature(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the signature header of a received webhook. Signatures more
+// than tolerance away from now are rejected; a tolerance of 0 disables the
+// check.
+func Verify(secret, header string, body []byte, tolerance time.Duration, now time.Time) error {
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	if tolerance > 0 {
+		if age := now.Sub(time.Unix(unix, 0)); age > tolerance || age < -tolerance {
+			return ErrStaleSignature
+		}
+	}
+
+	expected := signature(secret, timestamp, body)
+	for _, s := range signatures {
+		if hmac.Equal([]byte(s), []byte(expected)) {
+			return nil
+		}
+	}
+
+	return ErrInvalidSignature
+}
+
+// Backoff returns how long to wait before retrying a delivery that has
+// failed attempts times: base, doubling with every attempt, up to max.
+func Backoff(attempts int, base, max time.Duration) time.Duration {
+	delay := base
+	for i := 1; i < attempts && delay < max; i++ {
+		delay *= 2
+	}
+
+	return min(delay, max)
+}
+
+// ValidateURL checks that a subscription URL is an http or https URL whose
+// host resolves to public addresses only, so subscriptions cannot be used to
+// reach the loopback interface, private networks or cloud metadata services.
+// The Client returned by NewClient checks the address again when connecting,
+// since the host may resolve differently by then.
+func ValidateURL(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return ErrInvalidURL
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, u.Hostname())
+	if err != nil {
+		return fmt.Errorf("url host could not be resolved: %w", err)
+	}
+
+	for _, addr := range addrs {
+		if !isPublic(addr.IP) {
+			return ErrPrivateAddress
+		}
+	}
+
+	return nil
+}
+
+// isPublic reports whether ip may receive webhooks: it is none of the
+// loopback, private, link-local, unspecified or multicast addresses.
+func isPublic(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() && !ip.IsMulticast() && !ip.IsUnspecified()
+}
+
+// NewClient returns the HTTP client webhooks are sent with. It only connects
+// to public addresses, whatever the receiver's host resolves to at the
+// time, goes to receivers directly rather than through a proxy, and does not
+// follow redirects.
+func NewClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+
+			if ip := net.ParseIP(host); ip == nil || !isPublic(ip) {
+				return ErrPrivateAddress
+			}
+
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return ErrRedirect
+		},
+	}
+}
+
+// Request is a webhook about to be sent to a subscription.
+type Request struct {
+	DeliveryID int
+	URL        string
+	Secret     string
+	EventType  string
+	Body       []byte
+}
+
+// Sender posts signed webhooks. Its zero value uses http.DefaultClient.
+type Sender struct {
+	Client    *http.Client
+	UserAgent string
+	Now       func() time.Time
+}
+
+// Send posts a webhook and returns the status code of the response. Only a
+// 2xx response counts as delivered; anything else is returned as an error
+// together with the status code, which is 0 when no response was received.
+func (s *Sender) Send(ctx context.Context, r Request) (int, error) {
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	now := time.Now
+	if s.Now != nil {
+		now = s.Now
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.URL, bytes.NewReader(r.Body))
+	if err != nil {
+		return 0, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, r.EventType)
+	req.Header.Set(DeliveryHeader, strconv.Itoa(r.DeliveryID))
+	req.Header.Set(SignatureHeader, Sign(r.Secret, r.Body, now()))
+	if s.UserAgent != "" {
+		req.Header.Set("User-Agent", s.UserAgent)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+
+	defer resp.Body.Close()
+
+	// Drain a little of the body so the connection can be reused.
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver responded with %s", resp.Status)
+	}
+
+	return resp.StatusCode, nil
+}
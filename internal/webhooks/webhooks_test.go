@@ -0,0 +1,155 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSendSignsRequests(t *testing.T) {
+	sentAt := time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC)
+	body := []byte(`{"id":"evt_1","type":"event.created"}`)
+
+	var header http.Header
+	var received []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Clone()
+		received, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	sender := &Sender{UserAgent: "test-agent", Now: func() time.Time { return sentAt }}
+	status, err := sender.Send(context.Background(), Request{DeliveryID: 42, URL: server.URL, Secret: "whsec_test", EventType: EventCreated, Body: body})
+	if err != nil || status != http.StatusNoContent {
+		t.Fatalf("Send() = %d, %v; want 204", status, err)
+	}
+
+	if string(received) != string(body) {
+		t.Errorf("receiver got %s, want %s", received, body)
+	}
+
+	if got := header.Get(EventHeader); got != EventCreated {
+		t.Errorf("%s = %q, want %q", EventHeader, got, EventCreated)
+	}
+	if got := header.Get(DeliveryHeader); got != "42" {
+		t.Errorf("%s = %q, want 42", DeliveryHeader, got)
+	}
+	if got := header.Get("User-Agent"); got != "test-agent" {
+		t.Errorf("User-Agent = %q, want test-agent", got)
+	}
+
+	signature := header.Get(SignatureHeader)
+	if err := Verify("whsec_test", signature, received, time.Minute, sentAt.Add(30*time.Second)); err != nil {
+		t.Errorf("verifying %q: %s", signature, err)
+	}
+	if err := Verify("whsec_other", signature, received, time.Minute, sentAt); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("verifying with another secret: got %v, want ErrInvalidSignature", err)
+	}
+	if err := Verify("whsec_test", signature, append(received, ' '), time.Minute, sentAt); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("verifying a changed body: got %v, want ErrInvalidSignature", err)
+	}
+	if err := Verify("whsec_test", signature, received, time.Minute, sentAt.Add(2*time.Minute)); !errors.Is(err, ErrStaleSignature) {
+		t.Errorf("verifying an old signature: got %v, want ErrStaleSignature", err)
+	}
+}
+
+func TestSendFailsOnNon2xx(t *testing.T) {
+	for _, status := range []int{http.StatusMovedPermanently, http.StatusBadRequest, http.StatusInternalServerError, http.StatusServiceUnavailable} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+		}))
+
+		sender := &Sender{Client: &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}}
+		got, err := sender.Send(context.Background(), Request{URL: server.URL, Secret: "whsec_test", Body: []byte("{}")})
+		if err == nil || got != status {
+			t.Errorf("Send() to a receiver answering %d = %d, %v; want the status and an error", status, got, err)
+		}
+
+		server.Close()
+	}
+
+	// A receiver that cannot be reached has no status code.
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	got, err := (&Sender{}).Send(context.Background(), Request{URL: server.URL, Secret: "whsec_test", Body: []byte("{}")})
+	if err == nil || got != 0 {
+		t.Errorf("Send() to a closed receiver = %d, %v; want 0 and an error", got, err)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{5, 8 * time.Minute},
+		{6, 10 * time.Minute},
+		{50, 10 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		if got := Backoff(tt.attempts, 30*time.Second, 10*time.Minute); got != tt.want {
+			t.Errorf("Backoff(%d) = %s, want %s", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestValidateURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		want error
+	}{
+		{"ftp://example.com/hook", ErrInvalidURL},
+		{"http:///hook", ErrInvalidURL},
+		{"http://127.0.0.1:8080/hook", ErrPrivateAddress},
+		{"http://localhost/hook", ErrPrivateAddress},
+		{"http://[::1]/hook", ErrPrivateAddress},
+		{"http://10.1.2.3/hook", ErrPrivateAddress},
+		{"https://172.16.0.10/hook", ErrPrivateAddress},
+		{"http://192.168.1.1/hook", ErrPrivateAddress},
+		{"http://169.254.169.254/latest/meta-data/", ErrPrivateAddress},
+		{"http://[fe80::1]/hook", ErrPrivateAddress},
+		{"http://0.0.0.0:9000/hook", ErrPrivateAddress},
+		{"http://[::ffff:127.0.0.1]/hook", ErrPrivateAddress},
+		{"https://93.184.215.14/hook", nil},
+	}
+
+	for _, tt := range tests {
+		if err := ValidateURL(context.Background(), tt.url); !errors.Is(err, tt.want) {
+			t.Errorf("ValidateURL(%q) = %v, want %v", tt.url, err, tt.want)
+		}
+	}
+}
+
+func TestClientRefusesPrivateAddresses(t *testing.T) {
+	var hits int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+	}))
+	defer server.Close()
+
+	// The receiver passed validation under another address and now
+	// resolves to the loopback interface.
+	sender := &Sender{Client: NewClient(time.Second)}
+	status, err := sender.Send(context.Background(), Request{URL: server.URL, Secret: "whsec_test", Body: []byte("{}")})
+	if !errors.Is(err, ErrPrivateAddress) || status != 0 {
+		t.Errorf("Send() to a loopback receiver = %d, %v; want ErrPrivateAddress", status, err)
+	}
+
+	if hits != 0 {
+		t.Errorf("loopback receiver got %d requests, want none", hits)
+	}
+
+	if err := NewClient(time.Second).CheckRedirect(nil, nil); !errors.Is(err, ErrRedirect) {
+		t.Errorf("following a redirect: got %v, want ErrRedirect", err)
+	}
+}